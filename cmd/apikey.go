@@ -0,0 +1,168 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/Gilf4/effective-mobile-task/internal/config"
+	"github.com/Gilf4/effective-mobile-task/internal/models"
+	"github.com/Gilf4/effective-mobile-task/internal/repository/db"
+	"github.com/Gilf4/effective-mobile-task/internal/repository/sqlite"
+	"github.com/Gilf4/effective-mobile-task/internal/service"
+	"github.com/google/uuid"
+)
+
+const apikeyUsage = `usage: app [-config path] apikey issue -name NAME -scopes SCOPE[,SCOPE...] [-expires-at YYYY-MM-DD|RFC3339]
+       app [-config path] apikey revoke ID
+       app [-config path] apikey list
+scopes: subscriptions:read, subscriptions:write, reports:read`
+
+// errUsage - неверные аргументы подкоманды; приложение завершается с кодом 2
+var errUsage = errors.New("invalid arguments")
+
+// runAPIKey выполняет подкоманду apikey: issue выпускает ключ и печатает его один раз,
+// revoke отзывает ключ по ID, list выводит выпущенные ключи без самих ключей
+func runAPIKey(ctx context.Context, cfg *config.Config, args []string, out io.Writer) error {
+	if len(args) == 0 {
+		return errUsage
+	}
+
+	repo, closeRepo, err := openAPIKeyRepository(ctx, cfg)
+	if err != nil {
+		return err
+	}
+	defer closeRepo()
+
+	svc := service.NewAPIKeyService(repo)
+
+	switch args[0] {
+	case "issue":
+		return issueAPIKey(ctx, svc, args[1:], out)
+	case "revoke":
+		if len(args) != 2 {
+			return errUsage
+		}
+		id, err := uuid.Parse(args[1])
+		if err != nil {
+			return fmt.Errorf("invalid api key id %q: %w", args[1], err)
+		}
+		if err := svc.RevokeAPIKey(ctx, id); err != nil {
+			return err
+		}
+		fmt.Fprintf(out, "api key %s revoked\n", id)
+		return nil
+	case "list":
+		if len(args) != 1 {
+			return errUsage
+		}
+		return listAPIKeys(ctx, svc, out)
+	}
+
+	return errUsage
+}
+
+func issueAPIKey(ctx context.Context, svc *service.APIKeyService, args []string, out io.Writer) error {
+	fs := flag.NewFlagSet("apikey issue", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	name := fs.String("name", "", "key name")
+	scopes := fs.String("scopes", "", "comma-separated scopes")
+	expiresAt := fs.String("expires-at", "", "expiry date")
+	if err := fs.Parse(args); err != nil || fs.NArg() > 0 {
+		return errUsage
+	}
+
+	req := models.IssueAPIKeyRequest{Name: *name}
+	for scope := range strings.SplitSeq(*scopes, ",") {
+		if scope = strings.TrimSpace(scope); scope != "" {
+			req.Scopes = append(req.Scopes, scope)
+		}
+	}
+	if *expiresAt != "" {
+		t, err := parseExpiresAt(*expiresAt)
+		if err != nil {
+			return err
+		}
+		req.ExpiresAt = &t
+	}
+
+	key, raw, err := svc.IssueAPIKey(ctx, req)
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(out, "id:      %s\n", key.ID)
+	fmt.Fprintf(out, "name:    %s\n", key.Name)
+	fmt.Fprintf(out, "scopes:  %s\n", strings.Join(key.Scopes, ","))
+	fmt.Fprintf(out, "expires: %s\n", formatOptionalTime(key.ExpiresAt, "never"))
+	fmt.Fprintf(out, "key:     %s\n", raw)
+	fmt.Fprintln(out, "store the key now: it cannot be shown again")
+	return nil
+}
+
+func listAPIKeys(ctx context.Context, svc *service.APIKeyService, out io.Writer) error {
+	keys, err := svc.ListAPIKeys(ctx)
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tNAME\tSCOPES\tEXPIRES AT\tREVOKED AT\tLAST USED AT")
+	for _, k := range keys {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n",
+			k.ID,
+			k.Name,
+			strings.Join(k.Scopes, ","),
+			formatOptionalTime(k.ExpiresAt, "-"),
+			formatOptionalTime(k.RevokedAt, "-"),
+			formatOptionalTime(k.LastUsedAt, "-"),
+		)
+	}
+	return w.Flush()
+}
+
+// openAPIKeyRepository открывает хранилище ключей. Ключи в памяти не переживают процесс,
+// поэтому подкоманда работает только с базой данных
+func openAPIKeyRepository(ctx context.Context, cfg *config.Config) (service.APIKeyRepository, func(), error) {
+	if cfg.Storage != config.StorageDB {
+		return nil, nil, errors.New("apikey command requires STORAGE=db")
+	}
+
+	if cfg.DB.Driver == config.DriverSQLite {
+		repo, err := sqlite.NewSubscriptionRepository(ctx, &cfg.DB)
+		if err != nil {
+			return nil, nil, err
+		}
+		return repo, func() {}, nil
+	}
+
+	pool, err := db.NewPool(ctx, &cfg.DB)
+	if err != nil {
+		return nil, nil, err
+	}
+	return db.NewSubscriptionRepository(pool), pool.Close, nil
+}
+
+// parseExpiresAt принимает дату (ключ действует до начала этого дня по UTC) или время в RFC 3339
+func parseExpiresAt(value string) (time.Time, error) {
+	if t, err := time.Parse(time.DateOnly, value); err == nil {
+		return t, nil
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid -expires-at %q: expected YYYY-MM-DD or RFC 3339", value)
+	}
+	return t, nil
+}
+
+func formatOptionalTime(t *time.Time, empty string) string {
+	if t == nil {
+		return empty
+	}
+	return t.UTC().Format(time.RFC3339)
+}
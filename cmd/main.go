@@ -2,6 +2,8 @@ package main
 
 import (
 	"context"
+	"errors"
+	"flag"
 	"fmt"
 	"log/slog"
 	"net/http"
@@ -11,10 +13,16 @@ import (
 	"time"
 
 	_ "github.com/Gilf4/effective-mobile-task/docs"
+	"github.com/Gilf4/effective-mobile-task/internal/auth"
 	"github.com/Gilf4/effective-mobile-task/internal/config"
 	"github.com/Gilf4/effective-mobile-task/internal/http/handler"
 	"github.com/Gilf4/effective-mobile-task/internal/http/middleware"
+	"github.com/Gilf4/effective-mobile-task/internal/logger"
+	"github.com/Gilf4/effective-mobile-task/internal/metrics"
+	"github.com/Gilf4/effective-mobile-task/internal/ratelimit"
 	"github.com/Gilf4/effective-mobile-task/internal/repository/db"
+	"github.com/Gilf4/effective-mobile-task/internal/repository/memory"
+	"github.com/Gilf4/effective-mobile-task/internal/repository/sqlite"
 	"github.com/Gilf4/effective-mobile-task/internal/service"
 )
 
@@ -29,33 +37,69 @@ const (
 // @description REST сервис для управления подписками пользователя.
 // @host localhost:8080
 // @BasePath /
+// @securityDefinitions.apikey BearerAuth
+// @in header
+// @name Authorization
+// @description JWT в формате "Bearer <token>"
+// @securityDefinitions.apikey ApiKeyAuth
+// @in header
+// @name Authorization
+// @description API-ключ в формате "ApiKey <key>"
 func main() {
 	cfg := config.MustLoad()
 
 	log := setupLogger(cfg.Env)
 
+	if args := flag.Args(); len(args) > 0 {
+		os.Exit(runCommand(cfg, args, log))
+	}
+
 	log.Info(
 		"starting application",
 		slog.String("env", cfg.Env),
+		slog.String("storage", cfg.Storage),
+		slog.String("db_driver", cfg.DB.Driver),
 		slog.Any("Server config", cfg.Server),
 	)
 
-	ctx := context.Background()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	m := metrics.New()
 
-	repo, err := db.NewSubscriptionRepository(ctx, &cfg.DB)
+	repo, checks, err := setupStorage(ctx, cfg, m, log)
 	if err != nil {
 		log.Error("failed to init repo", "err", err)
 		os.Exit(1)
 	}
 
-	service := service.NewSubscriptionService(repo)
+	apiKeys := service.NewAPIKeyService(repo)
+	service := service.NewSubscriptionService(repo, repo, repo, cfg.Idempotency.TTL, cfg.Idempotency.Lease)
+
+	go runIdempotencyCleanup(ctx, service, cfg.Idempotency.CleanupInterval, log)
+
+	m.MustRegister(metrics.NewActiveSubscriptionsCollector(service.ActiveSubscriptionsByService, log))
+
+	authenticate, err := setupAuth(cfg.Auth, cfg.Env, apiKeys, log)
+	if err != nil {
+		log.Error("failed to init auth", "err", err)
+		os.Exit(1)
+	}
+	guard := withRateLimit(authenticate, cfg.RateLimit, log)
 
 	h := handler.NewHandler(service, log)
+	health := handler.NewHealthHandler(checks, log)
 
 	mux := http.NewServeMux()
-	h.RegisterRoutes(mux)
+	h.RegisterRoutes(mux, guard)
+	health.RegisterRoutes(mux)
+	mux.Handle("GET /metrics", m.Handler())
+	h.RegisterAdminRoutes(mux, middleware.AdminToken(cfg.Admin.Token))
 
-	handler := middleware.Logging(log)(mux)
+	handler := middleware.Recover(log, m)(mux)
+	handler = middleware.Metrics(m)(handler)
+	handler = middleware.Logging(log)(handler)
+	handler = middleware.RequestID()(handler)
 
 	srv := &http.Server{
 		Addr:         fmt.Sprintf(":%d", cfg.Server.Port),
@@ -77,6 +121,10 @@ func main() {
 	sign := <-stop
 	log.Info("stopping application", slog.String("signal", sign.String()))
 
+	// пока /readyz отвечает 503, балансировщик успевает вывести экземпляр из ротации
+	health.SetShuttingDown()
+	time.Sleep(cfg.Server.ShutdownDelay)
+
 	shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer shutdownCancel()
 
@@ -84,24 +132,182 @@ func main() {
 		log.Error("server forced to shutdown", "err", err)
 	}
 
+	cancel()
+
 	log.Info("server exited properly")
 }
 
+type storage interface {
+	service.SubscriptionRepository
+	service.IdempotencyRepository
+	service.APIKeyRepository
+	service.ExchangeRateRepository
+}
+
+// setupStorage создает хранилище по конфигурации и проверки готовности его зависимостей
+// Для Postgres в m регистрируется статистика пула соединений
+func setupStorage(ctx context.Context, cfg *config.Config, m *metrics.Metrics, log *slog.Logger) (storage, []handler.ReadinessCheck, error) {
+	switch {
+	case cfg.Storage == config.StorageMemory:
+		return memory.NewSubscriptionRepository(), nil, nil
+	case cfg.DB.Driver == config.DriverSQLite:
+		repo, err := sqlite.NewSubscriptionRepository(ctx, &cfg.DB)
+		if err != nil {
+			return nil, nil, err
+		}
+		checks := []handler.ReadinessCheck{{Name: "database", Check: repo.Ping}}
+		return repo, checks, nil
+	default:
+		pool, err := db.NewPool(ctx, &cfg.DB)
+		if err != nil {
+			return nil, nil, err
+		}
+		migrator, err := prepareSchema(ctx, cfg, pool, log)
+		if err != nil {
+			pool.Close()
+			return nil, nil, err
+		}
+		m.MustRegister(metrics.NewPoolCollector(pool))
+
+		checks := []handler.ReadinessCheck{
+			{Name: "database", Check: pool.Ping},
+			{Name: "migrations", Check: migrationsApplied(migrator)},
+		}
+		return db.NewSubscriptionRepository(pool), checks, nil
+	}
+}
+
+// setupAuth создает фабрику middleware аутентификации маршрутов подписок:
+// JWT принимаются, если заданы ключи проверки, API-ключи - если они включены.
+// Без аутентификации маршруты доступны всем, поэтому она отключается только явно
+// через AUTH_DISABLED и только вне prod; если не включен ни один способ, возвращается ошибка
+func setupAuth(cfg config.AuthConfig, env string, keys *service.APIKeyService, log *slog.Logger) (func(auth.Scope) func(http.Handler) http.Handler, error) {
+	if cfg.Disabled {
+		if env == envProd {
+			return nil, errors.New("AUTH_DISABLED is not allowed in prod")
+		}
+		log.Warn("authentication is disabled by AUTH_DISABLED")
+		return func(auth.Scope) func(http.Handler) http.Handler {
+			return func(next http.Handler) http.Handler { return next }
+		}, nil
+	}
+	if !cfg.Enabled() {
+		return nil, errors.New("no authentication configured: set JWT keys or API_KEYS_ENABLED, or AUTH_DISABLED=true outside prod")
+	}
+
+	var tokens middleware.TokenVerifier
+	if cfg.JWTEnabled() {
+		verifier, err := auth.NewVerifier(cfg)
+		if err != nil {
+			return nil, err
+		}
+		tokens = verifier
+	}
+
+	var apiKeys middleware.APIKeyAuthenticator
+	if cfg.APIKeysEnabled {
+		apiKeys = keys
+	}
+
+	return middleware.Authenticate(tokens, apiKeys, log), nil
+}
+
+// withRateLimit дополняет аутентификацию маршрутов ограничением частоты запросов
+// До аутентификации действует общий лимит на IP-адрес, чтобы запросы без учетных данных
+// или с неверными тоже ограничивались. После нее - лимит группы маршрутов, определяемой
+// областью доступа, чтобы клиенты различались по API-ключу или subject токена
+func withRateLimit(authenticate func(auth.Scope) func(http.Handler) http.Handler, cfg config.RateLimitConfig, log *slog.Logger) func(auth.Scope) func(http.Handler) http.Handler {
+	if !cfg.Enabled {
+		return authenticate
+	}
+
+	limiter := ratelimit.NewMemory()
+	policies := map[auth.Scope]ratelimit.Policy{
+		auth.ScopeSubscriptionsRead:  {Limit: cfg.Read, Period: cfg.Period},
+		auth.ScopeSubscriptionsWrite: {Limit: cfg.Write, Period: cfg.Period},
+		auth.ScopeReportsRead:        {Limit: cfg.Reports, Period: cfg.Period},
+	}
+
+	ipMw := middleware.RateLimitByIP(limiter, "ip", ratelimit.Policy{Limit: cfg.IP, Period: cfg.Period}, log)
+
+	return func(scope auth.Scope) func(http.Handler) http.Handler {
+		authMw := authenticate(scope)
+		limitMw := middleware.RateLimit(limiter, string(scope), policies[scope], log)
+		return func(next http.Handler) http.Handler {
+			return ipMw(authMw(limitMw(next)))
+		}
+	}
+}
+
+// runCommand выполняет подкоманду вместо запуска сервера и возвращает код завершения
+func runCommand(cfg *config.Config, args []string, log *slog.Logger) int {
+	ctx := context.Background()
+
+	var err error
+	switch args[0] {
+	case "migrate":
+		if len(args) != 2 {
+			fmt.Fprintln(os.Stderr, migrateUsage)
+			return 2
+		}
+		err = runMigrate(ctx, cfg, args[1], log)
+	case "apikey":
+		err = runAPIKey(ctx, cfg, args[1:], os.Stdout)
+		if errors.Is(err, errUsage) {
+			fmt.Fprintln(os.Stderr, apikeyUsage)
+			return 2
+		}
+	default:
+		fmt.Fprintln(os.Stderr, migrateUsage)
+		fmt.Fprintln(os.Stderr, apikeyUsage)
+		return 2
+	}
+
+	if err != nil {
+		log.Error(args[0]+" failed", "err", err)
+		return 1
+	}
+	return 0
+}
+
+// runIdempotencyCleanup периодически удаляет истекшие ключи идемпотентности до отмены ctx
+func runIdempotencyCleanup(ctx context.Context, svc *service.SubscriptionService, interval time.Duration, log *slog.Logger) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			deleted, err := svc.CleanupExpiredIdempotencyKeys(ctx)
+			if err != nil {
+				log.Error("failed to cleanup idempotency keys", "err", err)
+				continue
+			}
+			if deleted > 0 {
+				log.Info("expired idempotency keys removed", slog.Int64("count", deleted))
+			}
+		}
+	}
+}
+
+// setupLogger создает логгер, дополняющий записи атрибутами из контекста (request_id, trace_id)
 func setupLogger(env string) *slog.Logger {
 	var log *slog.Logger
 
 	switch env {
 	case envLocal:
 		log = slog.New(
-			slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelDebug}),
+			logger.NewContextHandler(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelDebug})),
 		)
 	case envDev:
 		log = slog.New(
-			slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelDebug}),
+			logger.NewContextHandler(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelDebug})),
 		)
 	case envProd:
 		log = slog.New(
-			slog.NewJSONHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelInfo}),
+			logger.NewContextHandler(slog.NewJSONHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelInfo})),
 		)
 	}
 
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"text/tabwriter"
+
+	"github.com/Gilf4/effective-mobile-task/internal/config"
+	"github.com/Gilf4/effective-mobile-task/internal/migrate"
+	"github.com/Gilf4/effective-mobile-task/internal/repository/db"
+	"github.com/Gilf4/effective-mobile-task/migrations"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+const migrateUsage = "usage: app [-config path] migrate up|down|status"
+
+// runMigrate выполняет подкоманду migrate: up применяет все миграции,
+// down откатывает последнюю, status выводит состояние каждой миграции
+func runMigrate(ctx context.Context, cfg *config.Config, command string, log *slog.Logger) error {
+	if command != "up" && command != "down" && command != "status" {
+		return errors.New(migrateUsage)
+	}
+	if cfg.Storage != config.StorageDB || cfg.DB.Driver != config.DriverPostgres {
+		return errors.New("migrate command requires STORAGE=db and DB_DRIVER=postgres, sqlite schema is migrated on startup")
+	}
+
+	pool, err := db.NewPool(ctx, &cfg.DB)
+	if err != nil {
+		return err
+	}
+	defer pool.Close()
+
+	migrator, err := newMigrator(pool)
+	if err != nil {
+		return err
+	}
+
+	switch command {
+	case "up":
+		applied, err := migrator.Up(ctx)
+		for _, mig := range applied {
+			log.Info("migration applied", slog.String("migration", mig.Name))
+		}
+		if err != nil {
+			return err
+		}
+		if len(applied) == 0 {
+			log.Info("no migrations to apply")
+		}
+	case "down":
+		reverted, err := migrator.Down(ctx)
+		if err != nil {
+			return err
+		}
+		if reverted == nil {
+			log.Info("no migrations to revert")
+			return nil
+		}
+		log.Info("migration reverted", slog.String("migration", reverted.Name))
+	case "status":
+		statuses, err := migrator.Status(ctx)
+		if err != nil {
+			return err
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "APPLIED AT\tMIGRATION")
+		for _, s := range statuses {
+			appliedAt := "Pending"
+			if s.AppliedAt != nil {
+				appliedAt = s.AppliedAt.UTC().Format("2006-01-02 15:04:05")
+			}
+			fmt.Fprintf(w, "%s\t%s\n", appliedAt, s.Name)
+		}
+		return w.Flush()
+	}
+
+	return nil
+}
+
+// prepareSchema применяет миграции при DB_AUTO_MIGRATE и проверяет, что схема базы
+// совместима с приложением: схема новее кода - ошибка, непримененные миграции - предупреждение
+func prepareSchema(ctx context.Context, cfg *config.Config, pool *pgxpool.Pool, log *slog.Logger) (*migrate.Migrator, error) {
+	migrator, err := newMigrator(pool)
+	if err != nil {
+		return nil, err
+	}
+
+	if cfg.DB.AutoMigrate {
+		applied, err := migrator.Up(ctx)
+		for _, mig := range applied {
+			log.Info("migration applied", slog.String("migration", mig.Name))
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	pending, err := migrator.Pending(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if pending > 0 {
+		log.Warn("database schema is behind the application, run migrate up", slog.Int("pending", pending))
+	}
+
+	return migrator, nil
+}
+
+// migrationsApplied - проверка готовности: все встроенные миграции применены к базе
+func migrationsApplied(migrator *migrate.Migrator) func(ctx context.Context) error {
+	return func(ctx context.Context) error {
+		pending, err := migrator.Pending(ctx)
+		if err != nil {
+			return err
+		}
+		if pending > 0 {
+			return fmt.Errorf("%d migrations pending", pending)
+		}
+		return nil
+	}
+}
+
+func newMigrator(pool *pgxpool.Pool) (*migrate.Migrator, error) {
+	list, err := migrate.Load(migrations.FS)
+	if err != nil {
+		return nil, err
+	}
+
+	return migrate.New(pool, list), nil
+}
@@ -15,33 +15,34 @@ const docTemplate = `{
     "host": "{{.Host}}",
     "basePath": "{{.BasePath}}",
     "paths": {
-        "/subscriptions": {
+        "/admin/exchange-rates": {
             "get": {
-                "description": "Получение списка подписок с пагинацией. При указании user_id возвращаются подписки конкретного пользователя, иначе все подписки.",
+                "description": "Возвращает курсы валютных пар по месяцам. Курс действует с указанного месяца до следующего курса той же пары. Требует заголовок ` + "`" + `X-Admin-Token` + "`" + `.",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "subscriptions"
+                    "admin"
                 ],
-                "summary": "Получить список подписок",
+                "summary": "Список курсов валют",
                 "parameters": [
                     {
                         "type": "string",
-                        "description": "User UUID (optional - returns all if not provided)",
-                        "name": "user_id",
-                        "in": "query"
+                        "description": "Admin token",
+                        "name": "X-Admin-Token",
+                        "in": "header",
+                        "required": true
                     },
                     {
-                        "type": "integer",
-                        "description": "Limit (default: 10, max: 100)",
-                        "name": "limit",
+                        "type": "string",
+                        "description": "Base currency filter (ISO 4217)",
+                        "name": "base_currency",
                         "in": "query"
                     },
                     {
-                        "type": "integer",
-                        "description": "Offset (default: 0)",
-                        "name": "offset",
+                        "type": "string",
+                        "description": "Quote currency filter (ISO 4217)",
+                        "name": "quote_currency",
                         "in": "query"
                     }
                 ],
@@ -49,25 +50,34 @@ const docTemplate = `{
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "$ref": "#/definitions/models.PaginatedSubscriptionResponse"
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/models.ExchangeRateResponse"
+                            }
                         }
                     },
                     "400": {
                         "description": "Invalid parameters",
                         "schema": {
-                            "type": "string"
+                            "$ref": "#/definitions/problem.Details"
+                        }
+                    },
+                    "403": {
+                        "description": "Admin access required",
+                        "schema": {
+                            "$ref": "#/definitions/problem.Details"
                         }
                     },
                     "500": {
                         "description": "Internal server error",
                         "schema": {
-                            "type": "string"
+                            "$ref": "#/definitions/problem.Details"
                         }
                     }
                 }
             },
             "post": {
-                "description": "Создание новой подписки. Поле ` + "`" + `end_date` + "`" + ` опциональное. Если не указано - подписка бессрочная.",
+                "description": "Сохраняет курсы из массива одной транзакцией: курс той же пары на тот же месяц заменяется. Требует заголовок ` + "`" + `X-Admin-Token` + "`" + `.\u003cbr\u003e\n` + "`" + `rate` + "`" + ` - сколько единиц ` + "`" + `quote_currency` + "`" + ` стоит одна единица ` + "`" + `base_currency` + "`" + `, строкой в десятичной записи не более чем с 8 знаками после запятой.\nДля пересчета используется курс пары в любом направлении, действующий в месяце списания.",
                 "consumes": [
                     "application/json"
                 ],
@@ -75,289 +85,1945 @@ const docTemplate = `{
                     "application/json"
                 ],
                 "tags": [
-                    "subscriptions"
+                    "admin"
                 ],
-                "summary": "Создать подписку",
+                "summary": "Загрузить курсы валют",
                 "parameters": [
                     {
-                        "description": "Subscription info",
+                        "type": "string",
+                        "description": "Admin token",
+                        "name": "X-Admin-Token",
+                        "in": "header",
+                        "required": true
+                    },
+                    {
+                        "description": "Exchange rates",
                         "name": "input",
                         "in": "body",
                         "required": true,
                         "schema": {
-                            "$ref": "#/definitions/models.CreateSubscriptionRequest"
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/models.ExchangeRateRequest"
+                            }
                         }
                     }
                 ],
                 "responses": {
-                    "201": {
-                        "description": "Created",
+                    "200": {
+                        "description": "OK",
                         "schema": {
-                            "$ref": "#/definitions/models.SubscriptionResponse"
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/models.ExchangeRateResponse"
+                            }
                         }
                     },
                     "400": {
-                        "description": "Invalid request body",
+                        "description": "Invalid request body or rates",
                         "schema": {
-                            "type": "string"
+                            "$ref": "#/definitions/problem.Details"
+                        }
+                    },
+                    "403": {
+                        "description": "Admin access required",
+                        "schema": {
+                            "$ref": "#/definitions/problem.Details"
                         }
                     },
                     "500": {
                         "description": "Internal server error",
                         "schema": {
-                            "type": "string"
+                            "$ref": "#/definitions/problem.Details"
                         }
                     }
                 }
             }
         },
-        "/subscriptions/total": {
-            "get": {
-                "description": "Расчёт общей стоимости активных подписок за указанный период.\u003cbr\u003e\u003cbr\u003e\n**Логика расчёта:**\u003cbr\u003e\n- Подписка с ` + "`" + `end_date` + "`" + ` учитывается, если пересекается с запрошенным периодом (start_date \u003c= end_period AND end_date \u003e= start_period)\u003cbr\u003e\n- Подписка без ` + "`" + `end_date` + "`" + ` (бессрочная) учитывается полностью, если её ` + "`" + `start_date \u003c= end_period` + "`" + `\u003cbr\u003e\n- Бессрочная подписка всегда учитывается полной стоимостью, независимо от длины запрошенного периода",
+        "/admin/exchange-rates/import": {
+            "post": {
+                "description": "Загружает курсы из CSV-файла с колонками ` + "`" + `base_currency` + "`" + `, ` + "`" + `quote_currency` + "`" + `, ` + "`" + `month` + "`" + ` (MM-YYYY) и ` + "`" + `rate` + "`" + `; прочие колонки игнорируются. Требует заголовок ` + "`" + `X-Admin-Token` + "`" + `.\u003cbr\u003e\nФайл передается телом запроса (` + "`" + `text/csv` + "`" + `) или в поле ` + "`" + `file` + "`" + ` формы ` + "`" + `multipart/form-data` + "`" + `. Все строки проверяются до записи:\nпри ` + "`" + `dry_run=true` + "`" + ` или наличии ошибок ничего не сохраняется, иначе курсы сохраняются одной транзакцией с заменой существующих.",
+                "consumes": [
+                    "text/csv",
+                    "multipart/form-data"
+                ],
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "subscriptions"
+                    "admin"
                 ],
-                "summary": "Получение общей стоимости подписок за заданный период",
+                "summary": "Импортировать курсы валют из CSV",
                 "parameters": [
                     {
                         "type": "string",
-                        "description": "User UUID (optional - calculates total for all users if not provided)",
-                        "name": "user_id",
-                        "in": "query"
-                    },
-                    {
-                        "type": "string",
-                        "description": "Format: MM-YYYY",
-                        "name": "start_date",
-                        "in": "query",
+                        "description": "Admin token",
+                        "name": "X-Admin-Token",
+                        "in": "header",
                         "required": true
                     },
                     {
-                        "type": "string",
-                        "description": "Format: MM-YYYY",
-                        "name": "end_date",
-                        "in": "query",
-                        "required": true
+                        "type": "boolean",
+                        "description": "Validate only, do not write (default: false)",
+                        "name": "dry_run",
+                        "in": "query"
                     },
                     {
-                        "type": "string",
-                        "description": "Service Name filter (optional)",
-                        "name": "service_name",
-                        "in": "query"
+                        "type": "file",
+                        "description": "CSV file (multipart/form-data)",
+                        "name": "file",
+                        "in": "formData"
                     }
                 ],
                 "responses": {
                     "200": {
-                        "description": "OK",
+                        "description": "Dry run report",
                         "schema": {
-                            "$ref": "#/definitions/models.TotalCostResponse"
+                            "$ref": "#/definitions/models.ImportReport"
+                        }
+                    },
+                    "201": {
+                        "description": "Import completed",
+                        "schema": {
+                            "$ref": "#/definitions/models.ImportReport"
                         }
                     },
                     "400": {
-                        "description": "Invalid parameters",
+                        "description": "Invalid file or rows",
                         "schema": {
-                            "type": "string"
+                            "$ref": "#/definitions/models.ImportReport"
                         }
                     },
-                    "404": {
-                        "description": "No subscriptions found for the specified criteria",
+                    "403": {
+                        "description": "Admin access required",
                         "schema": {
-                            "type": "string"
+                            "$ref": "#/definitions/problem.Details"
                         }
                     },
                     "500": {
                         "description": "Internal server error",
                         "schema": {
-                            "type": "string"
+                            "$ref": "#/definitions/problem.Details"
                         }
                     }
                 }
             }
         },
-        "/subscriptions/{id}": {
-            "put": {
-                "description": "Обновление данных подписки. Все поля опциональные. При обновлении ` + "`" + `end_date` + "`" + ` проверяется, что ` + "`" + `end_date \u003e= start_date` + "`" + `.",
-                "consumes": [
-                    "application/json"
-                ],
-                "produces": [
-                    "application/json"
-                ],
+        "/admin/exchange-rates/{base}/{quote}/{month}": {
+            "delete": {
+                "description": "Удаляет курс пары на месяц. Требует заголовок ` + "`" + `X-Admin-Token` + "`" + `.",
                 "tags": [
-                    "subscriptions"
+                    "admin"
                 ],
-                "summary": "Обновить подписку",
+                "summary": "Удалить курс валют",
                 "parameters": [
                     {
                         "type": "string",
-                        "description": "Subscription ID",
-                        "name": "id",
+                        "description": "Admin token",
+                        "name": "X-Admin-Token",
+                        "in": "header",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Base currency (ISO 4217)",
+                        "name": "base",
                         "in": "path",
                         "required": true
                     },
                     {
-                        "description": "Subscription update info (all fields optional)",
-                        "name": "input",
-                        "in": "body",
-                        "required": true,
-                        "schema": {
-                            "$ref": "#/definitions/models.UpdateSubscriptionRequest"
-                        }
+                        "type": "string",
+                        "description": "Quote currency (ISO 4217)",
+                        "name": "quote",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Format: MM-YYYY",
+                        "name": "month",
+                        "in": "path",
+                        "required": true
                     }
                 ],
                 "responses": {
-                    "200": {
-                        "description": "OK",
+                    "204": {
+                        "description": "No Content"
+                    },
+                    "400": {
+                        "description": "Invalid parameters",
                         "schema": {
-                            "$ref": "#/definitions/models.SubscriptionResponse"
+                            "$ref": "#/definitions/problem.Details"
                         }
                     },
-                    "400": {
-                        "description": "Invalid request",
+                    "403": {
+                        "description": "Admin access required",
                         "schema": {
-                            "type": "string"
+                            "$ref": "#/definitions/problem.Details"
                         }
                     },
                     "404": {
-                        "description": "Subscription not found",
+                        "description": "Exchange rate not found",
                         "schema": {
-                            "type": "string"
+                            "$ref": "#/definitions/problem.Details"
                         }
                     },
                     "500": {
                         "description": "Internal server error",
                         "schema": {
-                            "type": "string"
+                            "$ref": "#/definitions/problem.Details"
                         }
                     }
                 }
-            },
+            }
+        },
+        "/admin/subscriptions/archived": {
             "delete": {
+                "description": "Окончательно удаляет подписки, находящиеся в архиве дольше ` + "`" + `older_than_days` + "`" + ` дней. Требует заголовок ` + "`" + `X-Admin-Token` + "`" + `.",
+                "produces": [
+                    "application/json"
+                ],
                 "tags": [
-                    "subscriptions"
+                    "admin"
                 ],
-                "summary": "Удалить подписку",
+                "summary": "Очистить архив подписок",
                 "parameters": [
                     {
                         "type": "string",
-                        "description": "Subscription ID",
-                        "name": "id",
-                        "in": "path",
+                        "description": "Admin token",
+                        "name": "X-Admin-Token",
+                        "in": "header",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Minimum number of days in archive",
+                        "name": "older_than_days",
+                        "in": "query",
                         "required": true
                     }
                 ],
                 "responses": {
-                    "204": {
-                        "description": "No Content"
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/models.PurgeArchivedResponse"
+                        }
                     },
                     "400": {
-                        "description": "Invalid ID format",
+                        "description": "Invalid parameters",
                         "schema": {
-                            "type": "string"
+                            "$ref": "#/definitions/problem.Details"
                         }
                     },
-                    "404": {
-                        "description": "Subscription not found",
+                    "403": {
+                        "description": "Admin access required",
                         "schema": {
-                            "type": "string"
+                            "$ref": "#/definitions/problem.Details"
                         }
                     },
                     "500": {
                         "description": "Internal server error",
                         "schema": {
-                            "type": "string"
+                            "$ref": "#/definitions/problem.Details"
                         }
                     }
                 }
             }
-        }
-    },
-    "definitions": {
-        "models.CreateSubscriptionRequest": {
-            "type": "object",
-            "properties": {
-                "end_date": {
-                    "type": "string"
-                },
-                "price": {
-                    "type": "integer"
-                },
-                "service_name": {
-                    "type": "string"
-                },
-                "start_date": {
-                    "type": "string"
-                },
-                "user_id": {
-                    "type": "string"
-                }
-            }
         },
-        "models.PaginatedSubscriptionResponse": {
-            "type": "object",
-            "properties": {
-                "data": {
-                    "type": "array",
-                    "items": {
-                        "$ref": "#/definitions/models.SubscriptionResponse"
+        "/healthz": {
+            "get": {
+                "description": "Возвращает 200, пока процесс запущен и обрабатывает HTTP-запросы. Зависимости не проверяются.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "health"
+                ],
+                "summary": "Проверка жизнеспособности",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/models.HealthResponse"
+                        }
                     }
-                },
-                "has_more": {
-                    "type": "boolean"
-                },
-                "limit": {
-                    "type": "integer"
-                },
-                "offset": {
-                    "type": "integer"
-                },
-                "total": {
-                    "type": "integer"
-                }
-            }
-        },
-        "models.SubscriptionResponse": {
-            "type": "object",
-            "properties": {
-                "end_date": {
-                    "type": "string"
-                },
-                "id": {
-                    "type": "string"
-                },
-                "price": {
-                    "type": "integer"
-                },
-                "service_name": {
-                    "type": "string"
-                },
-                "start_date": {
-                    "type": "string"
-                },
-                "updated_at": {
-                    "type": "string"
-                },
-                "user_id": {
-                    "type": "string"
                 }
             }
         },
-        "models.TotalCostResponse": {
-            "type": "object",
-            "properties": {
-                "total_cost": {
-                    "type": "integer"
+        "/readyz": {
+            "get": {
+                "description": "Проверяет зависимости приложения (база данных, миграции) и возвращает статус каждой из них.\u003cbr\u003e\nВо время остановки сервера всегда возвращает 503.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "health"
+                ],
+                "summary": "Проверка готовности",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/models.ReadinessResponse"
+                        }
+                    },
+                    "503": {
+                        "description": "Service Unavailable",
+                        "schema": {
+                            "$ref": "#/definitions/models.ReadinessResponse"
+                        }
+                    }
                 }
             }
         },
-        "models.UpdateSubscriptionRequest": {
-            "type": "object",
-            "properties": {
-                "end_date": {
-                    "type": "string"
+        "/subscriptions": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    },
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Получение списка подписок с пагинацией. При указании user_id возвращаются подписки конкретного пользователя, иначе все подписки.\u003cbr\u003e\nВсе фильтры опциональные и объединяются через AND. Даты в формате MM-YYYY, диапазоны включительные.\u003cbr\u003e\nСортировка задается списком полей через запятую, префикс ` + "`" + `-` + "`" + ` означает убывание, например ` + "`" + `sort=price,-start_date` + "`" + `. По умолчанию ` + "`" + `-created_at` + "`" + `.\u003cbr\u003e\u003cbr\u003e\n**Пагинация:** поддерживаются режимы ` + "`" + `offset` + "`" + ` и курсорный. При сортировке по умолчанию ответ содержит ` + "`" + `next_cursor` + "`" + `,\nкоторый передается в параметре ` + "`" + `cursor` + "`" + ` для получения следующей страницы. Курсор не совместим с ` + "`" + `offset` + "`" + ` и ` + "`" + `sort` + "`" + `.\u003cbr\u003e\nПоле ` + "`" + `total` + "`" + ` возвращается при ` + "`" + `include_total=true` + "`" + `; по умолчанию считается только в режиме offset.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "subscriptions"
+                ],
+                "summary": "Получить список подписок",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "User UUID (optional - returns all if not provided)",
+                        "name": "user_id",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Limit (default: 20, max: 100)",
+                        "name": "limit",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Offset (default: 0)",
+                        "name": "offset",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Opaque cursor from next_cursor of the previous page",
+                        "name": "cursor",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Calculate total count (default: true without cursor, false with cursor)",
+                        "name": "include_total",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Include archived subscriptions (default: false)",
+                        "name": "include_deleted",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Exact service name",
+                        "name": "service_name",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Service name prefix",
+                        "name": "service_name_prefix",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Minimum price (inclusive)",
+                        "name": "price_min",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Maximum price (inclusive)",
+                        "name": "price_max",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Only subscriptions active in this month (MM-YYYY)",
+                        "name": "active_at",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Minimum start_date (MM-YYYY)",
+                        "name": "start_date_from",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Maximum start_date (MM-YYYY)",
+                        "name": "start_date_to",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Minimum end_date (MM-YYYY)",
+                        "name": "end_date_from",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Maximum end_date (MM-YYYY)",
+                        "name": "end_date_to",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "true - only subscriptions without end_date, false - only with end_date",
+                        "name": "open_ended",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Sort fields: service_name, price, user_id, start_date, end_date, created_at, updated_at",
+                        "name": "sort",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/models.PaginatedSubscriptionResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid parameters",
+                        "schema": {
+                            "$ref": "#/definitions/problem.Details"
+                        }
+                    },
+                    "401": {
+                        "description": "Missing or invalid credentials",
+                        "schema": {
+                            "$ref": "#/definitions/problem.Details"
+                        }
+                    },
+                    "403": {
+                        "description": "user_id of another user or missing scope",
+                        "schema": {
+                            "$ref": "#/definitions/problem.Details"
+                        }
+                    },
+                    "429": {
+                        "description": "Rate limit exceeded",
+                        "schema": {
+                            "$ref": "#/definitions/problem.Details"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/problem.Details"
+                        }
+                    }
+                }
+            },
+            "post": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    },
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Создание новой подписки. Поле ` + "`" + `end_date` + "`" + ` опциональное. Если не указано - подписка бессрочная.\u003cbr\u003e\n` + "`" + `price` + "`" + ` - стоимость одного периода ` + "`" + `billing_period` + "`" + ` (` + "`" + `month` + "`" + ` по умолчанию, ` + "`" + `quarter` + "`" + `, ` + "`" + `year` + "`" + `, ` + "`" + `week` + "`" + `)\nв минорных единицах валюты ` + "`" + `currency` + "`" + ` (код ISO 4217, по умолчанию ` + "`" + `RUB` + "`" + `): 19990 RUB - 199,90 руб.\n**Несовместимое изменение:** раньше ` + "`" + `price` + "`" + ` задавался в целых рублях, теперь - в копейках (400 руб. - ` + "`" + `40000` + "`" + `).\u003cbr\u003e\nКвартальная и годовая подписка с ` + "`" + `end_date` + "`" + ` должна длиться целое число периодов, иначе запрос отклоняется (` + "`" + `partial_billing_period` + "`" + `).\u003cbr\u003e\nПри передаче заголовка ` + "`" + `Idempotency-Key` + "`" + ` повторный запрос с тем же ключом и телом возвращает исходный ответ без создания новой подписки,\nа запрос с тем же ключом и другим телом отклоняется с кодом 409.\nПока исходный запрос выполняется, повтор получает 409 (` + "`" + `idempotency_key_in_progress` + "`" + `); если исходный запрос не завершился за ` + "`" + `IDEMPOTENCY_LEASE` + "`" + `, повтор выполняет его заново.\nПри включенной аутентификации обычный пользователь создает подписки только для себя; если ` + "`" + `user_id` + "`" + ` не указан, используется subject токена.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "subscriptions"
+                ],
+                "summary": "Создать подписку",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Unique key to safely retry the request",
+                        "name": "Idempotency-Key",
+                        "in": "header"
+                    },
+                    {
+                        "description": "Subscription info",
+                        "name": "input",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/models.CreateSubscriptionRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Created",
+                        "schema": {
+                            "$ref": "#/definitions/models.SubscriptionResponse"
+                        },
+                        "headers": {
+                            "ETag": {
+                                "type": "string",
+                                "description": "Subscription version"
+                            },
+                            "Idempotent-Replayed": {
+                                "type": "string",
+                                "description": "true if the response was replayed for a repeated Idempotency-Key"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid request body",
+                        "schema": {
+                            "$ref": "#/definitions/problem.Details"
+                        }
+                    },
+                    "401": {
+                        "description": "Missing or invalid credentials",
+                        "schema": {
+                            "$ref": "#/definitions/problem.Details"
+                        }
+                    },
+                    "403": {
+                        "description": "user_id of another user or missing scope",
+                        "schema": {
+                            "$ref": "#/definitions/problem.Details"
+                        }
+                    },
+                    "409": {
+                        "description": "Idempotency key reused with a different body or still in progress",
+                        "schema": {
+                            "$ref": "#/definitions/problem.Details"
+                        }
+                    },
+                    "429": {
+                        "description": "Rate limit exceeded",
+                        "schema": {
+                            "$ref": "#/definitions/problem.Details"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/problem.Details"
+                        }
+                    }
+                }
+            }
+        },
+        "/subscriptions/batch": {
+            "post": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    },
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Создает подписки из массива в одной транзакции (не более 1000 элементов).\u003cbr\u003e\n` + "`" + `mode=atomic` + "`" + ` (по умолчанию) - все или ничего: при любой ошибке ничего не сохраняется, ответ содержит ошибки по индексам элементов.\u003cbr\u003e\n` + "`" + `mode=partial` + "`" + ` - валидные элементы сохраняются, ошибочные пропускаются; при наличии ошибок возвращается 207.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "subscriptions"
+                ],
+                "summary": "Пакетное создание подписок",
+                "parameters": [
+                    {
+                        "enum": [
+                            "atomic",
+                            "partial"
+                        ],
+                        "type": "string",
+                        "description": "Batch mode (default: atomic)",
+                        "name": "mode",
+                        "in": "query"
+                    },
+                    {
+                        "description": "Subscriptions to create",
+                        "name": "input",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/models.CreateSubscriptionRequest"
+                            }
+                        }
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Created",
+                        "schema": {
+                            "$ref": "#/definitions/models.BatchResponse"
+                        }
+                    },
+                    "207": {
+                        "description": "Some items failed (partial mode)",
+                        "schema": {
+                            "$ref": "#/definitions/models.BatchResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid request or item validation failed (atomic mode)",
+                        "schema": {
+                            "$ref": "#/definitions/models.BatchResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "Missing or invalid credentials",
+                        "schema": {
+                            "$ref": "#/definitions/problem.Details"
+                        }
+                    },
+                    "403": {
+                        "description": "Missing scope",
+                        "schema": {
+                            "$ref": "#/definitions/problem.Details"
+                        }
+                    },
+                    "429": {
+                        "description": "Rate limit exceeded",
+                        "schema": {
+                            "$ref": "#/definitions/problem.Details"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/problem.Details"
+                        }
+                    }
+                }
+            },
+            "delete": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    },
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Архивирует подписки по массиву ID в одной транзакции (не более 1000 элементов). Режимы ` + "`" + `atomic` + "`" + ` и ` + "`" + `partial` + "`" + ` работают так же, как при пакетном создании.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "subscriptions"
+                ],
+                "summary": "Пакетное удаление подписок",
+                "parameters": [
+                    {
+                        "enum": [
+                            "atomic",
+                            "partial"
+                        ],
+                        "type": "string",
+                        "description": "Batch mode (default: atomic)",
+                        "name": "mode",
+                        "in": "query"
+                    },
+                    {
+                        "description": "Subscription IDs",
+                        "name": "input",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/models.BatchResponse"
+                        }
+                    },
+                    "207": {
+                        "description": "Some items failed (partial mode)",
+                        "schema": {
+                            "$ref": "#/definitions/models.BatchResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid request",
+                        "schema": {
+                            "$ref": "#/definitions/problem.Details"
+                        }
+                    },
+                    "401": {
+                        "description": "Missing or invalid credentials",
+                        "schema": {
+                            "$ref": "#/definitions/problem.Details"
+                        }
+                    },
+                    "403": {
+                        "description": "Missing scope",
+                        "schema": {
+                            "$ref": "#/definitions/problem.Details"
+                        }
+                    },
+                    "404": {
+                        "description": "Subscription not found (atomic mode)",
+                        "schema": {
+                            "$ref": "#/definitions/models.BatchResponse"
+                        }
+                    },
+                    "429": {
+                        "description": "Rate limit exceeded",
+                        "schema": {
+                            "$ref": "#/definitions/problem.Details"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/problem.Details"
+                        }
+                    }
+                }
+            }
+        },
+        "/subscriptions/export": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    },
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Потоковая выгрузка всех подписок, удовлетворяющих фильтрам списка, без ограничения ` + "`" + `limit` + "`" + `.\u003cbr\u003e\nПоддерживаются те же фильтры и ` + "`" + `sort` + "`" + `, что и в ` + "`" + `GET /subscriptions` + "`" + `; параметры пагинации игнорируются.\u003cbr\u003e\nВ CSV даты ` + "`" + `start_date` + "`" + ` и ` + "`" + `end_date` + "`" + ` выгружаются в формате MM-YYYY, что позволяет загрузить файл обратно через импорт.\u003cbr\u003e\n` + "`" + `created_at` + "`" + ` выгружается с полной точностью: вместе с ` + "`" + `id` + "`" + ` он образует ключ курсора ` + "`" + `GET /subscriptions` + "`" + `, по которому строки выгрузки сопоставляются со страницами списка.",
+                "produces": [
+                    "text/csv",
+                    "application/x-ndjson"
+                ],
+                "tags": [
+                    "subscriptions"
+                ],
+                "summary": "Выгрузить подписки",
+                "parameters": [
+                    {
+                        "enum": [
+                            "csv",
+                            "ndjson"
+                        ],
+                        "type": "string",
+                        "description": "Export format",
+                        "name": "format",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "User UUID",
+                        "name": "user_id",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Include archived subscriptions (default: false)",
+                        "name": "include_deleted",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Exact service name",
+                        "name": "service_name",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Service name prefix",
+                        "name": "service_name_prefix",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Minimum price (inclusive)",
+                        "name": "price_min",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Maximum price (inclusive)",
+                        "name": "price_max",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Only subscriptions active in this month (MM-YYYY)",
+                        "name": "active_at",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Minimum start_date (MM-YYYY)",
+                        "name": "start_date_from",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Maximum start_date (MM-YYYY)",
+                        "name": "start_date_to",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Minimum end_date (MM-YYYY)",
+                        "name": "end_date_from",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Maximum end_date (MM-YYYY)",
+                        "name": "end_date_to",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "true - only subscriptions without end_date, false - only with end_date",
+                        "name": "open_ended",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Sort fields: service_name, price, user_id, start_date, end_date, created_at, updated_at",
+                        "name": "sort",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Stream of subscriptions",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid parameters",
+                        "schema": {
+                            "$ref": "#/definitions/problem.Details"
+                        }
+                    },
+                    "401": {
+                        "description": "Missing or invalid credentials",
+                        "schema": {
+                            "$ref": "#/definitions/problem.Details"
+                        }
+                    },
+                    "403": {
+                        "description": "user_id of another user or missing scope",
+                        "schema": {
+                            "$ref": "#/definitions/problem.Details"
+                        }
+                    },
+                    "429": {
+                        "description": "Rate limit exceeded",
+                        "schema": {
+                            "$ref": "#/definitions/problem.Details"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/problem.Details"
+                        }
+                    }
+                }
+            }
+        },
+        "/subscriptions/import": {
+            "post": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    },
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Загружает подписки из CSV-файла с заголовком. Обязательные колонки: ` + "`" + `service_name` + "`" + `, ` + "`" + `price` + "`" + `, ` + "`" + `user_id` + "`" + `, ` + "`" + `start_date` + "`" + `; опциональные - ` + "`" + `end_date` + "`" + `, ` + "`" + `billing_period` + "`" + ` (по умолчанию ` + "`" + `month` + "`" + `) и ` + "`" + `currency` + "`" + ` (по умолчанию ` + "`" + `RUB` + "`" + `).\n` + "`" + `price` + "`" + ` - в минорных единицах валюты (копейках для ` + "`" + `RUB` + "`" + `), даты в формате MM-YYYY, прочие колонки игнорируются. Файл передается телом запроса (` + "`" + `text/csv` + "`" + `) или в поле ` + "`" + `file` + "`" + ` формы ` + "`" + `multipart/form-data` + "`" + `.\u003cbr\u003e\nВсе строки проверяются до записи. При ` + "`" + `dry_run=true` + "`" + ` или наличии ошибок ничего не сохраняется, ответ содержит ошибки с номерами строк.\nИначе все строки загружаются одной транзакцией.",
+                "consumes": [
+                    "text/csv",
+                    "multipart/form-data"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "subscriptions"
+                ],
+                "summary": "Импортировать подписки из CSV",
+                "parameters": [
+                    {
+                        "type": "boolean",
+                        "description": "Validate only, do not write (default: false)",
+                        "name": "dry_run",
+                        "in": "query"
+                    },
+                    {
+                        "type": "file",
+                        "description": "CSV file (multipart/form-data)",
+                        "name": "file",
+                        "in": "formData"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Dry run report",
+                        "schema": {
+                            "$ref": "#/definitions/models.ImportReport"
+                        }
+                    },
+                    "201": {
+                        "description": "Import completed",
+                        "schema": {
+                            "$ref": "#/definitions/models.ImportReport"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid file or rows",
+                        "schema": {
+                            "$ref": "#/definitions/models.ImportReport"
+                        }
+                    },
+                    "401": {
+                        "description": "Missing or invalid credentials",
+                        "schema": {
+                            "$ref": "#/definitions/problem.Details"
+                        }
+                    },
+                    "403": {
+                        "description": "Missing scope",
+                        "schema": {
+                            "$ref": "#/definitions/problem.Details"
+                        }
+                    },
+                    "429": {
+                        "description": "Rate limit exceeded",
+                        "schema": {
+                            "$ref": "#/definitions/problem.Details"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/problem.Details"
+                        }
+                    }
+                }
+            }
+        },
+        "/subscriptions/total": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    },
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Расчёт общей стоимости активных подписок за указанный период.\u003cbr\u003e\u003cbr\u003e\n**Логика расчёта (mode=prorated, по умолчанию):**\u003cbr\u003e\n- Подписка учитывается, если пересекается с запрошенным периодом (start_date \u003c= end_period AND (end_date IS NULL OR end_date \u003e= start_period))\u003cbr\u003e\n- Стоимость подписки = цена периода × количество списаний, даты которых попадают в запрошенный период (включительно)\u003cbr\u003e\n- Списания приходятся на ` + "`" + `start_date` + "`" + ` и далее через каждый ` + "`" + `billing_period` + "`" + `: месяц, квартал, год или неделю\u003cbr\u003e\n- Бессрочная подписка считается активной до конца запрошенного периода\u003cbr\u003e\n- Архивная (удаленная) подписка считается активной по месяц архивации включительно\u003cbr\u003e\u003cbr\u003e\n**mode=per_subscription:** каждая пересекающаяся с периодом подписка учитывается один раз, независимо от длины периода\u003cbr\u003e\u003cbr\u003e\n**group_by:** список полей через запятую (` + "`" + `service_name` + "`" + `, ` + "`" + `user_id` + "`" + `, ` + "`" + `month` + "`" + `). При указании в ответе возвращается поле ` + "`" + `groups` + "`" + ` с суммой и количеством подписок по каждой группе.\nПри группировке по ` + "`" + `month` + "`" + ` подписка учитывается в каждом месяце, в котором активна, со списаниями этого месяца; ` + "`" + `mode=per_subscription` + "`" + ` с ` + "`" + `month` + "`" + ` не совместим.\u003cbr\u003e\u003cbr\u003e\n**currency:** суммы в минорных единицах валюты ответа ` + "`" + `currency` + "`" + ` (для ` + "`" + `RUB` + "`" + ` - в копейках, а не в рублях, как раньше). Без параметра все подписки должны быть в одной валюте, иначе ответ 400 ` + "`" + `currency_required` + "`" + `.\nС параметром списания каждой валюты за месяц пересчитываются по курсу этого месяца из ` + "`" + `/admin/exchange-rates` + "`" + ` (при ` + "`" + `mode=per_subscription` + "`" + ` - по курсу первого месяца периода, в котором подписка активна); если курса нет - 400 ` + "`" + `exchange_rate_not_found` + "`" + `.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "subscriptions"
+                ],
+                "summary": "Получение общей стоимости подписок за заданный период",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "User UUID (optional - calculates total for all users if not provided)",
+                        "name": "user_id",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Format: MM-YYYY",
+                        "name": "start_date",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Format: MM-YYYY",
+                        "name": "end_date",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Service Name filter (optional)",
+                        "name": "service_name",
+                        "in": "query"
+                    },
+                    {
+                        "enum": [
+                            "prorated",
+                            "per_subscription"
+                        ],
+                        "type": "string",
+                        "description": "Calculation mode (default: prorated)",
+                        "name": "mode",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Comma-separated grouping fields: service_name, user_id, month",
+                        "name": "group_by",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "ISO 4217 currency to convert amounts into (e.g. USD)",
+                        "name": "currency",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/models.TotalCostResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid parameters",
+                        "schema": {
+                            "$ref": "#/definitions/problem.Details"
+                        }
+                    },
+                    "401": {
+                        "description": "Missing or invalid credentials",
+                        "schema": {
+                            "$ref": "#/definitions/problem.Details"
+                        }
+                    },
+                    "403": {
+                        "description": "user_id of another user or missing scope",
+                        "schema": {
+                            "$ref": "#/definitions/problem.Details"
+                        }
+                    },
+                    "404": {
+                        "description": "No subscriptions found for the specified criteria",
+                        "schema": {
+                            "$ref": "#/definitions/problem.Details"
+                        }
+                    },
+                    "429": {
+                        "description": "Rate limit exceeded",
+                        "schema": {
+                            "$ref": "#/definitions/problem.Details"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/problem.Details"
+                        }
+                    }
+                }
+            }
+        },
+        "/subscriptions/total/breakdown": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    },
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Возвращает по одной строке на каждый календарный месяц периода (включительно) с суммой списаний этого месяца и списком подписок, активных в нём.\u003cbr\u003e\nСумма месяца учитывает ` + "`" + `billing_period` + "`" + `: годовая подписка списывается только в месяцы продления, недельная - за каждую неделю, начавшуюся в месяце.\u003cbr\u003e\nПодписка активна в месяце, если её ` + "`" + `start_date` + "`" + ` не позже месяца и ` + "`" + `end_date` + "`" + ` не указан или не раньше месяца.\u003cbr\u003e\nС параметром ` + "`" + `currency` + "`" + ` суммы месяцев пересчитываются в эту валюту по курсу месяца; цены подписок в списке остаются в их валютах.\nБез параметра все подписки должны быть в одной валюте, иначе ответ 400 ` + "`" + `currency_required` + "`" + `.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "subscriptions"
+                ],
+                "summary": "Помесячная разбивка стоимости подписок за период",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "User UUID (optional - calculates total for all users if not provided)",
+                        "name": "user_id",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Format: MM-YYYY",
+                        "name": "start_date",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Format: MM-YYYY",
+                        "name": "end_date",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Service Name filter (optional)",
+                        "name": "service_name",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "ISO 4217 currency to convert amounts into (e.g. USD)",
+                        "name": "currency",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/models.CostBreakdownResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid parameters",
+                        "schema": {
+                            "$ref": "#/definitions/problem.Details"
+                        }
+                    },
+                    "401": {
+                        "description": "Missing or invalid credentials",
+                        "schema": {
+                            "$ref": "#/definitions/problem.Details"
+                        }
+                    },
+                    "403": {
+                        "description": "user_id of another user or missing scope",
+                        "schema": {
+                            "$ref": "#/definitions/problem.Details"
+                        }
+                    },
+                    "429": {
+                        "description": "Rate limit exceeded",
+                        "schema": {
+                            "$ref": "#/definitions/problem.Details"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/problem.Details"
+                        }
+                    }
+                }
+            }
+        },
+        "/subscriptions/{id}": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    },
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "subscriptions"
+                ],
+                "summary": "Получить подписку",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Subscription ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/models.SubscriptionResponse"
+                        },
+                        "headers": {
+                            "ETag": {
+                                "type": "string",
+                                "description": "Subscription version"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid ID format",
+                        "schema": {
+                            "$ref": "#/definitions/problem.Details"
+                        }
+                    },
+                    "401": {
+                        "description": "Missing or invalid credentials",
+                        "schema": {
+                            "$ref": "#/definitions/problem.Details"
+                        }
+                    },
+                    "403": {
+                        "description": "Missing scope",
+                        "schema": {
+                            "$ref": "#/definitions/problem.Details"
+                        }
+                    },
+                    "404": {
+                        "description": "Subscription not found",
+                        "schema": {
+                            "$ref": "#/definitions/problem.Details"
+                        }
+                    },
+                    "429": {
+                        "description": "Rate limit exceeded",
+                        "schema": {
+                            "$ref": "#/definitions/problem.Details"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/problem.Details"
+                        }
+                    }
+                }
+            },
+            "put": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    },
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Обновление данных подписки. Все поля опциональные. После объединения с текущей подпиской проверяется, что ` + "`" + `end_date \u003e= start_date` + "`" + `\nи что квартальная или годовая подписка длится целое число периодов ` + "`" + `billing_period` + "`" + `.\nЕсли передан заголовок ` + "`" + `If-Match` + "`" + `, обновление выполняется только при совпадении с текущим ` + "`" + `ETag` + "`" + ` подписки.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "subscriptions"
+                ],
+                "summary": "Обновить подписку",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Subscription ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "ETag of the subscription version being updated",
+                        "name": "If-Match",
+                        "in": "header"
+                    },
+                    {
+                        "description": "Subscription update info (all fields optional)",
+                        "name": "input",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/models.UpdateSubscriptionRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/models.SubscriptionResponse"
+                        },
+                        "headers": {
+                            "ETag": {
+                                "type": "string",
+                                "description": "Subscription version"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid request",
+                        "schema": {
+                            "$ref": "#/definitions/problem.Details"
+                        }
+                    },
+                    "401": {
+                        "description": "Missing or invalid credentials",
+                        "schema": {
+                            "$ref": "#/definitions/problem.Details"
+                        }
+                    },
+                    "403": {
+                        "description": "Missing scope",
+                        "schema": {
+                            "$ref": "#/definitions/problem.Details"
+                        }
+                    },
+                    "404": {
+                        "description": "Subscription not found",
+                        "schema": {
+                            "$ref": "#/definitions/problem.Details"
+                        }
+                    },
+                    "412": {
+                        "description": "Subscription has been modified",
+                        "schema": {
+                            "$ref": "#/definitions/problem.Details"
+                        }
+                    },
+                    "429": {
+                        "description": "Rate limit exceeded",
+                        "schema": {
+                            "$ref": "#/definitions/problem.Details"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/problem.Details"
+                        }
+                    }
+                }
+            },
+            "delete": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    },
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Подписка переносится в архив: она перестает возвращаться из GET и списка (без ` + "`" + `include_deleted` + "`" + `),\nно учитывается в расчете стоимости по месяц архивации включительно. Восстановить можно через ` + "`" + `POST /subscriptions/{id}/restore` + "`" + `.\u003cbr\u003e\nЕсли передан заголовок ` + "`" + `If-Match` + "`" + `, подписка удаляется только при совпадении с текущим ` + "`" + `ETag` + "`" + `.",
+                "tags": [
+                    "subscriptions"
+                ],
+                "summary": "Удалить подписку",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Subscription ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "ETag of the subscription version being deleted",
+                        "name": "If-Match",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "No Content"
+                    },
+                    "400": {
+                        "description": "Invalid ID format",
+                        "schema": {
+                            "$ref": "#/definitions/problem.Details"
+                        }
+                    },
+                    "401": {
+                        "description": "Missing or invalid credentials",
+                        "schema": {
+                            "$ref": "#/definitions/problem.Details"
+                        }
+                    },
+                    "403": {
+                        "description": "Missing scope",
+                        "schema": {
+                            "$ref": "#/definitions/problem.Details"
+                        }
+                    },
+                    "404": {
+                        "description": "Subscription not found",
+                        "schema": {
+                            "$ref": "#/definitions/problem.Details"
+                        }
+                    },
+                    "412": {
+                        "description": "Subscription has been modified",
+                        "schema": {
+                            "$ref": "#/definitions/problem.Details"
+                        }
+                    },
+                    "429": {
+                        "description": "Rate limit exceeded",
+                        "schema": {
+                            "$ref": "#/definitions/problem.Details"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/problem.Details"
+                        }
+                    }
+                }
+            }
+        },
+        "/subscriptions/{id}/restore": {
+            "post": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    },
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "subscriptions"
+                ],
+                "summary": "Восстановить подписку из архива",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Subscription ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "ETag of the archived subscription version",
+                        "name": "If-Match",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/models.SubscriptionResponse"
+                        },
+                        "headers": {
+                            "ETag": {
+                                "type": "string",
+                                "description": "Subscription version"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid ID format",
+                        "schema": {
+                            "$ref": "#/definitions/problem.Details"
+                        }
+                    },
+                    "401": {
+                        "description": "Missing or invalid credentials",
+                        "schema": {
+                            "$ref": "#/definitions/problem.Details"
+                        }
+                    },
+                    "403": {
+                        "description": "Missing scope",
+                        "schema": {
+                            "$ref": "#/definitions/problem.Details"
+                        }
+                    },
+                    "404": {
+                        "description": "Archived subscription not found",
+                        "schema": {
+                            "$ref": "#/definitions/problem.Details"
+                        }
+                    },
+                    "412": {
+                        "description": "Subscription has been modified",
+                        "schema": {
+                            "$ref": "#/definitions/problem.Details"
+                        }
+                    },
+                    "429": {
+                        "description": "Rate limit exceeded",
+                        "schema": {
+                            "$ref": "#/definitions/problem.Details"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/problem.Details"
+                        }
+                    }
+                }
+            }
+        }
+    },
+    "definitions": {
+        "errors.Violation": {
+            "type": "object",
+            "properties": {
+                "code": {
+                    "type": "string"
+                },
+                "field": {
+                    "type": "string"
+                },
+                "message": {
+                    "type": "string"
+                }
+            }
+        },
+        "models.BatchItemResult": {
+            "type": "object",
+            "properties": {
+                "code": {
+                    "type": "string"
+                },
+                "error": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "string"
+                },
+                "index": {
+                    "type": "integer"
+                },
+                "status": {
+                    "type": "integer"
+                },
+                "subscription": {
+                    "$ref": "#/definitions/models.SubscriptionResponse"
+                }
+            }
+        },
+        "models.BatchMode": {
+            "type": "string",
+            "enum": [
+                "atomic",
+                "partial"
+            ],
+            "x-enum-varnames": [
+                "BatchModeAtomic",
+                "BatchModePartial"
+            ]
+        },
+        "models.BatchResponse": {
+            "type": "object",
+            "properties": {
+                "failed": {
+                    "type": "integer"
+                },
+                "mode": {
+                    "$ref": "#/definitions/models.BatchMode"
+                },
+                "results": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.BatchItemResult"
+                    }
+                },
+                "succeeded": {
+                    "type": "integer"
+                }
+            }
+        },
+        "models.BillingPeriod": {
+            "type": "string",
+            "enum": [
+                "month",
+                "quarter",
+                "year",
+                "week"
+            ],
+            "x-enum-varnames": [
+                "BillingPeriodMonth",
+                "BillingPeriodQuarter",
+                "BillingPeriodYear",
+                "BillingPeriodWeek"
+            ]
+        },
+        "models.ComponentStatus": {
+            "type": "object",
+            "properties": {
+                "error": {
+                    "type": "string"
+                },
+                "status": {
+                    "type": "string",
+                    "example": "ok"
+                }
+            }
+        },
+        "models.CostBreakdownResponse": {
+            "type": "object",
+            "properties": {
+                "currency": {
+                    "type": "string",
+                    "example": "RUB"
+                },
+                "months": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.MonthlyCostResponse"
+                    }
+                },
+                "total_cost": {
+                    "type": "integer"
+                }
+            }
+        },
+        "models.CostGroupResponse": {
+            "type": "object",
+            "properties": {
+                "month": {
+                    "type": "string",
+                    "example": "01-2026"
+                },
+                "service_name": {
+                    "type": "string"
+                },
+                "subscriptions_count": {
+                    "type": "integer"
+                },
+                "total_cost": {
+                    "type": "integer"
+                },
+                "user_id": {
+                    "type": "string"
+                }
+            }
+        },
+        "models.CreateSubscriptionRequest": {
+            "type": "object",
+            "properties": {
+                "billing_period": {
+                    "enum": [
+                        "month",
+                        "quarter",
+                        "year",
+                        "week"
+                    ],
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/models.BillingPeriod"
+                        }
+                    ]
+                },
+                "currency": {
+                    "type": "string",
+                    "example": "RUB"
+                },
+                "end_date": {
+                    "type": "string"
+                },
+                "price": {
+                    "type": "integer"
+                },
+                "service_name": {
+                    "type": "string"
+                },
+                "start_date": {
+                    "type": "string"
+                },
+                "user_id": {
+                    "type": "string"
+                }
+            }
+        },
+        "models.ExchangeRateRequest": {
+            "type": "object",
+            "properties": {
+                "base_currency": {
+                    "type": "string",
+                    "example": "USD"
+                },
+                "month": {
+                    "type": "string",
+                    "example": "01-2026"
+                },
+                "quote_currency": {
+                    "type": "string",
+                    "example": "RUB"
+                },
+                "rate": {
+                    "type": "string",
+                    "example": "92.5"
+                }
+            }
+        },
+        "models.ExchangeRateResponse": {
+            "type": "object",
+            "properties": {
+                "base_currency": {
+                    "type": "string",
+                    "example": "USD"
+                },
+                "month": {
+                    "type": "string",
+                    "example": "01-2026"
+                },
+                "quote_currency": {
+                    "type": "string",
+                    "example": "RUB"
+                },
+                "rate": {
+                    "type": "string",
+                    "example": "92.5"
+                },
+                "updated_at": {
+                    "type": "string"
+                }
+            }
+        },
+        "models.HealthResponse": {
+            "type": "object",
+            "properties": {
+                "status": {
+                    "type": "string",
+                    "example": "ok"
+                }
+            }
+        },
+        "models.ImportLineError": {
+            "type": "object",
+            "properties": {
+                "code": {
+                    "type": "string"
+                },
+                "error": {
+                    "type": "string"
+                },
+                "line": {
+                    "type": "integer"
+                }
+            }
+        },
+        "models.ImportReport": {
+            "type": "object",
+            "properties": {
+                "dry_run": {
+                    "type": "boolean"
+                },
+                "errors": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.ImportLineError"
+                    }
+                },
+                "imported": {
+                    "type": "integer"
+                },
+                "total_rows": {
+                    "type": "integer"
+                },
+                "valid_rows": {
+                    "type": "integer"
+                }
+            }
+        },
+        "models.MonthlyCostResponse": {
+            "type": "object",
+            "properties": {
+                "month": {
+                    "type": "string",
+                    "example": "01-2026"
+                },
+                "subscriptions": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.SubscriptionResponse"
+                    }
+                },
+                "total_cost": {
+                    "type": "integer"
+                }
+            }
+        },
+        "models.PaginatedSubscriptionResponse": {
+            "type": "object",
+            "properties": {
+                "data": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.SubscriptionResponse"
+                    }
+                },
+                "has_more": {
+                    "type": "boolean"
+                },
+                "limit": {
+                    "type": "integer"
+                },
+                "next_cursor": {
+                    "type": "string"
+                },
+                "offset": {
+                    "type": "integer"
+                },
+                "total": {
+                    "type": "integer"
+                }
+            }
+        },
+        "models.PurgeArchivedResponse": {
+            "type": "object",
+            "properties": {
+                "deleted": {
+                    "type": "integer"
+                }
+            }
+        },
+        "models.ReadinessResponse": {
+            "type": "object",
+            "properties": {
+                "components": {
+                    "type": "object",
+                    "additionalProperties": {
+                        "$ref": "#/definitions/models.ComponentStatus"
+                    }
+                },
+                "status": {
+                    "type": "string",
+                    "example": "ok"
+                }
+            }
+        },
+        "models.SubscriptionResponse": {
+            "type": "object",
+            "properties": {
+                "billing_period": {
+                    "enum": [
+                        "month",
+                        "quarter",
+                        "year",
+                        "week"
+                    ],
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/models.BillingPeriod"
+                        }
+                    ]
+                },
+                "created_at": {
+                    "type": "string"
+                },
+                "currency": {
+                    "type": "string",
+                    "example": "RUB"
+                },
+                "deleted_at": {
+                    "type": "string"
+                },
+                "end_date": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "string"
+                },
+                "price": {
+                    "type": "integer"
+                },
+                "service_name": {
+                    "type": "string"
+                },
+                "start_date": {
+                    "type": "string"
+                },
+                "updated_at": {
+                    "type": "string"
+                },
+                "user_id": {
+                    "type": "string"
+                },
+                "version": {
+                    "type": "integer"
+                }
+            }
+        },
+        "models.TotalCostResponse": {
+            "type": "object",
+            "properties": {
+                "currency": {
+                    "type": "string",
+                    "example": "RUB"
+                },
+                "groups": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.CostGroupResponse"
+                    }
+                },
+                "total_cost": {
+                    "type": "integer"
+                }
+            }
+        },
+        "models.UpdateSubscriptionRequest": {
+            "type": "object",
+            "properties": {
+                "billing_period": {
+                    "enum": [
+                        "month",
+                        "quarter",
+                        "year",
+                        "week"
+                    ],
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/models.BillingPeriod"
+                        }
+                    ]
+                },
+                "currency": {
+                    "type": "string",
+                    "example": "RUB"
+                },
+                "end_date": {
+                    "type": "string"
                 },
                 "price": {
                     "type": "integer"
@@ -369,6 +2035,55 @@ const docTemplate = `{
                     "type": "string"
                 }
             }
+        },
+        "problem.Details": {
+            "type": "object",
+            "properties": {
+                "code": {
+                    "type": "string"
+                },
+                "detail": {
+                    "type": "string"
+                },
+                "error": {
+                    "type": "string"
+                },
+                "instance": {
+                    "type": "string"
+                },
+                "request_id": {
+                    "type": "string"
+                },
+                "status": {
+                    "type": "integer"
+                },
+                "title": {
+                    "type": "string"
+                },
+                "type": {
+                    "type": "string"
+                },
+                "violations": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/errors.Violation"
+                    }
+                }
+            }
+        }
+    },
+    "securityDefinitions": {
+        "ApiKeyAuth": {
+            "description": "API-ключ в формате \"ApiKey \u003ckey\u003e\"",
+            "type": "apiKey",
+            "name": "Authorization",
+            "in": "header"
+        },
+        "BearerAuth": {
+            "description": "JWT в формате \"Bearer \u003ctoken\u003e\"",
+            "type": "apiKey",
+            "name": "Authorization",
+            "in": "header"
         }
     }
 }`
@@ -0,0 +1,221 @@
+package auth
+
+import (
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"slices"
+	"time"
+
+	"github.com/Gilf4/effective-mobile-task/internal/config"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+)
+
+const (
+	algHS256 = "HS256"
+	algRS256 = "RS256"
+)
+
+// clockSkew - допустимое расхождение часов издателя токена и сервиса
+const clockSkew = 30 * time.Second
+
+var (
+	ErrInvalidToken   = errors.New("invalid token")
+	ErrInvalidSubject = errors.New("token subject must be a user id")
+	ErrInvalidAPIKey  = errors.New("invalid api key")
+)
+
+// claims - утверждения токена. Роль передается в "role" строкой или в "roles" списком
+type claims struct {
+	jwt.RegisteredClaims
+	Role  string   `json:"role,omitempty"`
+	Roles []string `json:"roles,omitempty"`
+}
+
+// verificationKey - ключ проверки подписи. Пустой id подходит к токену с любым kid
+type verificationKey struct {
+	id  string
+	alg string
+	key any
+}
+
+// Verifier проверяет JWT, подписанные HS256 или RS256, и извлекает из них автора запроса
+type Verifier struct {
+	keys      []verificationKey
+	parser    *jwt.Parser
+	adminRole string
+}
+
+// NewVerifier загружает ключи из конфигурации: общий секрет HS256, публичный ключ RS256 в PEM
+// и ключи из локального JWKS-файла
+func NewVerifier(cfg config.AuthConfig) (*Verifier, error) {
+	var keys []verificationKey
+
+	if cfg.JWTSecret != "" {
+		keys = append(keys, verificationKey{alg: algHS256, key: []byte(cfg.JWTSecret)})
+	}
+
+	if cfg.JWTPublicKeyFile != "" {
+		data, err := os.ReadFile(cfg.JWTPublicKeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read public key: %w", err)
+		}
+		key, err := jwt.ParseRSAPublicKeyFromPEM(data)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse public key %s: %w", cfg.JWTPublicKeyFile, err)
+		}
+		keys = append(keys, verificationKey{alg: algRS256, key: key})
+	}
+
+	if cfg.JWKSFile != "" {
+		jwksKeys, err := loadJWKS(cfg.JWKSFile)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, jwksKeys...)
+	}
+
+	if len(keys) == 0 {
+		return nil, errors.New("no JWT verification keys configured")
+	}
+
+	opts := []jwt.ParserOption{
+		jwt.WithValidMethods([]string{algHS256, algRS256}),
+		jwt.WithExpirationRequired(),
+		jwt.WithLeeway(clockSkew),
+	}
+	if cfg.Issuer != "" {
+		opts = append(opts, jwt.WithIssuer(cfg.Issuer))
+	}
+	if cfg.Audience != "" {
+		opts = append(opts, jwt.WithAudience(cfg.Audience))
+	}
+
+	return &Verifier{
+		keys:      keys,
+		parser:    jwt.NewParser(opts...),
+		adminRole: cfg.AdminRole,
+	}, nil
+}
+
+// Verify проверяет подпись и срок действия токена и возвращает автора запроса
+// Subject токена обычного пользователя должен быть его user_id
+func (v *Verifier) Verify(token string) (Principal, error) {
+	var c claims
+	if _, err := v.parser.ParseWithClaims(token, &c, v.keyFunc); err != nil {
+		return Principal{}, fmt.Errorf("%w: %w", ErrInvalidToken, err)
+	}
+	if c.Subject == "" {
+		return Principal{}, fmt.Errorf("%w: subject is required", ErrInvalidToken)
+	}
+
+	p := Principal{
+		Subject: c.Subject,
+		Admin:   v.adminRole != "" && (c.Role == v.adminRole || slices.Contains(c.Roles, v.adminRole)),
+	}
+
+	userID, err := uuid.Parse(c.Subject)
+	switch {
+	case err == nil:
+		p.UserID = userID
+	case !p.Admin:
+		return Principal{}, ErrInvalidSubject
+	}
+
+	return p, nil
+}
+
+// keyFunc выбирает ключ по алгоритму и kid токена
+func (v *Verifier) keyFunc(t *jwt.Token) (any, error) {
+	kid, _ := t.Header["kid"].(string)
+
+	for _, k := range v.keys {
+		if k.alg != t.Method.Alg() {
+			continue
+		}
+		if kid != "" && k.id != "" && k.id != kid {
+			continue
+		}
+		return k.key, nil
+	}
+
+	return nil, fmt.Errorf("no key for alg %s and kid %q", t.Method.Alg(), kid)
+}
+
+// jwk - ключ из JWKS (RFC 7517). Поддерживаются RSA-ключи для RS256 и симметричные (oct) для HS256
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Alg string `json:"alg"`
+	Use string `json:"use"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	K   string `json:"k"`
+}
+
+func loadJWKS(path string) ([]verificationKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read JWKS: %w", err)
+	}
+
+	var set struct {
+		Keys []jwk `json:"keys"`
+	}
+	if err := json.Unmarshal(data, &set); err != nil {
+		return nil, fmt.Errorf("failed to parse JWKS %s: %w", path, err)
+	}
+
+	var keys []verificationKey
+	for i, k := range set.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+
+		key, err := k.verificationKey()
+		if err != nil {
+			return nil, fmt.Errorf("JWKS %s: key %d: %w", path, i, err)
+		}
+		if key.key == nil {
+			continue
+		}
+		keys = append(keys, key)
+	}
+
+	return keys, nil
+}
+
+// verificationKey преобразует ключ JWKS в ключ проверки подписи
+// Ключи неподдерживаемых типов и алгоритмов возвращаются пустыми и пропускаются
+func (k jwk) verificationKey() (verificationKey, error) {
+	switch {
+	case k.Kty == "RSA" && (k.Alg == "" || k.Alg == algRS256):
+		n, err := base64.RawURLEncoding.DecodeString(k.N)
+		if err != nil {
+			return verificationKey{}, fmt.Errorf("invalid modulus: %w", err)
+		}
+		e, err := base64.RawURLEncoding.DecodeString(k.E)
+		if err != nil {
+			return verificationKey{}, fmt.Errorf("invalid exponent: %w", err)
+		}
+		exp := new(big.Int).SetBytes(e)
+		if len(n) == 0 || !exp.IsInt64() || exp.Int64() < 3 || exp.Int64() > 1<<31-1 {
+			return verificationKey{}, errors.New("invalid RSA public key")
+		}
+		key := &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(exp.Int64())}
+		return verificationKey{id: k.Kid, alg: algRS256, key: key}, nil
+	case k.Kty == "oct" && (k.Alg == "" || k.Alg == algHS256):
+		secret, err := base64.RawURLEncoding.DecodeString(k.K)
+		if err != nil || len(secret) == 0 {
+			return verificationKey{}, errors.New("invalid symmetric key")
+		}
+		return verificationKey{id: k.Kid, alg: algHS256, key: secret}, nil
+	}
+
+	return verificationKey{}, nil
+}
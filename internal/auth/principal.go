@@ -0,0 +1,36 @@
+package auth
+
+import (
+	"context"
+	"slices"
+
+	"github.com/google/uuid"
+)
+
+// Principal - аутентифицированный автор запроса
+// UserID совпадает с subject токена; у администратора subject может не быть UUID, тогда UserID равен uuid.Nil.
+// Scopes ограничивает доступные маршруты; nil означает доступ ко всем маршрутам (JWT)
+type Principal struct {
+	Subject string
+	UserID  uuid.UUID
+	Admin   bool
+	Scopes  []Scope
+}
+
+// HasScope сообщает, разрешена ли автору запроса область доступа scope
+func (p Principal) HasScope(scope Scope) bool {
+	return p.Scopes == nil || slices.Contains(p.Scopes, scope)
+}
+
+type principalKey struct{}
+
+// WithPrincipal возвращает контекст, к которому привязан автор запроса
+func WithPrincipal(ctx context.Context, p Principal) context.Context {
+	return context.WithValue(ctx, principalKey{}, p)
+}
+
+// FromContext возвращает автора запроса, если запрос прошел аутентификацию
+func FromContext(ctx context.Context) (Principal, bool) {
+	p, ok := ctx.Value(principalKey{}).(Principal)
+	return p, ok
+}
@@ -0,0 +1,20 @@
+package auth
+
+import "slices"
+
+// Scope - область доступа API-ключа
+type Scope string
+
+const (
+	ScopeSubscriptionsRead  Scope = "subscriptions:read"
+	ScopeSubscriptionsWrite Scope = "subscriptions:write"
+	ScopeReportsRead        Scope = "reports:read"
+)
+
+// Scopes - все известные области доступа
+var Scopes = []Scope{ScopeSubscriptionsRead, ScopeSubscriptionsWrite, ScopeReportsRead}
+
+// Valid сообщает, является ли s известной областью доступа
+func (s Scope) Valid() bool {
+	return slices.Contains(Scopes, s)
+}
@@ -9,25 +9,99 @@ import (
 	"github.com/ilyakaznacheev/cleanenv"
 )
 
+const (
+	StorageDB     = "db"
+	StorageMemory = "memory"
+)
+
+const (
+	DriverPostgres = "postgres"
+	DriverSQLite   = "sqlite"
+)
+
 type Config struct {
-	Env string `env:"APP_ENV" env-default:"local"`
+	Env     string `env:"APP_ENV" env-default:"local"`
+	Storage string `env:"STORAGE" env-default:"db"`
 
-	Server ServerConfig
-	DB     DBConfig
+	Server      ServerConfig
+	DB          DBConfig
+	Idempotency IdempotencyConfig
+	Admin       AdminConfig
+	Auth        AuthConfig
+	RateLimit   RateLimitConfig
 }
 
+// ServerConfig.ShutdownDelay - время между переводом /readyz в 503 и остановкой сервера
 type ServerConfig struct {
-	Port         int           `env:"SERVER_PORT" env-required:"true"`
-	ReadTimeout  time.Duration `env:"READ_TIMEOUT" env-default:"5s"`
-	WriteTimeout time.Duration `env:"WRITE_TIMEOUT" env-default:"10s"`
+	Port          int           `env:"SERVER_PORT" env-required:"true"`
+	ReadTimeout   time.Duration `env:"READ_TIMEOUT" env-default:"5s"`
+	WriteTimeout  time.Duration `env:"WRITE_TIMEOUT" env-default:"10s"`
+	ShutdownDelay time.Duration `env:"SHUTDOWN_DELAY" env-default:"5s"`
 }
 
+// DBConfig обязателен только при STORAGE=db
+// Для DB_DRIVER=sqlite используется только Path, для postgres - остальные поля.
+// AutoMigrate применяет встроенные миграции Postgres при запуске приложения
 type DBConfig struct {
-	Host     string `env:"DB_HOST" env-required:"true"`
-	Port     int    `env:"DB_PORT" env-default:"5432"`
-	User     string `env:"DB_USER" env-required:"true"`
-	Password string `env:"DB_PASSWORD" env-required:"true"`
-	DBName   string `env:"DB_NAME" env-required:"true"`
+	Driver      string `env:"DB_DRIVER" env-default:"postgres"`
+	Path        string `env:"DB_PATH" env-default:"subscriptions.db"`
+	Host        string `env:"DB_HOST"`
+	Port        int    `env:"DB_PORT" env-default:"5432"`
+	User        string `env:"DB_USER"`
+	Password    string `env:"DB_PASSWORD"`
+	DBName      string `env:"DB_NAME"`
+	AutoMigrate bool   `env:"DB_AUTO_MIGRATE" env-default:"false"`
+}
+
+// IdempotencyConfig.Lease - сколько запрос владеет ключом идемпотентности; по истечении аренды
+// незавершенный резерв считается брошенным и повторный запрос с тем же ключом может его занять
+type IdempotencyConfig struct {
+	TTL             time.Duration `env:"IDEMPOTENCY_TTL" env-default:"24h"`
+	Lease           time.Duration `env:"IDEMPOTENCY_LEASE" env-default:"30s"`
+	CleanupInterval time.Duration `env:"IDEMPOTENCY_CLEANUP_INTERVAL" env-default:"1h"`
+}
+
+type AdminConfig struct {
+	Token string `env:"ADMIN_TOKEN"`
+}
+
+// AuthConfig - ключи и ограничения проверки JWT и включение API-ключей
+// Ключи задаются общим секретом HS256, файлом публичного ключа RS256 в формате PEM
+// и/или локальным JWKS-файлом. Токены с ролью AdminRole видят подписки всех пользователей.
+// Если не задан ни один ключ JWT и API-ключи выключены, приложение не запускается.
+// Disabled явно отключает аутентификацию для локальной разработки; в APP_ENV=prod он запрещен
+type AuthConfig struct {
+	Disabled         bool   `env:"AUTH_DISABLED" env-default:"false"`
+	JWTSecret        string `env:"JWT_SECRET"`
+	JWTPublicKeyFile string `env:"JWT_PUBLIC_KEY_FILE"`
+	JWKSFile         string `env:"JWT_JWKS_FILE"`
+	Issuer           string `env:"JWT_ISSUER"`
+	Audience         string `env:"JWT_AUDIENCE"`
+	AdminRole        string `env:"JWT_ADMIN_ROLE" env-default:"admin"`
+	APIKeysEnabled   bool   `env:"API_KEYS_ENABLED" env-default:"false"`
+}
+
+// JWTEnabled сообщает, задан ли хотя бы один ключ проверки JWT
+func (c AuthConfig) JWTEnabled() bool {
+	return c.JWTSecret != "" || c.JWTPublicKeyFile != "" || c.JWKSFile != ""
+}
+
+// Enabled сообщает, включен ли хотя бы один способ аутентификации
+func (c AuthConfig) Enabled() bool {
+	return c.JWTEnabled() || c.APIKeysEnabled
+}
+
+// RateLimitConfig - ограничения частоты запросов на клиента по группам маршрутов подписок:
+// Read - чтение подписок, Write - изменение, Reports - расчет стоимости.
+// Каждая группа допускает указанное число запросов за Period; 0 снимает ограничение группы.
+// IP - общий лимит на IP-адрес для всех маршрутов подписок, проверяется до аутентификации
+type RateLimitConfig struct {
+	Enabled bool          `env:"RATE_LIMIT_ENABLED" env-default:"false"`
+	Period  time.Duration `env:"RATE_LIMIT_PERIOD" env-default:"1m"`
+	IP      int           `env:"RATE_LIMIT_IP" env-default:"600"`
+	Read    int           `env:"RATE_LIMIT_READ" env-default:"300"`
+	Write   int           `env:"RATE_LIMIT_WRITE" env-default:"60"`
+	Reports int           `env:"RATE_LIMIT_REPORTS" env-default:"30"`
 }
 
 func MustLoad() *Config {
@@ -45,6 +119,25 @@ func MustLoad() *Config {
 		panic("failes to read config" + err.Error())
 	}
 
+	switch cfg.Storage {
+	case StorageDB:
+		switch cfg.DB.Driver {
+		case DriverPostgres:
+			if cfg.DB.Host == "" || cfg.DB.User == "" || cfg.DB.Password == "" || cfg.DB.DBName == "" {
+				panic("DB_HOST, DB_USER, DB_PASSWORD and DB_NAME are required for DB_DRIVER=postgres")
+			}
+		case DriverSQLite:
+			if cfg.DB.Path == "" {
+				panic("DB_PATH is required for DB_DRIVER=sqlite")
+			}
+		default:
+			panic("unknown DB_DRIVER " + cfg.DB.Driver)
+		}
+	case StorageMemory:
+	default:
+		panic("unknown STORAGE " + cfg.Storage)
+	}
+
 	return &cfg
 }
 
@@ -63,6 +156,7 @@ func fetchConfigPath() string {
 
 func (c Config) LogValue() slog.Value {
 	return slog.GroupValue(
+		slog.String("storage", c.Storage),
 		slog.Any("server", c.Server),
 		slog.Any("db", c.DB),
 	)
@@ -1,14 +1,43 @@
 package errors
 
 import (
+	"errors"
 	"fmt"
 	"net/http"
+	"strings"
 )
 
+// Стабильные коды ошибок по умолчанию. Клиенты сравнивают коды, а не текст сообщений
+const (
+	CodeBadRequest         = "bad_request"
+	CodeValidationFailed   = "validation_failed"
+	CodeUnauthorized       = "unauthorized"
+	CodeForbidden          = "forbidden"
+	CodeNotFound           = "not_found"
+	CodeConflict           = "conflict"
+	CodePreconditionFailed = "precondition_failed"
+	CodeTooManyRequests    = "too_many_requests"
+	CodeInternal           = "internal_error"
+)
+
+// typeBaseURI - префикс URI типа проблемы (RFC 7807), к которому добавляется код ошибки
+const typeBaseURI = "/problems/"
+
+// AppError - ошибка приложения с HTTP-статусом (Code) и стабильным машиночитаемым кодом (ErrorCode)
+// Violations заполняется для ошибок валидации и перечисляет нарушения по полям запроса
 type AppError struct {
-	Code    int
-	Message string
-	Err     error
+	Code       int
+	ErrorCode  string
+	Message    string
+	Violations []Violation
+	Err        error
+}
+
+// Violation - нарушение правила валидации одного поля запроса
+type Violation struct {
+	Field   string `json:"field"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
 }
 
 func (e *AppError) Error() string {
@@ -21,31 +50,129 @@ func (e *AppError) Unwrap() error {
 
 func (e *AppError) String() string {
 	if e.Err != nil {
-		return fmt.Sprintf("code=%d message=%s err=%v", e.Code, e.Message, e.Err)
+		return fmt.Sprintf("code=%d error_code=%s message=%s err=%v", e.Code, e.ErrorCode, e.Message, e.Err)
 	}
-	return fmt.Sprintf("code=%d message=%s", e.Code, e.Message)
+	return fmt.Sprintf("code=%d error_code=%s message=%s", e.Code, e.ErrorCode, e.Message)
+}
+
+// Type возвращает URI типа проблемы, соответствующий коду ошибки
+func (e *AppError) Type() string {
+	return typeBaseURI + e.ErrorCode
 }
 
 func NewBadRequest(message string, err error) *AppError {
+	return newAppError(http.StatusBadRequest, CodeBadRequest, message, err)
+}
+
+func NewUnauthorized(message string, err error) *AppError {
+	return newAppError(http.StatusUnauthorized, CodeUnauthorized, message, err)
+}
+
+func NewForbidden(message string, err error) *AppError {
+	return newAppError(http.StatusForbidden, CodeForbidden, message, err)
+}
+
+func NewNotFound(message string, err error) *AppError {
+	return newAppError(http.StatusNotFound, CodeNotFound, message, err)
+}
+
+func NewConflict(message string, err error) *AppError {
+	return newAppError(http.StatusConflict, CodeConflict, message, err)
+}
+
+func NewPreconditionFailed(message string, err error) *AppError {
+	return newAppError(http.StatusPreconditionFailed, CodePreconditionFailed, message, err)
+}
+
+func NewTooManyRequests(message string, err error) *AppError {
+	return newAppError(http.StatusTooManyRequests, CodeTooManyRequests, message, err)
+}
+
+func NewInternal(err error) *AppError {
 	return &AppError{
-		Code:    http.StatusBadRequest,
-		Message: message,
-		Err:     err,
+		Code:      http.StatusInternalServerError,
+		ErrorCode: CodeInternal,
+		Message:   "Internal server error",
+		Err:       err,
 	}
 }
 
-func NewNotFound(message string, err error) *AppError {
+// NewValidation возвращает ошибку 400 со списком нарушений по полям
+// Сообщение ошибки объединяет сообщения всех нарушений
+func NewValidation(violations []Violation, err error) *AppError {
+	messages := make([]string, len(violations))
+	for i, v := range violations {
+		messages[i] = v.Message
+	}
+
 	return &AppError{
-		Code:    http.StatusNotFound,
-		Message: message,
-		Err:     err,
+		Code:       http.StatusBadRequest,
+		ErrorCode:  CodeValidationFailed,
+		Message:    strings.Join(messages, "; "),
+		Violations: violations,
+		Err:        err,
 	}
 }
 
-func NewInternal(err error) *AppError {
+// newAppError создает ошибку с кодом из цепочки err, если он там есть, иначе с defaultCode
+func newAppError(status int, defaultCode, message string, err error) *AppError {
 	return &AppError{
-		Code:    http.StatusInternalServerError,
-		Message: "Internal server error",
-		Err:     err,
+		Code:      status,
+		ErrorCode: codeOf(err, defaultCode),
+		Message:   message,
+		Err:       err,
+	}
+}
+
+// CodedError - ошибка-эталон со стабильным кодом, например ошибка валидации из пакета models
+type CodedError struct {
+	Code    string
+	Message string
+}
+
+// Coded создает ошибку-эталон с кодом code
+func Coded(code, message string) *CodedError {
+	return &CodedError{Code: code, Message: message}
+}
+
+func (e *CodedError) Error() string {
+	return e.Message
+}
+
+func (e *CodedError) ErrorCode() string {
+	return e.Code
+}
+
+func codeOf(err error, defaultCode string) string {
+	var coded interface{ ErrorCode() string }
+	if err != nil && errors.As(err, &coded) {
+		return coded.ErrorCode()
+	}
+	return defaultCode
+}
+
+// Validation накапливает нарушения по полям, чтобы сообщить клиенту обо всех сразу
+// Нулевое значение готово к использованию
+type Validation struct {
+	violations []Violation
+	errs       []error
+}
+
+// Add добавляет нарушение поля field. Код нарушения берется из цепочки err
+func (v *Validation) Add(field string, err error) {
+	v.violations = append(v.violations, Violation{
+		Field:   field,
+		Code:    codeOf(err, "invalid"),
+		Message: err.Error(),
+	})
+	v.errs = append(v.errs, err)
+}
+
+// Err возвращает ошибку валидации со всеми нарушениями или nil, если нарушений нет
+// Исходные ошибки доступны через errors.Is
+func (v *Validation) Err() error {
+	if len(v.violations) == 0 {
+		return nil
 	}
+	return NewValidation(v.violations, errors.Join(v.errs...))
 }
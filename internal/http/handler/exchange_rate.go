@@ -0,0 +1,118 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+
+	apperrors "github.com/Gilf4/effective-mobile-task/internal/errors"
+	"github.com/Gilf4/effective-mobile-task/internal/models"
+)
+
+// @Summary Список курсов валют
+// @Description Возвращает курсы валютных пар по месяцам. Курс действует с указанного месяца до следующего курса той же пары. Требует заголовок `X-Admin-Token`.
+// @Tags admin
+// @Produce json
+// @Param X-Admin-Token header string true "Admin token"
+// @Param base_currency query string false "Base currency filter (ISO 4217)"
+// @Param quote_currency query string false "Quote currency filter (ISO 4217)"
+// @Success 200 {array} models.ExchangeRateResponse
+// @Failure 400 {object} problem.Details "Invalid parameters"
+// @Failure 403 {object} problem.Details "Admin access required"
+// @Failure 500 {object} problem.Details "Internal server error"
+// @Router /admin/exchange-rates [get]
+func (h *Handler) ListExchangeRates(w http.ResponseWriter, r *http.Request) {
+	rates, err := h.service.ListExchangeRates(r.Context(), r.URL.Query().Get("base_currency"), r.URL.Query().Get("quote_currency"))
+	if err != nil {
+		h.handleError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(rates)
+}
+
+// @Summary Загрузить курсы валют
+// @Description Сохраняет курсы из массива одной транзакцией: курс той же пары на тот же месяц заменяется. Требует заголовок `X-Admin-Token`.<br>
+// @Description `rate` - сколько единиц `quote_currency` стоит одна единица `base_currency`, строкой в десятичной записи не более чем с 8 знаками после запятой.
+// @Description Для пересчета используется курс пары в любом направлении, действующий в месяце списания.
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Param X-Admin-Token header string true "Admin token"
+// @Param input body []models.ExchangeRateRequest true "Exchange rates"
+// @Success 200 {array} models.ExchangeRateResponse
+// @Failure 400 {object} problem.Details "Invalid request body or rates"
+// @Failure 403 {object} problem.Details "Admin access required"
+// @Failure 500 {object} problem.Details "Internal server error"
+// @Router /admin/exchange-rates [post]
+func (h *Handler) UpsertExchangeRates(w http.ResponseWriter, r *http.Request) {
+	var reqs []models.ExchangeRateRequest
+	if err := json.NewDecoder(r.Body).Decode(&reqs); err != nil {
+		h.handleError(w, r, apperrors.NewBadRequest(errInvalidBody.Error(), errors.Join(errInvalidBody, err)))
+		return
+	}
+
+	h.log.InfoContext(r.Context(), "upserting exchange rates", slog.Int("count", len(reqs)))
+
+	rates, err := h.service.UpsertExchangeRates(r.Context(), reqs)
+	if err != nil {
+		h.handleError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(rates)
+}
+
+// @Summary Импортировать курсы валют из CSV
+// @Description Загружает курсы из CSV-файла с колонками `base_currency`, `quote_currency`, `month` (MM-YYYY) и `rate`; прочие колонки игнорируются. Требует заголовок `X-Admin-Token`.<br>
+// @Description Файл передается телом запроса (`text/csv`) или в поле `file` формы `multipart/form-data`. Все строки проверяются до записи:
+// @Description при `dry_run=true` или наличии ошибок ничего не сохраняется, иначе курсы сохраняются одной транзакцией с заменой существующих.
+// @Tags admin
+// @Accept text/csv
+// @Accept multipart/form-data
+// @Produce json
+// @Param X-Admin-Token header string true "Admin token"
+// @Param dry_run query boolean false "Validate only, do not write (default: false)"
+// @Param file formData file false "CSV file (multipart/form-data)"
+// @Success 200 {object} models.ImportReport "Dry run report"
+// @Success 201 {object} models.ImportReport "Import completed"
+// @Failure 400 {object} models.ImportReport "Invalid file or rows"
+// @Failure 403 {object} problem.Details "Admin access required"
+// @Failure 500 {object} problem.Details "Internal server error"
+// @Router /admin/exchange-rates/import [post]
+func (h *Handler) ImportExchangeRates(w http.ResponseWriter, r *http.Request) {
+	h.importCSV(w, r, "exchange rates", h.service.ImportExchangeRatesCSV)
+}
+
+// @Summary Удалить курс валют
+// @Description Удаляет курс пары на месяц. Требует заголовок `X-Admin-Token`.
+// @Tags admin
+// @Param X-Admin-Token header string true "Admin token"
+// @Param base path string true "Base currency (ISO 4217)"
+// @Param quote path string true "Quote currency (ISO 4217)"
+// @Param month path string true "Format: MM-YYYY"
+// @Success 204 "No Content"
+// @Failure 400 {object} problem.Details "Invalid parameters"
+// @Failure 403 {object} problem.Details "Admin access required"
+// @Failure 404 {object} problem.Details "Exchange rate not found"
+// @Failure 500 {object} problem.Details "Internal server error"
+// @Router /admin/exchange-rates/{base}/{quote}/{month} [delete]
+func (h *Handler) DeleteExchangeRate(w http.ResponseWriter, r *http.Request) {
+	base, quote, month := r.PathValue("base"), r.PathValue("quote"), r.PathValue("month")
+
+	h.log.InfoContext(r.Context(), "deleting exchange rate",
+		slog.String("base_currency", base),
+		slog.String("quote_currency", quote),
+		slog.String("month", month),
+	)
+
+	if err := h.service.DeleteExchangeRate(r.Context(), base, quote, month); err != nil {
+		h.handleError(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
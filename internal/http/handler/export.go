@@ -0,0 +1,184 @@
+package handler
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	apperrors "github.com/Gilf4/effective-mobile-task/internal/errors"
+	"github.com/Gilf4/effective-mobile-task/internal/models"
+)
+
+// exportFlushEvery - через сколько строк выгрузка отправляется клиенту
+const exportFlushEvery = 500
+
+var errInvalidExportFormat = apperrors.Coded("invalid_export_format", "format must be one of: csv, ndjson")
+
+var exportCSVHeader = []string{
+	"id", "service_name", "price", "currency", "billing_period", "user_id", "start_date", "end_date",
+	"version", "created_at", "updated_at", "deleted_at",
+}
+
+// @Summary Выгрузить подписки
+// @Description Потоковая выгрузка всех подписок, удовлетворяющих фильтрам списка, без ограничения `limit`.<br>
+// @Description Поддерживаются те же фильтры и `sort`, что и в `GET /subscriptions`; параметры пагинации игнорируются.<br>
+// @Description В CSV даты `start_date` и `end_date` выгружаются в формате MM-YYYY, что позволяет загрузить файл обратно через импорт.<br>
+// @Description `created_at` выгружается с полной точностью: вместе с `id` он образует ключ курсора `GET /subscriptions`, по которому строки выгрузки сопоставляются со страницами списка.
+// @Tags subscriptions
+// @Produce text/csv
+// @Produce application/x-ndjson
+// @Param format query string true "Export format" Enums(csv, ndjson)
+// @Param user_id query string false "User UUID"
+// @Param include_deleted query boolean false "Include archived subscriptions (default: false)"
+// @Param service_name query string false "Exact service name"
+// @Param service_name_prefix query string false "Service name prefix"
+// @Param price_min query integer false "Minimum price (inclusive)"
+// @Param price_max query integer false "Maximum price (inclusive)"
+// @Param active_at query string false "Only subscriptions active in this month (MM-YYYY)"
+// @Param start_date_from query string false "Minimum start_date (MM-YYYY)"
+// @Param start_date_to query string false "Maximum start_date (MM-YYYY)"
+// @Param end_date_from query string false "Minimum end_date (MM-YYYY)"
+// @Param end_date_to query string false "Maximum end_date (MM-YYYY)"
+// @Param open_ended query boolean false "true - only subscriptions without end_date, false - only with end_date"
+// @Param sort query string false "Sort fields: service_name, price, user_id, start_date, end_date, created_at, updated_at"
+// @Success 200 {string} string "Stream of subscriptions"
+// @Failure 400 {object} problem.Details "Invalid parameters"
+// @Failure 401 {object} problem.Details "Missing or invalid credentials"
+// @Failure 403 {object} problem.Details "user_id of another user or missing scope"
+// @Failure 429 {object} problem.Details "Rate limit exceeded"
+// @Failure 500 {object} problem.Details "Internal server error"
+// @Security BearerAuth
+// @Security ApiKeyAuth
+// @Router /subscriptions/export [get]
+func (h *Handler) ExportSubscriptions(w http.ResponseWriter, r *http.Request) {
+	format := r.URL.Query().Get("format")
+	if format != "csv" && format != "ndjson" {
+		h.handleError(w, r, fieldError("format", errInvalidExportFormat))
+		return
+	}
+
+	req, err := parseListQuery(r)
+	if err != nil {
+		h.handleError(w, r, err)
+		return
+	}
+	if err := req.ValidateFilters(); err != nil {
+		h.handleError(w, r, err)
+		return
+	}
+
+	h.log.InfoContext(r.Context(), "exporting subscriptions",
+		slog.String("format", format),
+		slog.String("user_id", r.URL.Query().Get("user_id")),
+		slog.Bool("include_deleted", req.IncludeDeleted),
+	)
+
+	// выгрузка может длиться дольше WriteTimeout сервера
+	rc := http.NewResponseController(w)
+	if err := rc.SetWriteDeadline(time.Time{}); err != nil {
+		h.log.WarnContext(r.Context(), "failed to reset write deadline for export", "error", err)
+	}
+
+	out := &exportWriter{w: w}
+	var (
+		write func(*models.SubscriptionResponse) error
+		flush func() error
+	)
+
+	switch format {
+	case "csv":
+		cw := csv.NewWriter(out)
+		if err := cw.Write(exportCSVHeader); err != nil {
+			h.handleError(w, r, err)
+			return
+		}
+		w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+		w.Header().Set("Content-Disposition", `attachment; filename="subscriptions.csv"`)
+		write = func(sub *models.SubscriptionResponse) error {
+			return cw.Write(subscriptionCSVRecord(sub))
+		}
+		flush = func() error {
+			cw.Flush()
+			return cw.Error()
+		}
+	default:
+		enc := json.NewEncoder(out)
+		w.Header().Set("Content-Type", "application/x-ndjson")
+		write = func(sub *models.SubscriptionResponse) error {
+			return enc.Encode(sub)
+		}
+		flush = func() error { return nil }
+	}
+
+	count := 0
+	err = h.service.ExportSubscriptions(r.Context(), req, func(sub *models.SubscriptionResponse) error {
+		if err := write(sub); err != nil {
+			return err
+		}
+		count++
+		if count%exportFlushEvery == 0 {
+			if err := flush(); err != nil {
+				return err
+			}
+			return rc.Flush()
+		}
+		return nil
+	})
+	if err == nil {
+		err = flush()
+	}
+	if err != nil && !out.started {
+		// клиенту еще ничего не отправлено, поэтому вместо пустого файла можно вернуть ошибку
+		w.Header().Del("Content-Disposition")
+		h.handleError(w, r, err)
+		return
+	}
+	if err != nil {
+		// заголовки и часть строк уже отправлены, поэтому ошибку можно только залогировать
+		h.log.ErrorContext(r.Context(), "export failed", "error", err, slog.Int("rows", count))
+		return
+	}
+
+	h.log.InfoContext(r.Context(), "export completed", slog.Int("rows", count))
+}
+
+func subscriptionCSVRecord(sub *models.SubscriptionResponse) []string {
+	record := []string{
+		sub.ID.String(),
+		sub.ServiceName,
+		strconv.Itoa(sub.Price),
+		sub.Currency,
+		string(sub.BillingPeriod),
+		sub.UserID.String(),
+		sub.StartDate.Format(models.DateLayout),
+		"",
+		strconv.Itoa(sub.Version),
+		// с полной точностью, чтобы строку можно было сопоставить с курсором списка (created_at, id)
+		sub.CreatedAt.Format(time.RFC3339Nano),
+		sub.UpdatedAt.Format(time.RFC3339),
+		"",
+	}
+	if sub.EndDate != nil {
+		record[7] = sub.EndDate.Format(models.DateLayout)
+	}
+	if sub.DeletedAt != nil {
+		record[11] = sub.DeletedAt.Format(time.RFC3339)
+	}
+	return record
+}
+
+// exportWriter запоминает, было ли что-то записано в ответ: после первой записи
+// статус 200 уже отправлен и вернуть клиенту ошибку нельзя
+type exportWriter struct {
+	w       io.Writer
+	started bool
+}
+
+func (e *exportWriter) Write(p []byte) (int, error) {
+	e.started = true
+	return e.w.Write(p)
+}
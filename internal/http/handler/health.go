@@ -0,0 +1,95 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"github.com/Gilf4/effective-mobile-task/internal/models"
+)
+
+// readinessTimeout ограничивает время одной проверки готовности
+const readinessTimeout = 2 * time.Second
+
+// ReadinessCheck - проверка зависимости, без которой приложение не может обслуживать запросы
+type ReadinessCheck struct {
+	Name  string
+	Check func(ctx context.Context) error
+}
+
+type HealthHandler struct {
+	checks       []ReadinessCheck
+	shuttingDown atomic.Bool
+	log          *slog.Logger
+}
+
+func NewHealthHandler(checks []ReadinessCheck, log *slog.Logger) *HealthHandler {
+	return &HealthHandler{checks: checks, log: log}
+}
+
+// SetShuttingDown переводит /readyz в состояние 503, чтобы балансировщик
+// перестал направлять трафик до остановки сервера
+func (h *HealthHandler) SetShuttingDown() {
+	h.shuttingDown.Store(true)
+}
+
+func (h *HealthHandler) RegisterRoutes(mux *http.ServeMux) {
+	mux.HandleFunc("GET /healthz", h.Healthz)
+	mux.HandleFunc("GET /readyz", h.Readyz)
+}
+
+// @Summary Проверка жизнеспособности
+// @Description Возвращает 200, пока процесс запущен и обрабатывает HTTP-запросы. Зависимости не проверяются.
+// @Tags health
+// @Produce json
+// @Success 200 {object} models.HealthResponse
+// @Router /healthz [get]
+func (h *HealthHandler) Healthz(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(models.HealthResponse{Status: models.HealthStatusOK})
+}
+
+// @Summary Проверка готовности
+// @Description Проверяет зависимости приложения (база данных, миграции) и возвращает статус каждой из них.<br>
+// @Description Во время остановки сервера всегда возвращает 503.
+// @Tags health
+// @Produce json
+// @Success 200 {object} models.ReadinessResponse
+// @Failure 503 {object} models.ReadinessResponse
+// @Router /readyz [get]
+func (h *HealthHandler) Readyz(w http.ResponseWriter, r *http.Request) {
+	resp := models.ReadinessResponse{
+		Status:     models.HealthStatusOK,
+		Components: make(map[string]models.ComponentStatus, len(h.checks)),
+	}
+
+	for _, c := range h.checks {
+		ctx, cancel := context.WithTimeout(r.Context(), readinessTimeout)
+		err := c.Check(ctx)
+		cancel()
+
+		if err != nil {
+			h.log.WarnContext(r.Context(), "readiness check failed", slog.String("component", c.Name), "err", err)
+			resp.Status = models.HealthStatusFail
+			resp.Components[c.Name] = models.ComponentStatus{Status: models.HealthStatusFail, Error: err.Error()}
+			continue
+		}
+		resp.Components[c.Name] = models.ComponentStatus{Status: models.HealthStatusOK}
+	}
+
+	if h.shuttingDown.Load() {
+		resp.Status = models.HealthStatusShuttingDown
+	}
+
+	status := http.StatusOK
+	if resp.Status != models.HealthStatusOK {
+		status = http.StatusServiceUnavailable
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(resp)
+}
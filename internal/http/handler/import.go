@@ -0,0 +1,97 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"mime"
+	"net/http"
+	"strconv"
+
+	apperrors "github.com/Gilf4/effective-mobile-task/internal/errors"
+	"github.com/Gilf4/effective-mobile-task/internal/models"
+)
+
+// maxImportSize - максимальный размер загружаемого CSV-файла
+const maxImportSize = 32 << 20
+
+// @Summary Импортировать подписки из CSV
+// @Description Загружает подписки из CSV-файла с заголовком. Обязательные колонки: `service_name`, `price`, `user_id`, `start_date`; опциональные - `end_date`, `billing_period` (по умолчанию `month`) и `currency` (по умолчанию `RUB`).
+// @Description `price` - в минорных единицах валюты (копейках для `RUB`), даты в формате MM-YYYY, прочие колонки игнорируются. Файл передается телом запроса (`text/csv`) или в поле `file` формы `multipart/form-data`.<br>
+// @Description Все строки проверяются до записи. При `dry_run=true` или наличии ошибок ничего не сохраняется, ответ содержит ошибки с номерами строк.
+// @Description Иначе все строки загружаются одной транзакцией.
+// @Tags subscriptions
+// @Accept text/csv
+// @Accept multipart/form-data
+// @Produce json
+// @Param dry_run query boolean false "Validate only, do not write (default: false)"
+// @Param file formData file false "CSV file (multipart/form-data)"
+// @Success 200 {object} models.ImportReport "Dry run report"
+// @Success 201 {object} models.ImportReport "Import completed"
+// @Failure 400 {object} models.ImportReport "Invalid file or rows"
+// @Failure 401 {object} problem.Details "Missing or invalid credentials"
+// @Failure 403 {object} problem.Details "Missing scope"
+// @Failure 429 {object} problem.Details "Rate limit exceeded"
+// @Failure 500 {object} problem.Details "Internal server error"
+// @Security BearerAuth
+// @Security ApiKeyAuth
+// @Router /subscriptions/import [post]
+func (h *Handler) ImportSubscriptions(w http.ResponseWriter, r *http.Request) {
+	h.importCSV(w, r, "subscriptions", h.service.ImportSubscriptionsCSV)
+}
+
+// importCSV загружает CSV из тела запроса или поля file формы функцией load
+// и отвечает отчетом: 400 при ошибках строк, 201 после записи, 200 при dry_run
+func (h *Handler) importCSV(w http.ResponseWriter, r *http.Request, resource string, load func(ctx context.Context, r io.Reader, dryRun bool) (*models.ImportReport, error)) {
+	dryRun := false
+	if v := r.URL.Query().Get("dry_run"); v != "" {
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			h.handleError(w, r, fieldError("dry_run", invalidFormat("dry_run must be a boolean")))
+			return
+		}
+		dryRun = b
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxImportSize)
+
+	body := io.Reader(r.Body)
+	if mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); mediaType == "multipart/form-data" {
+		file, _, err := r.FormFile("file")
+		if err != nil {
+			h.handleError(w, r, apperrors.NewBadRequest("file is required", err))
+			return
+		}
+		defer file.Close()
+		body = file
+	}
+
+	h.log.InfoContext(r.Context(), "importing "+resource, slog.Bool("dry_run", dryRun))
+
+	report, err := load(r.Context(), body, dryRun)
+	if err != nil {
+		h.handleError(w, r, err)
+		return
+	}
+
+	status := http.StatusOK
+	switch {
+	case len(report.Errors) > 0:
+		status = http.StatusBadRequest
+	case !dryRun:
+		status = http.StatusCreated
+	}
+
+	h.log.InfoContext(r.Context(), "import completed",
+		slog.String("resource", resource),
+		slog.Bool("dry_run", dryRun),
+		slog.Int("total_rows", report.TotalRows),
+		slog.Int("imported", report.Imported),
+		slog.Int("errors", len(report.Errors)),
+	)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(report)
+}
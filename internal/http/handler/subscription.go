@@ -4,22 +4,47 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
+	"io"
 	"log/slog"
 	"net/http"
 	"strconv"
+	"strings"
+	"time"
 
+	"github.com/Gilf4/effective-mobile-task/internal/auth"
 	apperrors "github.com/Gilf4/effective-mobile-task/internal/errors"
+	"github.com/Gilf4/effective-mobile-task/internal/http/middleware"
+	"github.com/Gilf4/effective-mobile-task/internal/http/problem"
 	"github.com/Gilf4/effective-mobile-task/internal/models"
 	"github.com/google/uuid"
 	httpSwagger "github.com/swaggo/http-swagger"
 )
 
+var (
+	errInvalidBody = apperrors.Coded("invalid_body", "invalid request body")
+	errInvalidID   = apperrors.Coded("invalid_id", "invalid id format")
+)
+
 type SubscriptionService interface {
 	CreateSubscription(ctx context.Context, req models.CreateSubscriptionRequest) (*models.SubscriptionResponse, error)
-	UpdateSubscription(ctx context.Context, id uuid.UUID, req models.UpdateSubscriptionRequest) (*models.SubscriptionResponse, error)
-	DeleteSubscription(ctx context.Context, id uuid.UUID) error
+	CreateSubscriptionIdempotent(ctx context.Context, key string, req models.CreateSubscriptionRequest) (*models.SubscriptionResponse, bool, error)
+	GetSubscription(ctx context.Context, id uuid.UUID) (*models.SubscriptionResponse, error)
+	UpdateSubscription(ctx context.Context, id uuid.UUID, req models.UpdateSubscriptionRequest, expectedVersion *int) (*models.SubscriptionResponse, error)
+	DeleteSubscription(ctx context.Context, id uuid.UUID, expectedVersion *int) error
+	RestoreSubscription(ctx context.Context, id uuid.UUID, expectedVersion *int) (*models.SubscriptionResponse, error)
+	PurgeArchived(ctx context.Context, olderThanDays int) (*models.PurgeArchivedResponse, error)
+	CreateSubscriptionsBatch(ctx context.Context, reqs []models.CreateSubscriptionRequest, mode models.BatchMode) (*models.BatchResponse, error)
+	ArchiveSubscriptionsBatch(ctx context.Context, ids []uuid.UUID, mode models.BatchMode) (*models.BatchResponse, error)
+	ExportSubscriptions(ctx context.Context, req models.ListSubscriptionsRequest, fn func(*models.SubscriptionResponse) error) error
+	ImportSubscriptionsCSV(ctx context.Context, r io.Reader, dryRun bool) (*models.ImportReport, error)
 	ListSubscriptions(ctx context.Context, req models.ListSubscriptionsRequest) (*models.PaginatedSubscriptionResponse, error)
-	CalculateTotal(ctx context.Context, userID *uuid.UUID, serviceName string, startStr, endStr string) (int, error)
+	CalculateTotal(ctx context.Context, userID *uuid.UUID, serviceName string, startStr, endStr string, mode models.TotalCostMode, currency string) (*models.TotalCostResponse, error)
+	CalculateGroupedTotal(ctx context.Context, userID *uuid.UUID, serviceName string, startStr, endStr string, mode models.TotalCostMode, groupBy []models.CostGroupBy, currency string) (*models.TotalCostResponse, error)
+	CalculateBreakdown(ctx context.Context, userID *uuid.UUID, serviceName string, startStr, endStr string, currency string) (*models.CostBreakdownResponse, error)
+	ListExchangeRates(ctx context.Context, base, quote string) ([]models.ExchangeRateResponse, error)
+	UpsertExchangeRates(ctx context.Context, reqs []models.ExchangeRateRequest) ([]models.ExchangeRateResponse, error)
+	DeleteExchangeRate(ctx context.Context, base, quote, month string) error
+	ImportExchangeRatesCSV(ctx context.Context, r io.Reader, dryRun bool) (*models.ImportReport, error)
 }
 
 type Handler struct {
@@ -31,187 +56,514 @@ func NewHandler(service SubscriptionService, log *slog.Logger) *Handler {
 	return &Handler{service: service, log: log}
 }
 
-func (h *Handler) handleError(w http.ResponseWriter, err error) {
+// handleError отвечает клиенту ошибкой в формате application/problem+json (RFC 7807)
+// Ошибки, не являющиеся AppError, скрываются за ответом 500
+func (h *Handler) handleError(w http.ResponseWriter, r *http.Request, err error) {
 	var appErr *apperrors.AppError
 	if errors.As(err, &appErr) {
-		h.log.Error("application error", "error", appErr.Err, "code", appErr.Code, "message", appErr.Message)
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(appErr.Code)
-		json.NewEncoder(w).Encode(map[string]string{"error": appErr.Message})
-		return
+		h.log.ErrorContext(r.Context(), "application error", "error", appErr.Err, "code", appErr.Code, "error_code", appErr.ErrorCode, "message", appErr.Message)
+	} else {
+		h.log.ErrorContext(r.Context(), "unexpected error", "error", err)
+		appErr = apperrors.NewInternal(err)
 	}
 
-	h.log.Error("unexpected error", "error", err)
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusInternalServerError)
-	json.NewEncoder(w).Encode(map[string]string{"error": "Internal server error"})
+	problem.Write(w, problem.New(appErr, r.URL.Path, middleware.RequestIDFromContext(r.Context())))
+}
+
+// invalidFormat описывает параметр запроса, значение которого не удалось разобрать
+func invalidFormat(message string) error {
+	return apperrors.Coded("invalid_format", message)
 }
 
-func (h *Handler) RegisterRoutes(mux *http.ServeMux) {
-	mux.HandleFunc("POST /subscriptions", h.CreateSubscription)
-	mux.HandleFunc("GET /subscriptions", h.ListSubscriptions)
-	mux.HandleFunc("PUT /subscriptions/{id}", h.UpdateSubscription)
-	mux.HandleFunc("DELETE /subscriptions/{id}", h.DeleteSubscription)
-	mux.HandleFunc("GET /subscriptions/total", h.GetTotalCost)
+// fieldError возвращает ошибку валидации с единственным нарушением поля field
+func fieldError(field string, err error) error {
+	var v apperrors.Validation
+	v.Add(field, err)
+	return v.Err()
+}
+
+// RegisterRoutes регистрирует маршруты подписок, защищенные guard
+// guard(scope) пропускает только запросы, автору которых разрешена область доступа scope
+func (h *Handler) RegisterRoutes(mux *http.ServeMux, guard func(scope auth.Scope) func(http.Handler) http.Handler) {
+	read := guard(auth.ScopeSubscriptionsRead)
+	write := guard(auth.ScopeSubscriptionsWrite)
+	reports := guard(auth.ScopeReportsRead)
+
+	mux.Handle("POST /subscriptions", write(http.HandlerFunc(h.CreateSubscription)))
+	mux.Handle("GET /subscriptions", read(http.HandlerFunc(h.ListSubscriptions)))
+	mux.Handle("GET /subscriptions/export", read(http.HandlerFunc(h.ExportSubscriptions)))
+	mux.Handle("POST /subscriptions/import", write(http.HandlerFunc(h.ImportSubscriptions)))
+	mux.Handle("POST /subscriptions/batch", write(http.HandlerFunc(h.CreateSubscriptionsBatch)))
+	mux.Handle("DELETE /subscriptions/batch", write(http.HandlerFunc(h.DeleteSubscriptionsBatch)))
+	mux.Handle("GET /subscriptions/{id}", read(http.HandlerFunc(h.GetSubscription)))
+	mux.Handle("PUT /subscriptions/{id}", write(http.HandlerFunc(h.UpdateSubscription)))
+	mux.Handle("DELETE /subscriptions/{id}", write(http.HandlerFunc(h.DeleteSubscription)))
+	mux.Handle("POST /subscriptions/{id}/restore", write(http.HandlerFunc(h.RestoreSubscription)))
+	mux.Handle("GET /subscriptions/total", reports(http.HandlerFunc(h.GetTotalCost)))
+	mux.Handle("GET /subscriptions/total/breakdown", reports(http.HandlerFunc(h.GetTotalCostBreakdown)))
 
 	mux.Handle("GET /swagger/", httpSwagger.WrapHandler)
 }
 
+// RegisterAdminRoutes регистрирует административные маршруты, защищенные guard
+func (h *Handler) RegisterAdminRoutes(mux *http.ServeMux, guard func(http.Handler) http.Handler) {
+	mux.Handle("DELETE /admin/subscriptions/archived", guard(http.HandlerFunc(h.PurgeArchived)))
+	mux.Handle("GET /admin/exchange-rates", guard(http.HandlerFunc(h.ListExchangeRates)))
+	mux.Handle("POST /admin/exchange-rates", guard(http.HandlerFunc(h.UpsertExchangeRates)))
+	mux.Handle("POST /admin/exchange-rates/import", guard(http.HandlerFunc(h.ImportExchangeRates)))
+	mux.Handle("DELETE /admin/exchange-rates/{base}/{quote}/{month}", guard(http.HandlerFunc(h.DeleteExchangeRate)))
+}
+
 // @Summary Создать подписку
-// @Description Создание новой подписки. Поле `end_date` опциональное. Если не указано - подписка бессрочная.
+// @Description Создание новой подписки. Поле `end_date` опциональное. Если не указано - подписка бессрочная.<br>
+// @Description `price` - стоимость одного периода `billing_period` (`month` по умолчанию, `quarter`, `year`, `week`)
+// @Description в минорных единицах валюты `currency` (код ISO 4217, по умолчанию `RUB`): 19990 RUB - 199,90 руб.
+// @Description **Несовместимое изменение:** раньше `price` задавался в целых рублях, теперь - в копейках (400 руб. - `40000`).<br>
+// @Description Квартальная и годовая подписка с `end_date` должна длиться целое число периодов, иначе запрос отклоняется (`partial_billing_period`).<br>
+// @Description При передаче заголовка `Idempotency-Key` повторный запрос с тем же ключом и телом возвращает исходный ответ без создания новой подписки,
+// @Description а запрос с тем же ключом и другим телом отклоняется с кодом 409.
+// @Description Пока исходный запрос выполняется, повтор получает 409 (`idempotency_key_in_progress`); если исходный запрос не завершился за `IDEMPOTENCY_LEASE`, повтор выполняет его заново.
+// @Description При включенной аутентификации обычный пользователь создает подписки только для себя; если `user_id` не указан, используется subject токена.
 // @Tags subscriptions
 // @Accept json
 // @Produce json
+// @Param Idempotency-Key header string false "Unique key to safely retry the request"
 // @Param input body models.CreateSubscriptionRequest true "Subscription info"
 // @Success 201 {object} models.SubscriptionResponse
-// @Failure 400 {string} string "Invalid request body"
-// @Failure 500 {string} string "Internal server error"
+// @Header 201 {string} ETag "Subscription version"
+// @Header 201 {string} Idempotent-Replayed "true if the response was replayed for a repeated Idempotency-Key"
+// @Failure 400 {object} problem.Details "Invalid request body"
+// @Failure 401 {object} problem.Details "Missing or invalid credentials"
+// @Failure 403 {object} problem.Details "user_id of another user or missing scope"
+// @Failure 429 {object} problem.Details "Rate limit exceeded"
+// @Failure 409 {object} problem.Details "Idempotency key reused with a different body or still in progress"
+// @Failure 500 {object} problem.Details "Internal server error"
+// @Security BearerAuth
+// @Security ApiKeyAuth
 // @Router /subscriptions [post]
 func (h *Handler) CreateSubscription(w http.ResponseWriter, r *http.Request) {
 	var req models.CreateSubscriptionRequest
 
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		h.handleError(w, apperrors.NewBadRequest("invalid request body", err))
+		h.handleError(w, r, apperrors.NewBadRequest(errInvalidBody.Error(), errors.Join(errInvalidBody, err)))
 		return
 	}
 
-	h.log.Info("creating subscription",
+	h.log.InfoContext(r.Context(), "creating subscription",
 		slog.String("user_id", req.UserID.String()),
 		slog.String("service_name", req.ServiceName),
 	)
 
-	sub, err := h.service.CreateSubscription(r.Context(), req)
+	var (
+		sub      *models.SubscriptionResponse
+		replayed bool
+		err      error
+	)
+	if key := r.Header.Get("Idempotency-Key"); key != "" {
+		sub, replayed, err = h.service.CreateSubscriptionIdempotent(r.Context(), key, req)
+	} else {
+		sub, err = h.service.CreateSubscription(r.Context(), req)
+	}
 	if err != nil {
-		h.handleError(w, err)
+		h.handleError(w, r, err)
 		return
 	}
 
+	if replayed {
+		w.Header().Set("Idempotent-Replayed", "true")
+	}
 	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("ETag", etag(sub.Version))
 	w.WriteHeader(http.StatusCreated)
 	json.NewEncoder(w).Encode(sub)
 }
 
+// @Summary Получить подписку
+// @Tags subscriptions
+// @Produce json
+// @Param id path string true "Subscription ID"
+// @Success 200 {object} models.SubscriptionResponse
+// @Header 200 {string} ETag "Subscription version"
+// @Failure 400 {object} problem.Details "Invalid ID format"
+// @Failure 401 {object} problem.Details "Missing or invalid credentials"
+// @Failure 403 {object} problem.Details "Missing scope"
+// @Failure 429 {object} problem.Details "Rate limit exceeded"
+// @Failure 404 {object} problem.Details "Subscription not found"
+// @Failure 500 {object} problem.Details "Internal server error"
+// @Security BearerAuth
+// @Security ApiKeyAuth
+// @Router /subscriptions/{id} [get]
+func (h *Handler) GetSubscription(w http.ResponseWriter, r *http.Request) {
+	idStr := r.PathValue("id")
+	id, err := uuid.Parse(idStr)
+	if err != nil {
+		h.handleError(w, r, apperrors.NewBadRequest(errInvalidID.Error(), errors.Join(errInvalidID, err)))
+		return
+	}
+
+	h.log.InfoContext(r.Context(), "getting subscription", slog.String("id", id.String()))
+
+	sub, err := h.service.GetSubscription(r.Context(), id)
+	if err != nil {
+		h.handleError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("ETag", etag(sub.Version))
+	json.NewEncoder(w).Encode(sub)
+}
+
 // @Summary Обновить подписку
-// @Description Обновление данных подписки. Все поля опциональные. При обновлении `end_date` проверяется, что `end_date >= start_date`.
+// @Description Обновление данных подписки. Все поля опциональные. После объединения с текущей подпиской проверяется, что `end_date >= start_date`
+// @Description и что квартальная или годовая подписка длится целое число периодов `billing_period`.
+// @Description Если передан заголовок `If-Match`, обновление выполняется только при совпадении с текущим `ETag` подписки.
 // @Tags subscriptions
 // @Accept json
 // @Produce json
 // @Param id path string true "Subscription ID"
+// @Param If-Match header string false "ETag of the subscription version being updated"
 // @Param input body models.UpdateSubscriptionRequest true "Subscription update info (all fields optional)"
 // @Success 200 {object} models.SubscriptionResponse
-// @Failure 400 {string} string "Invalid request"
-// @Failure 404 {string} string "Subscription not found"
-// @Failure 500 {string} string "Internal server error"
+// @Header 200 {string} ETag "Subscription version"
+// @Failure 400 {object} problem.Details "Invalid request"
+// @Failure 401 {object} problem.Details "Missing or invalid credentials"
+// @Failure 403 {object} problem.Details "Missing scope"
+// @Failure 429 {object} problem.Details "Rate limit exceeded"
+// @Failure 404 {object} problem.Details "Subscription not found"
+// @Failure 412 {object} problem.Details "Subscription has been modified"
+// @Failure 500 {object} problem.Details "Internal server error"
+// @Security BearerAuth
+// @Security ApiKeyAuth
 // @Router /subscriptions/{id} [put]
 func (h *Handler) UpdateSubscription(w http.ResponseWriter, r *http.Request) {
 	idStr := r.PathValue("id")
 	id, err := uuid.Parse(idStr)
 	if err != nil {
-		h.handleError(w, apperrors.NewBadRequest("invalid id format", err))
+		h.handleError(w, r, apperrors.NewBadRequest(errInvalidID.Error(), errors.Join(errInvalidID, err)))
+		return
+	}
+
+	expectedVersion, err := parseIfMatch(r)
+	if err != nil {
+		h.handleError(w, r, err)
 		return
 	}
 
 	var req models.UpdateSubscriptionRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		h.handleError(w, apperrors.NewBadRequest("invalid request body", err))
+		h.handleError(w, r, apperrors.NewBadRequest(errInvalidBody.Error(), errors.Join(errInvalidBody, err)))
 		return
 	}
 
-	h.log.Info("updating subscription", slog.String("id", id.String()))
+	h.log.InfoContext(r.Context(), "updating subscription", slog.String("id", id.String()))
 
-	sub, err := h.service.UpdateSubscription(r.Context(), id, req)
+	sub, err := h.service.UpdateSubscription(r.Context(), id, req, expectedVersion)
 	if err != nil {
-		h.handleError(w, err)
+		h.handleError(w, r, err)
 		return
 	}
 
 	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("ETag", etag(sub.Version))
 	json.NewEncoder(w).Encode(sub)
 }
 
 // @Summary Удалить подписку
+// @Description Подписка переносится в архив: она перестает возвращаться из GET и списка (без `include_deleted`),
+// @Description но учитывается в расчете стоимости по месяц архивации включительно. Восстановить можно через `POST /subscriptions/{id}/restore`.<br>
+// @Description Если передан заголовок `If-Match`, подписка удаляется только при совпадении с текущим `ETag`.
 // @Tags subscriptions
 // @Param id path string true "Subscription ID"
+// @Param If-Match header string false "ETag of the subscription version being deleted"
 // @Success 204 "No Content"
-// @Failure 400 {string} string "Invalid ID format"
-// @Failure 404 {string} string "Subscription not found"
-// @Failure 500 {string} string "Internal server error"
+// @Failure 400 {object} problem.Details "Invalid ID format"
+// @Failure 401 {object} problem.Details "Missing or invalid credentials"
+// @Failure 403 {object} problem.Details "Missing scope"
+// @Failure 429 {object} problem.Details "Rate limit exceeded"
+// @Failure 404 {object} problem.Details "Subscription not found"
+// @Failure 412 {object} problem.Details "Subscription has been modified"
+// @Failure 500 {object} problem.Details "Internal server error"
+// @Security BearerAuth
+// @Security ApiKeyAuth
 // @Router /subscriptions/{id} [delete]
 func (h *Handler) DeleteSubscription(w http.ResponseWriter, r *http.Request) {
 	idStr := r.PathValue("id")
 	id, err := uuid.Parse(idStr)
 	if err != nil {
-		h.handleError(w, apperrors.NewBadRequest("invalid id format", err))
+		h.handleError(w, r, apperrors.NewBadRequest(errInvalidID.Error(), errors.Join(errInvalidID, err)))
 		return
 	}
 
-	h.log.Info("deleting subscription", slog.String("id", id.String()))
+	expectedVersion, err := parseIfMatch(r)
+	if err != nil {
+		h.handleError(w, r, err)
+		return
+	}
 
-	err = h.service.DeleteSubscription(r.Context(), id)
+	h.log.InfoContext(r.Context(), "deleting subscription", slog.String("id", id.String()))
+
+	err = h.service.DeleteSubscription(r.Context(), id, expectedVersion)
 	if err != nil {
-		h.handleError(w, err)
+		h.handleError(w, r, err)
 		return
 	}
 
 	w.WriteHeader(http.StatusNoContent)
 }
 
-// @Summary Получить список подписок
-// @Description Получение списка подписок с пагинацией. При указании user_id возвращаются подписки конкретного пользователя, иначе все подписки.
+// @Summary Восстановить подписку из архива
 // @Tags subscriptions
 // @Produce json
-// @Param user_id query string false "User UUID (optional - returns all if not provided)"
-// @Param limit query integer false "Limit (default: 10, max: 100)"
-// @Param offset query integer false "Offset (default: 0)"
-// @Success 200 {object} models.PaginatedSubscriptionResponse
-// @Failure 400 {string} string "Invalid parameters"
-// @Failure 500 {string} string "Internal server error"
-// @Router /subscriptions [get]
-func (h *Handler) ListSubscriptions(w http.ResponseWriter, r *http.Request) {
-	ctx := r.Context()
+// @Param id path string true "Subscription ID"
+// @Param If-Match header string false "ETag of the archived subscription version"
+// @Success 200 {object} models.SubscriptionResponse
+// @Header 200 {string} ETag "Subscription version"
+// @Failure 400 {object} problem.Details "Invalid ID format"
+// @Failure 401 {object} problem.Details "Missing or invalid credentials"
+// @Failure 403 {object} problem.Details "Missing scope"
+// @Failure 429 {object} problem.Details "Rate limit exceeded"
+// @Failure 404 {object} problem.Details "Archived subscription not found"
+// @Failure 412 {object} problem.Details "Subscription has been modified"
+// @Failure 500 {object} problem.Details "Internal server error"
+// @Security BearerAuth
+// @Security ApiKeyAuth
+// @Router /subscriptions/{id}/restore [post]
+func (h *Handler) RestoreSubscription(w http.ResponseWriter, r *http.Request) {
+	idStr := r.PathValue("id")
+	id, err := uuid.Parse(idStr)
+	if err != nil {
+		h.handleError(w, r, apperrors.NewBadRequest(errInvalidID.Error(), errors.Join(errInvalidID, err)))
+		return
+	}
 
-	req := models.ListSubscriptionsRequest{}
+	expectedVersion, err := parseIfMatch(r)
+	if err != nil {
+		h.handleError(w, r, err)
+		return
+	}
 
-	userIDStr := r.URL.Query().Get("user_id")
+	h.log.InfoContext(r.Context(), "restoring subscription", slog.String("id", id.String()))
 
-	if userIDStr != "" {
-		parsedID, err := uuid.Parse(userIDStr)
-		if err != nil {
-			h.handleError(w, apperrors.NewBadRequest("invalid user_id format", err))
-			return
-		}
-		req.UserID = &parsedID
+	sub, err := h.service.RestoreSubscription(r.Context(), id, expectedVersion)
+	if err != nil {
+		h.handleError(w, r, err)
+		return
 	}
 
-	limitStr := r.URL.Query().Get("limit")
-	if limitStr != "" {
-		limit, err := strconv.Atoi(limitStr)
-		if err != nil {
-			h.handleError(w, apperrors.NewBadRequest("limit must be an integer", err))
-			return
-		}
-		req.Limit = limit
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("ETag", etag(sub.Version))
+	json.NewEncoder(w).Encode(sub)
+}
+
+// @Summary Очистить архив подписок
+// @Description Окончательно удаляет подписки, находящиеся в архиве дольше `older_than_days` дней. Требует заголовок `X-Admin-Token`.
+// @Tags admin
+// @Produce json
+// @Param X-Admin-Token header string true "Admin token"
+// @Param older_than_days query integer true "Minimum number of days in archive"
+// @Success 200 {object} models.PurgeArchivedResponse
+// @Failure 400 {object} problem.Details "Invalid parameters"
+// @Failure 403 {object} problem.Details "Admin access required"
+// @Failure 500 {object} problem.Details "Internal server error"
+// @Router /admin/subscriptions/archived [delete]
+func (h *Handler) PurgeArchived(w http.ResponseWriter, r *http.Request) {
+	daysStr := r.URL.Query().Get("older_than_days")
+	if daysStr == "" {
+		h.handleError(w, r, fieldError("older_than_days", apperrors.Coded("required", "older_than_days is required")))
+		return
 	}
 
-	offsetStr := r.URL.Query().Get("offset")
-	if offsetStr != "" {
-		offset, err := strconv.Atoi(offsetStr)
-		if err != nil {
-			h.handleError(w, apperrors.NewBadRequest("offset must be an integer", err))
-			return
+	days, err := strconv.Atoi(daysStr)
+	if err != nil {
+		h.handleError(w, r, fieldError("older_than_days", invalidFormat("older_than_days must be an integer")))
+		return
+	}
+
+	h.log.InfoContext(r.Context(), "purging archived subscriptions", slog.Int("older_than_days", days))
+
+	res, err := h.service.PurgeArchived(r.Context(), days)
+	if err != nil {
+		h.handleError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(res)
+}
+
+// @Summary Пакетное создание подписок
+// @Description Создает подписки из массива в одной транзакции (не более 1000 элементов).<br>
+// @Description `mode=atomic` (по умолчанию) - все или ничего: при любой ошибке ничего не сохраняется, ответ содержит ошибки по индексам элементов.<br>
+// @Description `mode=partial` - валидные элементы сохраняются, ошибочные пропускаются; при наличии ошибок возвращается 207.
+// @Tags subscriptions
+// @Accept json
+// @Produce json
+// @Param mode query string false "Batch mode (default: atomic)" Enums(atomic, partial)
+// @Param input body []models.CreateSubscriptionRequest true "Subscriptions to create"
+// @Success 201 {object} models.BatchResponse
+// @Success 207 {object} models.BatchResponse "Some items failed (partial mode)"
+// @Failure 400 {object} models.BatchResponse "Invalid request or item validation failed (atomic mode)"
+// @Failure 401 {object} problem.Details "Missing or invalid credentials"
+// @Failure 403 {object} problem.Details "Missing scope"
+// @Failure 429 {object} problem.Details "Rate limit exceeded"
+// @Failure 500 {object} problem.Details "Internal server error"
+// @Security BearerAuth
+// @Security ApiKeyAuth
+// @Router /subscriptions/batch [post]
+func (h *Handler) CreateSubscriptionsBatch(w http.ResponseWriter, r *http.Request) {
+	var reqs []models.CreateSubscriptionRequest
+	if err := json.NewDecoder(r.Body).Decode(&reqs); err != nil {
+		h.handleError(w, r, apperrors.NewBadRequest(errInvalidBody.Error(), errors.Join(errInvalidBody, err)))
+		return
+	}
+
+	mode := batchMode(r)
+
+	h.log.InfoContext(r.Context(), "creating subscriptions batch",
+		slog.Int("count", len(reqs)),
+		slog.String("mode", string(mode)),
+	)
+
+	res, err := h.service.CreateSubscriptionsBatch(r.Context(), reqs, mode)
+	if err != nil {
+		h.handleError(w, r, err)
+		return
+	}
+
+	h.writeBatchResponse(w, r, res, http.StatusCreated)
+}
+
+// @Summary Пакетное удаление подписок
+// @Description Архивирует подписки по массиву ID в одной транзакции (не более 1000 элементов). Режимы `atomic` и `partial` работают так же, как при пакетном создании.
+// @Tags subscriptions
+// @Accept json
+// @Produce json
+// @Param mode query string false "Batch mode (default: atomic)" Enums(atomic, partial)
+// @Param input body []string true "Subscription IDs"
+// @Success 200 {object} models.BatchResponse
+// @Success 207 {object} models.BatchResponse "Some items failed (partial mode)"
+// @Failure 400 {object} problem.Details "Invalid request"
+// @Failure 401 {object} problem.Details "Missing or invalid credentials"
+// @Failure 403 {object} problem.Details "Missing scope"
+// @Failure 429 {object} problem.Details "Rate limit exceeded"
+// @Failure 404 {object} models.BatchResponse "Subscription not found (atomic mode)"
+// @Failure 500 {object} problem.Details "Internal server error"
+// @Security BearerAuth
+// @Security ApiKeyAuth
+// @Router /subscriptions/batch [delete]
+func (h *Handler) DeleteSubscriptionsBatch(w http.ResponseWriter, r *http.Request) {
+	var ids []uuid.UUID
+	if err := json.NewDecoder(r.Body).Decode(&ids); err != nil {
+		h.handleError(w, r, apperrors.NewBadRequest(errInvalidBody.Error(), errors.Join(errInvalidBody, err)))
+		return
+	}
+
+	mode := batchMode(r)
+
+	h.log.InfoContext(r.Context(), "deleting subscriptions batch",
+		slog.Int("count", len(ids)),
+		slog.String("mode", string(mode)),
+	)
+
+	res, err := h.service.ArchiveSubscriptionsBatch(r.Context(), ids, mode)
+	if err != nil {
+		h.handleError(w, r, err)
+		return
+	}
+
+	h.writeBatchResponse(w, r, res, http.StatusOK)
+}
+
+// writeBatchResponse выбирает код ответа по результатам пакетной операции:
+// okStatus при полном успехе, 207 при частичном в режиме partial,
+// код первой ошибки элемента в режиме atomic
+func (h *Handler) writeBatchResponse(w http.ResponseWriter, r *http.Request, res *models.BatchResponse, okStatus int) {
+	status := okStatus
+	if res.Failed > 0 {
+		status = http.StatusMultiStatus
+		if res.Mode == models.BatchModeAtomic {
+			for _, item := range res.Results {
+				if item.Status != http.StatusFailedDependency && item.Status >= http.StatusBadRequest {
+					status = item.Status
+					break
+				}
+			}
 		}
-		req.Offset = offset
+		h.log.WarnContext(r.Context(), "batch completed with errors",
+			slog.String("mode", string(res.Mode)),
+			slog.Int("succeeded", res.Succeeded),
+			slog.Int("failed", res.Failed),
+		)
 	}
 
-	h.log.Info("listing subscriptions",
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(res)
+}
+
+func batchMode(r *http.Request) models.BatchMode {
+	if mode := r.URL.Query().Get("mode"); mode != "" {
+		return models.BatchMode(mode)
+	}
+	return models.BatchModeAtomic
+}
+
+// @Summary Получить список подписок
+// @Description Получение списка подписок с пагинацией. При указании user_id возвращаются подписки конкретного пользователя, иначе все подписки.<br>
+// @Description Все фильтры опциональные и объединяются через AND. Даты в формате MM-YYYY, диапазоны включительные.<br>
+// @Description Сортировка задается списком полей через запятую, префикс `-` означает убывание, например `sort=price,-start_date`. По умолчанию `-created_at`.<br><br>
+// @Description **Пагинация:** поддерживаются режимы `offset` и курсорный. При сортировке по умолчанию ответ содержит `next_cursor`,
+// @Description который передается в параметре `cursor` для получения следующей страницы. Курсор не совместим с `offset` и `sort`.<br>
+// @Description Поле `total` возвращается при `include_total=true`; по умолчанию считается только в режиме offset.
+// @Tags subscriptions
+// @Produce json
+// @Param user_id query string false "User UUID (optional - returns all if not provided)"
+// @Param limit query integer false "Limit (default: 20, max: 100)"
+// @Param offset query integer false "Offset (default: 0)"
+// @Param cursor query string false "Opaque cursor from next_cursor of the previous page"
+// @Param include_total query boolean false "Calculate total count (default: true without cursor, false with cursor)"
+// @Param include_deleted query boolean false "Include archived subscriptions (default: false)"
+// @Param service_name query string false "Exact service name"
+// @Param service_name_prefix query string false "Service name prefix"
+// @Param price_min query integer false "Minimum price (inclusive)"
+// @Param price_max query integer false "Maximum price (inclusive)"
+// @Param active_at query string false "Only subscriptions active in this month (MM-YYYY)"
+// @Param start_date_from query string false "Minimum start_date (MM-YYYY)"
+// @Param start_date_to query string false "Maximum start_date (MM-YYYY)"
+// @Param end_date_from query string false "Minimum end_date (MM-YYYY)"
+// @Param end_date_to query string false "Maximum end_date (MM-YYYY)"
+// @Param open_ended query boolean false "true - only subscriptions without end_date, false - only with end_date"
+// @Param sort query string false "Sort fields: service_name, price, user_id, start_date, end_date, created_at, updated_at"
+// @Success 200 {object} models.PaginatedSubscriptionResponse
+// @Failure 400 {object} problem.Details "Invalid parameters"
+// @Failure 401 {object} problem.Details "Missing or invalid credentials"
+// @Failure 403 {object} problem.Details "user_id of another user or missing scope"
+// @Failure 429 {object} problem.Details "Rate limit exceeded"
+// @Failure 500 {object} problem.Details "Internal server error"
+// @Security BearerAuth
+// @Security ApiKeyAuth
+// @Router /subscriptions [get]
+func (h *Handler) ListSubscriptions(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	req, err := parseListQuery(r)
+	if err != nil {
+		h.handleError(w, r, err)
+		return
+	}
+
+	h.log.InfoContext(r.Context(), "listing subscriptions",
 		slog.String("user_id", r.URL.Query().Get("user_id")),
 		slog.Int("limit", req.Limit),
 		slog.Int("offset", req.Offset),
+		slog.Bool("include_deleted", req.IncludeDeleted),
+		slog.String("sort", r.URL.Query().Get("sort")),
 	)
 
 	subs, err := h.service.ListSubscriptions(ctx, req)
 	if err != nil {
-		h.handleError(w, err)
+		h.handleError(w, r, err)
 		return
 	}
 
@@ -221,55 +573,318 @@ func (h *Handler) ListSubscriptions(w http.ResponseWriter, r *http.Request) {
 
 // @Summary Получение общей стоимости подписок за заданный период
 // @Description Расчёт общей стоимости активных подписок за указанный период.<br><br>
-// @Description **Логика расчёта:**<br>
-// @Description - Подписка с `end_date` учитывается, если пересекается с запрошенным периодом (start_date <= end_period AND end_date >= start_period)<br>
-// @Description - Подписка без `end_date` (бессрочная) учитывается полностью, если её `start_date <= end_period`<br>
-// @Description - Бессрочная подписка всегда учитывается полной стоимостью, независимо от длины запрошенного периода
+// @Description **Логика расчёта (mode=prorated, по умолчанию):**<br>
+// @Description - Подписка учитывается, если пересекается с запрошенным периодом (start_date <= end_period AND (end_date IS NULL OR end_date >= start_period))<br>
+// @Description - Стоимость подписки = цена периода × количество списаний, даты которых попадают в запрошенный период (включительно)<br>
+// @Description - Списания приходятся на `start_date` и далее через каждый `billing_period`: месяц, квартал, год или неделю<br>
+// @Description - Бессрочная подписка считается активной до конца запрошенного периода<br>
+// @Description - Архивная (удаленная) подписка считается активной по месяц архивации включительно<br><br>
+// @Description **mode=per_subscription:** каждая пересекающаяся с периодом подписка учитывается один раз, независимо от длины периода<br><br>
+// @Description **group_by:** список полей через запятую (`service_name`, `user_id`, `month`). При указании в ответе возвращается поле `groups` с суммой и количеством подписок по каждой группе.
+// @Description При группировке по `month` подписка учитывается в каждом месяце, в котором активна, со списаниями этого месяца; `mode=per_subscription` с `month` не совместим.<br><br>
+// @Description **currency:** суммы в минорных единицах валюты ответа `currency` (для `RUB` - в копейках, а не в рублях, как раньше). Без параметра все подписки должны быть в одной валюте, иначе ответ 400 `currency_required`.
+// @Description С параметром списания каждой валюты за месяц пересчитываются по курсу этого месяца из `/admin/exchange-rates` (при `mode=per_subscription` - по курсу первого месяца периода, в котором подписка активна); если курса нет - 400 `exchange_rate_not_found`.
 // @Tags subscriptions
 // @Produce json
 // @Param user_id query string false "User UUID (optional - calculates total for all users if not provided)"
 // @Param start_date query string true "Format: MM-YYYY"
 // @Param end_date query string true "Format: MM-YYYY"
 // @Param service_name query string false "Service Name filter (optional)"
+// @Param mode query string false "Calculation mode (default: prorated)" Enums(prorated, per_subscription)
+// @Param group_by query string false "Comma-separated grouping fields: service_name, user_id, month"
+// @Param currency query string false "ISO 4217 currency to convert amounts into (e.g. USD)"
 // @Success 200 {object} models.TotalCostResponse
-// @Failure 400 {string} string "Invalid parameters"
-// @Failure 404 {string} string "No subscriptions found for the specified criteria"
-// @Failure 500 {string} string "Internal server error"
+// @Failure 400 {object} problem.Details "Invalid parameters"
+// @Failure 401 {object} problem.Details "Missing or invalid credentials"
+// @Failure 403 {object} problem.Details "user_id of another user or missing scope"
+// @Failure 429 {object} problem.Details "Rate limit exceeded"
+// @Failure 404 {object} problem.Details "No subscriptions found for the specified criteria"
+// @Failure 500 {object} problem.Details "Internal server error"
+// @Security BearerAuth
+// @Security ApiKeyAuth
 // @Router /subscriptions/total [get]
 func (h *Handler) GetTotalCost(w http.ResponseWriter, r *http.Request) {
-	userIDStr := r.URL.Query().Get("user_id")
-	serviceName := r.URL.Query().Get("service_name")
-	startDate := r.URL.Query().Get("start_date")
-	endDate := r.URL.Query().Get("end_date")
+	q, err := parseCostQuery(r)
+	if err != nil {
+		h.handleError(w, r, err)
+		return
+	}
+	mode := models.TotalCostMode(r.URL.Query().Get("mode"))
+	groupByStr := r.URL.Query().Get("group_by")
 
-	var userID *uuid.UUID
-	if userIDStr != "" {
-		parsedID, err := uuid.Parse(userIDStr)
+	h.log.InfoContext(r.Context(), "calculating total cost",
+		slog.String("user_id", r.URL.Query().Get("user_id")),
+		slog.String("service_name", q.serviceName),
+		slog.String("start_date", q.startDate),
+		slog.String("end_date", q.endDate),
+		slog.String("mode", string(mode)),
+		slog.String("group_by", groupByStr),
+		slog.String("currency", q.currency),
+	)
+
+	if groupByStr != "" {
+		groupBy, err := models.ParseCostGroupBy(groupByStr)
+		if err != nil {
+			h.handleError(w, r, apperrors.NewBadRequest(err.Error(), err))
+			return
+		}
+
+		grouped, err := h.service.CalculateGroupedTotal(r.Context(), q.userID, q.serviceName, q.startDate, q.endDate, mode, groupBy, q.currency)
 		if err != nil {
-			h.handleError(w, apperrors.NewBadRequest("invalid user_id format", err))
+			h.handleError(w, r, err)
 			return
 		}
-		userID = &parsedID
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(grouped)
+		return
 	}
 
-	if startDate == "" || endDate == "" {
-		h.handleError(w, apperrors.NewBadRequest("start_date and end_date are required", nil))
+	total, err := h.service.CalculateTotal(r.Context(), q.userID, q.serviceName, q.startDate, q.endDate, mode, q.currency)
+	if err != nil {
+		h.handleError(w, r, err)
 		return
 	}
 
-	h.log.Info("calculating total cost",
-		slog.String("user_id", userIDStr),
-		slog.String("service_name", serviceName),
-		slog.String("start_date", startDate),
-		slog.String("end_date", endDate),
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(total)
+}
+
+// @Summary Помесячная разбивка стоимости подписок за период
+// @Description Возвращает по одной строке на каждый календарный месяц периода (включительно) с суммой списаний этого месяца и списком подписок, активных в нём.<br>
+// @Description Сумма месяца учитывает `billing_period`: годовая подписка списывается только в месяцы продления, недельная - за каждую неделю, начавшуюся в месяце.<br>
+// @Description Подписка активна в месяце, если её `start_date` не позже месяца и `end_date` не указан или не раньше месяца.<br>
+// @Description С параметром `currency` суммы месяцев пересчитываются в эту валюту по курсу месяца; цены подписок в списке остаются в их валютах.
+// @Description Без параметра все подписки должны быть в одной валюте, иначе ответ 400 `currency_required`.
+// @Tags subscriptions
+// @Produce json
+// @Param user_id query string false "User UUID (optional - calculates total for all users if not provided)"
+// @Param start_date query string true "Format: MM-YYYY"
+// @Param end_date query string true "Format: MM-YYYY"
+// @Param service_name query string false "Service Name filter (optional)"
+// @Param currency query string false "ISO 4217 currency to convert amounts into (e.g. USD)"
+// @Success 200 {object} models.CostBreakdownResponse
+// @Failure 400 {object} problem.Details "Invalid parameters"
+// @Failure 401 {object} problem.Details "Missing or invalid credentials"
+// @Failure 403 {object} problem.Details "user_id of another user or missing scope"
+// @Failure 429 {object} problem.Details "Rate limit exceeded"
+// @Failure 500 {object} problem.Details "Internal server error"
+// @Security BearerAuth
+// @Security ApiKeyAuth
+// @Router /subscriptions/total/breakdown [get]
+func (h *Handler) GetTotalCostBreakdown(w http.ResponseWriter, r *http.Request) {
+	q, err := parseCostQuery(r)
+	if err != nil {
+		h.handleError(w, r, err)
+		return
+	}
+
+	h.log.InfoContext(r.Context(), "calculating total cost breakdown",
+		slog.String("user_id", r.URL.Query().Get("user_id")),
+		slog.String("service_name", q.serviceName),
+		slog.String("start_date", q.startDate),
+		slog.String("end_date", q.endDate),
+		slog.String("currency", q.currency),
 	)
 
-	total, err := h.service.CalculateTotal(r.Context(), userID, serviceName, startDate, endDate)
+	breakdown, err := h.service.CalculateBreakdown(r.Context(), q.userID, q.serviceName, q.startDate, q.endDate, q.currency)
 	if err != nil {
-		h.handleError(w, err)
+		h.handleError(w, r, err)
 		return
 	}
 
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(models.TotalCostResponse{TotalCost: total})
+	json.NewEncoder(w).Encode(breakdown)
+}
+
+type costQuery struct {
+	userID      *uuid.UUID
+	serviceName string
+	startDate   string
+	endDate     string
+	currency    string
+}
+
+// parseCostQuery разбирает общие параметры запросов расчета стоимости
+func parseCostQuery(r *http.Request) (costQuery, error) {
+	q := costQuery{
+		serviceName: r.URL.Query().Get("service_name"),
+		startDate:   r.URL.Query().Get("start_date"),
+		endDate:     r.URL.Query().Get("end_date"),
+		currency:    r.URL.Query().Get("currency"),
+	}
+
+	var v apperrors.Validation
+
+	if userIDStr := r.URL.Query().Get("user_id"); userIDStr != "" {
+		parsedID, err := uuid.Parse(userIDStr)
+		if err != nil {
+			v.Add("user_id", invalidFormat("invalid user_id format"))
+		} else {
+			q.userID = &parsedID
+		}
+	}
+
+	if q.startDate == "" {
+		v.Add("start_date", apperrors.Coded("required", "start_date is required"))
+	}
+	if q.endDate == "" {
+		v.Add("end_date", apperrors.Coded("required", "end_date is required"))
+	}
+
+	if err := v.Err(); err != nil {
+		return costQuery{}, err
+	}
+	return q, nil
+}
+
+// etag формирует значение заголовка ETag по версии подписки
+func etag(version int) string {
+	return `"` + strconv.Itoa(version) + `"`
+}
+
+// parseIfMatch извлекает ожидаемую версию подписки из заголовка If-Match
+// Отсутствующий заголовок и "*" означают, что версия не проверяется
+func parseIfMatch(r *http.Request) (*int, error) {
+	value := strings.TrimSpace(r.Header.Get("If-Match"))
+	if value == "" || value == "*" {
+		return nil, nil
+	}
+
+	tag := strings.TrimPrefix(value, "W/")
+	if len(tag) < 2 || tag[0] != '"' || tag[len(tag)-1] != '"' {
+		return nil, apperrors.NewPreconditionFailed("If-Match does not match current ETag", nil)
+	}
+
+	version, err := strconv.Atoi(tag[1 : len(tag)-1])
+	if err != nil {
+		return nil, apperrors.NewPreconditionFailed("If-Match does not match current ETag", err)
+	}
+
+	return &version, nil
+}
+
+// parseListQuery разбирает параметры фильтрации, сортировки и пагинации списка подписок
+// Ошибки разбора возвращаются все сразу, по одной на параметр
+func parseListQuery(r *http.Request) (models.ListSubscriptionsRequest, error) {
+	query := r.URL.Query()
+	req := models.ListSubscriptionsRequest{
+		ServiceName:       query.Get("service_name"),
+		ServiceNamePrefix: query.Get("service_name_prefix"),
+	}
+
+	var v apperrors.Validation
+
+	if userIDStr := query.Get("user_id"); userIDStr != "" {
+		parsedID, err := uuid.Parse(userIDStr)
+		if err != nil {
+			v.Add("user_id", invalidFormat("invalid user_id format"))
+		} else {
+			req.UserID = &parsedID
+		}
+	}
+
+	intParams := []struct {
+		name string
+		dest *int
+	}{
+		{"limit", &req.Limit},
+		{"offset", &req.Offset},
+	}
+	for _, p := range intParams {
+		if value := query.Get(p.name); value != "" {
+			n, err := strconv.Atoi(value)
+			if err != nil {
+				v.Add(p.name, invalidFormat(p.name+" must be an integer"))
+				continue
+			}
+			*p.dest = n
+		}
+	}
+
+	optionalIntParams := []struct {
+		name string
+		dest **int
+	}{
+		{"price_min", &req.PriceMin},
+		{"price_max", &req.PriceMax},
+	}
+	for _, p := range optionalIntParams {
+		if value := query.Get(p.name); value != "" {
+			n, err := strconv.Atoi(value)
+			if err != nil {
+				v.Add(p.name, invalidFormat(p.name+" must be an integer"))
+				continue
+			}
+			*p.dest = &n
+		}
+	}
+
+	dateParams := []struct {
+		name string
+		dest **time.Time
+	}{
+		{"active_at", &req.ActiveAt},
+		{"start_date_from", &req.StartDateFrom},
+		{"start_date_to", &req.StartDateTo},
+		{"end_date_from", &req.EndDateFrom},
+		{"end_date_to", &req.EndDateTo},
+	}
+	for _, p := range dateParams {
+		if value := query.Get(p.name); value != "" {
+			t, err := time.Parse(models.DateLayout, value)
+			if err != nil {
+				v.Add(p.name, invalidFormat("invalid "+p.name+" format"))
+				continue
+			}
+			*p.dest = &t
+		}
+	}
+
+	if value := query.Get("include_deleted"); value != "" {
+		includeDeleted, err := strconv.ParseBool(value)
+		if err != nil {
+			v.Add("include_deleted", invalidFormat("include_deleted must be a boolean"))
+		} else {
+			req.IncludeDeleted = includeDeleted
+		}
+	}
+
+	if value := query.Get("open_ended"); value != "" {
+		openEnded, err := strconv.ParseBool(value)
+		if err != nil {
+			v.Add("open_ended", invalidFormat("open_ended must be a boolean"))
+		} else {
+			req.OpenEnded = &openEnded
+		}
+	}
+
+	if value := query.Get("include_total"); value != "" {
+		includeTotal, err := strconv.ParseBool(value)
+		if err != nil {
+			v.Add("include_total", invalidFormat("include_total must be a boolean"))
+		} else {
+			req.IncludeTotal = &includeTotal
+		}
+	}
+
+	if value := query.Get("cursor"); value != "" {
+		cursor, err := models.DecodeListCursor(value)
+		if err != nil {
+			v.Add("cursor", err)
+		} else {
+			req.Cursor = cursor
+		}
+	}
+
+	if sortStr := query.Get("sort"); sortStr != "" {
+		sort, err := models.ParseSort(sortStr)
+		if err != nil {
+			v.Add("sort", err)
+		} else {
+			req.Sort = sort
+		}
+	}
+
+	return req, v.Err()
 }
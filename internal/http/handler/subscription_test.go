@@ -0,0 +1,120 @@
+package handler
+
+import (
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Gilf4/effective-mobile-task/internal/auth"
+	"github.com/Gilf4/effective-mobile-task/internal/http/problem"
+	"github.com/Gilf4/effective-mobile-task/internal/models"
+	"github.com/Gilf4/effective-mobile-task/internal/repository/memory"
+	"github.com/Gilf4/effective-mobile-task/internal/service"
+)
+
+// newTestMux собирает маршруты подписок над хранилищем в памяти без аутентификации
+func newTestMux() *http.ServeMux {
+	repo := memory.NewSubscriptionRepository()
+	svc := service.NewSubscriptionService(repo, repo, repo, time.Hour, time.Minute)
+	log := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	mux := http.NewServeMux()
+	NewHandler(svc, log).RegisterRoutes(mux, func(auth.Scope) func(http.Handler) http.Handler {
+		return func(next http.Handler) http.Handler { return next }
+	})
+	return mux
+}
+
+func serve(mux http.Handler, method, target, body string, header http.Header) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, target, strings.NewReader(body))
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestSubscriptionLifecycle(t *testing.T) {
+	mux := newTestMux()
+	body := `{"service_name":"Netflix","price":39900,"user_id":"60601fee-2bf1-4721-ae6f-7636e79a0cba","start_date":"01-2026"}`
+
+	rec := serve(mux, http.MethodPost, "/subscriptions", body, nil)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("create status = %d, want %d: %s", rec.Code, http.StatusCreated, rec.Body)
+	}
+	if got := rec.Header().Get("ETag"); got != `"1"` {
+		t.Errorf("create ETag = %s, want \"1\"", got)
+	}
+	var created models.SubscriptionResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &created); err != nil {
+		t.Fatalf("decode created: %v", err)
+	}
+
+	rec = serve(mux, http.MethodGet, "/subscriptions?user_id=60601fee-2bf1-4721-ae6f-7636e79a0cba", "", nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("list status = %d, want %d: %s", rec.Code, http.StatusOK, rec.Body)
+	}
+	var list models.PaginatedSubscriptionResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &list); err != nil {
+		t.Fatalf("decode list: %v", err)
+	}
+	if len(list.Data) != 1 || list.Data[0].ID != created.ID {
+		t.Fatalf("list = %+v, want only %s", list.Data, created.ID)
+	}
+
+	path := "/subscriptions/" + created.ID.String()
+	ifMatch := http.Header{"If-Match": {`"1"`}}
+
+	rec = serve(mux, http.MethodPut, path, `{"price":49900}`, ifMatch)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("update status = %d, want %d: %s", rec.Code, http.StatusOK, rec.Body)
+	}
+	if got := rec.Header().Get("ETag"); got != `"2"` {
+		t.Errorf("update ETag = %s, want \"2\"", got)
+	}
+
+	rec = serve(mux, http.MethodPut, path, `{"price":59900}`, ifMatch)
+	if rec.Code != http.StatusPreconditionFailed {
+		t.Fatalf("stale update status = %d, want %d: %s", rec.Code, http.StatusPreconditionFailed, rec.Body)
+	}
+	var details problem.Details
+	if err := json.Unmarshal(rec.Body.Bytes(), &details); err != nil {
+		t.Fatalf("decode problem: %v", err)
+	}
+	if details.Code != models.ErrSubscriptionModified.ErrorCode() {
+		t.Errorf("stale update code = %q, want %q", details.Code, models.ErrSubscriptionModified.ErrorCode())
+	}
+
+	rec = serve(mux, http.MethodGet, "/subscriptions/total?start_date=01-2026&end_date=03-2026", "", nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("total status = %d, want %d: %s", rec.Code, http.StatusOK, rec.Body)
+	}
+	var total models.TotalCostResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &total); err != nil {
+		t.Fatalf("decode total: %v", err)
+	}
+	if total.TotalCost != 3*49900 || total.Currency != models.DefaultCurrency {
+		t.Errorf("total = %d %s, want %d %s", total.TotalCost, total.Currency, 3*49900, models.DefaultCurrency)
+	}
+}
+
+func TestListSubscriptionsInvalidCursor(t *testing.T) {
+	rec := serve(newTestMux(), http.MethodGet, "/subscriptions?cursor=bogus", "", nil)
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("status = %d, want %d: %s", rec.Code, http.StatusBadRequest, rec.Body)
+	}
+
+	var details problem.Details
+	if err := json.Unmarshal(rec.Body.Bytes(), &details); err != nil {
+		t.Fatalf("decode problem: %v", err)
+	}
+	if len(details.Violations) != 1 || details.Violations[0].Field != "cursor" || details.Violations[0].Code != models.ErrInvalidCursor.ErrorCode() {
+		t.Errorf("violations = %+v, want cursor %s", details.Violations, models.ErrInvalidCursor.ErrorCode())
+	}
+}
@@ -0,0 +1,26 @@
+package middleware
+
+import (
+	"crypto/subtle"
+	"net/http"
+
+	apperrors "github.com/Gilf4/effective-mobile-task/internal/errors"
+	"github.com/Gilf4/effective-mobile-task/internal/http/problem"
+)
+
+// AdminToken пропускает только запросы с заголовком X-Admin-Token, совпадающим с token
+// Если token не задан, административные маршруты недоступны
+func AdminToken(token string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			provided := r.Header.Get("X-Admin-Token")
+			if token == "" || subtle.ConstantTimeCompare([]byte(provided), []byte(token)) != 1 {
+				appErr := apperrors.NewForbidden("admin access required", nil)
+				problem.Write(w, problem.New(appErr, r.URL.Path, RequestIDFromContext(r.Context())))
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -0,0 +1,124 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"github.com/Gilf4/effective-mobile-task/internal/auth"
+	apperrors "github.com/Gilf4/effective-mobile-task/internal/errors"
+	"github.com/Gilf4/effective-mobile-task/internal/http/problem"
+	"github.com/Gilf4/effective-mobile-task/internal/logger"
+)
+
+const (
+	schemeBearer = "Bearer"
+	schemeAPIKey = "ApiKey"
+)
+
+var errInsufficientScope = apperrors.Coded("insufficient_scope", "credentials do not grant the scope required by this route")
+
+// TokenVerifier проверяет bearer-токен и возвращает автора запроса
+type TokenVerifier interface {
+	Verify(token string) (auth.Principal, error)
+}
+
+// APIKeyAuthenticator проверяет API-ключ и возвращает автора запроса
+type APIKeyAuthenticator interface {
+	AuthenticateAPIKey(ctx context.Context, key string) (auth.Principal, error)
+}
+
+// Authenticate возвращает фабрику middleware, пропускающих только запросы с действительными
+// учетными данными и нужной областью доступа scope. Принимаются заголовки Authorization: Bearer <JWT>,
+// если задан tokens, и Authorization: ApiKey <key>, если задан keys. Автор запроса кладется в контекст,
+// subject добавляется к атрибутам логов
+func Authenticate(tokens TokenVerifier, keys APIKeyAuthenticator, log *slog.Logger) func(scope auth.Scope) func(http.Handler) http.Handler {
+	a := &authenticator{tokens: tokens, keys: keys, log: log}
+
+	return func(scope auth.Scope) func(http.Handler) http.Handler {
+		return func(next http.Handler) http.Handler {
+			return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				principal, ok := a.authenticate(w, r)
+				if !ok {
+					return
+				}
+
+				ctx := logger.WithAttrs(r.Context(), slog.String("subject", principal.Subject))
+
+				if !principal.HasScope(scope) {
+					a.log.WarnContext(ctx, "insufficient scope", slog.String("scope", string(scope)))
+					appErr := apperrors.NewForbidden(errInsufficientScope.Error(), errInsufficientScope)
+					problem.Write(w, problem.New(appErr, r.URL.Path, RequestIDFromContext(ctx)))
+					return
+				}
+
+				next.ServeHTTP(w, r.WithContext(auth.WithPrincipal(ctx, principal)))
+			})
+		}
+	}
+}
+
+type authenticator struct {
+	tokens TokenVerifier
+	keys   APIKeyAuthenticator
+	log    *slog.Logger
+}
+
+// authenticate определяет автора запроса по заголовку Authorization
+// При ошибке ответ уже отправлен клиенту и возвращается false
+func (a *authenticator) authenticate(w http.ResponseWriter, r *http.Request) (auth.Principal, bool) {
+	scheme, credentials, _ := strings.Cut(r.Header.Get("Authorization"), " ")
+	credentials = strings.TrimSpace(credentials)
+
+	switch {
+	case credentials == "":
+	case a.tokens != nil && strings.EqualFold(scheme, schemeBearer):
+		principal, err := a.tokens.Verify(credentials)
+		if err != nil {
+			a.log.WarnContext(r.Context(), "token rejected", "error", err)
+			unauthorized(w, r, schemeBearer+` error="invalid_token"`, "invalid token")
+			return auth.Principal{}, false
+		}
+		return principal, true
+	case a.keys != nil && strings.EqualFold(scheme, schemeAPIKey):
+		principal, err := a.keys.AuthenticateAPIKey(r.Context(), credentials)
+		if err != nil {
+			if !errors.Is(err, auth.ErrInvalidAPIKey) {
+				a.log.ErrorContext(r.Context(), "failed to authenticate api key", "error", err)
+				var appErr *apperrors.AppError
+				if !errors.As(err, &appErr) {
+					appErr = apperrors.NewInternal(err)
+				}
+				problem.Write(w, problem.New(appErr, r.URL.Path, RequestIDFromContext(r.Context())))
+				return auth.Principal{}, false
+			}
+			a.log.WarnContext(r.Context(), "api key rejected", "error", err)
+			unauthorized(w, r, schemeAPIKey, "invalid api key")
+			return auth.Principal{}, false
+		}
+		return principal, true
+	}
+
+	unauthorized(w, r, a.challenge(), "authentication required")
+	return auth.Principal{}, false
+}
+
+// challenge перечисляет принимаемые схемы аутентификации для заголовка WWW-Authenticate
+func (a *authenticator) challenge() string {
+	var schemes []string
+	if a.tokens != nil {
+		schemes = append(schemes, schemeBearer)
+	}
+	if a.keys != nil {
+		schemes = append(schemes, schemeAPIKey)
+	}
+	return strings.Join(schemes, ", ")
+}
+
+func unauthorized(w http.ResponseWriter, r *http.Request, challenge, message string) {
+	w.Header().Set("WWW-Authenticate", challenge)
+	appErr := apperrors.NewUnauthorized(message, nil)
+	problem.Write(w, problem.New(appErr, r.URL.Path, RequestIDFromContext(r.Context())))
+}
@@ -0,0 +1,42 @@
+package middleware
+
+import (
+	"net/http"
+	"strings"
+	"time"
+)
+
+// RequestObserver учитывает обработанные HTTP-запросы
+type RequestObserver interface {
+	ObserveRequest(method, route string, status int, duration time.Duration)
+}
+
+// unmatchedRoute - метка запросов, не совпавших ни с одним маршрутом;
+// путь таких запросов не используется, чтобы не раздувать число серий
+const unmatchedRoute = "unmatched"
+
+// Metrics передает в obs метод, шаблон маршрута, статус и длительность каждого запроса
+// Шаблон маршрута ServeMux записывает в r.Pattern при выборе обработчика
+func Metrics(obs RequestObserver) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+
+			rw := &responseWriter{ResponseWriter: w, statusCode: http.StatusOK}
+			next.ServeHTTP(rw, r)
+
+			obs.ObserveRequest(r.Method, routeLabel(r.Pattern), rw.statusCode, time.Since(start))
+		})
+	}
+}
+
+// routeLabel возвращает путь шаблона маршрута без метода, например /subscriptions/{id}
+func routeLabel(pattern string) string {
+	if pattern == "" {
+		return unmatchedRoute
+	}
+	if _, path, ok := strings.Cut(pattern, " "); ok {
+		return path
+	}
+	return pattern
+}
@@ -0,0 +1,90 @@
+package middleware
+
+import (
+	"log/slog"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/Gilf4/effective-mobile-task/internal/auth"
+	apperrors "github.com/Gilf4/effective-mobile-task/internal/errors"
+	"github.com/Gilf4/effective-mobile-task/internal/http/problem"
+	"github.com/Gilf4/effective-mobile-task/internal/ratelimit"
+)
+
+var errRateLimited = apperrors.Coded("rate_limited", "rate limit exceeded, retry later")
+
+// RateLimit ограничивает частоту запросов к группе маршрутов group по политике policy
+// Запросы считаются отдельно для каждого клиента: аутентифицированного - по subject
+// (API-ключ или JWT), иначе - по IP-адресу. Поэтому middleware ставится после аутентификации.
+// Ответы содержат заголовки RateLimit-Limit, RateLimit-Remaining, RateLimit-Reset и RateLimit-Policy,
+// отклоненные запросы - 429 и Retry-After. При ошибке лимитера запрос пропускается
+func RateLimit(limiter ratelimit.Limiter, group string, policy ratelimit.Policy, log *slog.Logger) func(http.Handler) http.Handler {
+	return rateLimit(limiter, group, policy, clientKey, log)
+}
+
+// RateLimitByIP работает как RateLimit, но всегда считает запросы по IP-адресу соединения.
+// Ставится перед аутентификацией, чтобы ограничивать и запросы без учетных данных или с неверными
+func RateLimitByIP(limiter ratelimit.Limiter, group string, policy ratelimit.Policy, log *slog.Logger) func(http.Handler) http.Handler {
+	return rateLimit(limiter, group, policy, ipKey, log)
+}
+
+func rateLimit(limiter ratelimit.Limiter, group string, policy ratelimit.Policy, key func(*http.Request) string, log *slog.Logger) func(http.Handler) http.Handler {
+	if policy.Unlimited() {
+		return func(next http.Handler) http.Handler { return next }
+	}
+
+	policyHeader := strconv.Itoa(policy.Limit) + ";w=" + strconv.Itoa(int(policy.Period.Seconds()))
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx := r.Context()
+
+			res, err := limiter.Allow(ctx, group+"|"+key(r), policy)
+			if err != nil {
+				log.ErrorContext(ctx, "rate limiter failed", "error", err)
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			h := w.Header()
+			h.Set("RateLimit-Limit", strconv.Itoa(res.Limit))
+			h.Set("RateLimit-Remaining", strconv.Itoa(res.Remaining))
+			h.Set("RateLimit-Reset", strconv.Itoa(ceilSeconds(res.Reset)))
+			h.Set("RateLimit-Policy", policyHeader)
+
+			if !res.Allowed {
+				log.WarnContext(ctx, "rate limit exceeded", slog.String("group", group))
+				h.Set("Retry-After", strconv.Itoa(ceilSeconds(res.RetryAfter)))
+				appErr := apperrors.NewTooManyRequests(errRateLimited.Error(), errRateLimited)
+				problem.Write(w, problem.New(appErr, r.URL.Path, RequestIDFromContext(ctx)))
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// clientKey определяет клиента запроса: subject автора запроса или IP-адрес соединения
+func clientKey(r *http.Request) string {
+	if p, ok := auth.FromContext(r.Context()); ok {
+		return "sub:" + p.Subject
+	}
+	return ipKey(r)
+}
+
+// ipKey определяет клиента запроса по IP-адресу соединения
+func ipKey(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	return "ip:" + host
+}
+
+func ceilSeconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
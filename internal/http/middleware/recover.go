@@ -0,0 +1,57 @@
+package middleware
+
+import (
+	"log/slog"
+	"net/http"
+	"runtime/debug"
+
+	apperrors "github.com/Gilf4/effective-mobile-task/internal/errors"
+	"github.com/Gilf4/effective-mobile-task/internal/http/problem"
+)
+
+// PanicObserver учитывает паники, перехваченные при обработке запросов
+type PanicObserver interface {
+	ObservePanic(method, route string)
+}
+
+// Recover перехватывает панику обработчика, пишет в лог её значение и стек
+// с атрибутами запроса и отвечает 500 в том же формате, что и handleError.
+// Если ответ уже начат, соединение просто завершается без тела ошибки.
+// http.ErrAbortHandler пробрасывается дальше, чтобы сервер прервал ответ как обычно
+func Recover(log *slog.Logger, obs PanicObserver) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			rw := &responseWriter{ResponseWriter: w, statusCode: http.StatusOK}
+
+			defer func() {
+				rec := recover()
+				if rec == nil {
+					return
+				}
+				if rec == http.ErrAbortHandler {
+					panic(rec)
+				}
+
+				route := routeLabel(r.Pattern)
+				obs.ObservePanic(r.Method, route)
+
+				log.ErrorContext(r.Context(), "panic recovered",
+					slog.Any("panic", rec),
+					slog.String("method", r.Method),
+					slog.String("path", r.URL.Path),
+					slog.String("route", route),
+					slog.String("stack", string(debug.Stack())),
+				)
+
+				if rw.wroteHeader {
+					panic(http.ErrAbortHandler)
+				}
+
+				appErr := apperrors.NewInternal(nil)
+				problem.Write(w, problem.New(appErr, r.URL.Path, RequestIDFromContext(r.Context())))
+			}()
+
+			next.ServeHTTP(rw, r)
+		})
+	}
+}
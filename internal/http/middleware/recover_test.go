@@ -0,0 +1,82 @@
+package middleware
+
+import (
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sync"
+	"testing"
+
+	apperrors "github.com/Gilf4/effective-mobile-task/internal/errors"
+	"github.com/Gilf4/effective-mobile-task/internal/http/problem"
+)
+
+type panicCounter struct {
+	mu     sync.Mutex
+	counts map[string]int
+}
+
+func (c *panicCounter) ObservePanic(method, route string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.counts[method+" "+route]++
+}
+
+func (c *panicCounter) count(method, route string) int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.counts[method+" "+route]
+}
+
+func TestRecoverKeepsServingAfterPanic(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /panic", func(w http.ResponseWriter, r *http.Request) {
+		panic("boom")
+	})
+	mux.HandleFunc("GET /ok", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	})
+
+	obs := &panicCounter{counts: make(map[string]int)}
+	log := slog.New(slog.NewTextHandler(io.Discard, nil))
+	h := Recover(log, obs)(mux)
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/panic", nil))
+
+	if rec.Code != http.StatusInternalServerError {
+		t.Fatalf("panic status = %d, want %d", rec.Code, http.StatusInternalServerError)
+	}
+	if ct := rec.Header().Get("Content-Type"); ct != problem.ContentType {
+		t.Errorf("panic Content-Type = %q, want %q", ct, problem.ContentType)
+	}
+
+	var got problem.Details
+	if err := json.Unmarshal(rec.Body.Bytes(), &got); err != nil {
+		t.Fatalf("decode panic body: %v", err)
+	}
+	want := problem.New(apperrors.NewInternal(nil), "/panic", "")
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("panic body = %+v, want %+v", got, want)
+	}
+
+	if n := obs.count(http.MethodGet, "/panic"); n != 1 {
+		t.Errorf("panics observed = %d, want 1", n)
+	}
+
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/ok", nil))
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("ok status = %d, want %d", rec.Code, http.StatusOK)
+	}
+	if body := rec.Body.String(); body != "ok" {
+		t.Errorf("ok body = %q, want %q", body, "ok")
+	}
+	if n := obs.count(http.MethodGet, "/panic"); n != 1 {
+		t.Errorf("panics observed after normal request = %d, want 1", n)
+	}
+}
@@ -0,0 +1,97 @@
+package middleware
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"regexp"
+
+	"github.com/Gilf4/effective-mobile-task/internal/logger"
+	"github.com/google/uuid"
+)
+
+const (
+	RequestIDHeader   = "X-Request-ID"
+	TraceparentHeader = "traceparent"
+)
+
+// maxRequestIDLength ограничивает длину идентификатора запроса, принятого от клиента
+const maxRequestIDLength = 128
+
+// requestIDPattern - допустимые символы идентификатора запроса от клиента
+var requestIDPattern = regexp.MustCompile(`^[A-Za-z0-9._:/+=-]+$`)
+
+// traceparentPattern - заголовок W3C Trace Context: version-trace_id-parent_id-flags
+var traceparentPattern = regexp.MustCompile(`^([0-9a-f]{2})-([0-9a-f]{32})-([0-9a-f]{16})-([0-9a-f]{2})(-.*)?$`)
+
+// TraceContext - контекст распределенной трассировки из заголовка traceparent
+type TraceContext struct {
+	TraceID  string
+	ParentID string
+	Flags    string
+}
+
+type requestIDKey struct{}
+
+type traceKey struct{}
+
+// RequestIDFromContext возвращает идентификатор текущего запроса
+func RequestIDFromContext(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+// TraceFromContext возвращает контекст трассировки текущего запроса, если клиент его передал
+func TraceFromContext(ctx context.Context) (TraceContext, bool) {
+	tc, ok := ctx.Value(traceKey{}).(TraceContext)
+	return tc, ok
+}
+
+// RequestID принимает идентификатор запроса из X-Request-ID или генерирует новый,
+// разбирает заголовок traceparent и сохраняет оба значения в контексте запроса.
+// Идентификаторы добавляются к атрибутам логов контекста и возвращаются в X-Request-ID ответа
+func RequestID() func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			id := r.Header.Get(RequestIDHeader)
+			if len(id) > maxRequestIDLength || !requestIDPattern.MatchString(id) {
+				id = uuid.NewString()
+			}
+
+			ctx := context.WithValue(r.Context(), requestIDKey{}, id)
+			attrs := []slog.Attr{slog.String("request_id", id)}
+
+			if tc, ok := parseTraceparent(r.Header.Get(TraceparentHeader)); ok {
+				ctx = context.WithValue(ctx, traceKey{}, tc)
+				attrs = append(attrs,
+					slog.String("trace_id", tc.TraceID),
+					slog.String("parent_id", tc.ParentID),
+				)
+			}
+
+			w.Header().Set(RequestIDHeader, id)
+			next.ServeHTTP(w, r.WithContext(logger.WithAttrs(ctx, attrs...)))
+		})
+	}
+}
+
+// parseTraceparent разбирает заголовок traceparent по спецификации W3C Trace Context
+// Нулевые идентификаторы и версия ff считаются недействительными
+func parseTraceparent(header string) (TraceContext, bool) {
+	m := traceparentPattern.FindStringSubmatch(header)
+	if m == nil {
+		return TraceContext{}, false
+	}
+
+	version, traceID, parentID, flags, rest := m[1], m[2], m[3], m[4], m[5]
+	switch {
+	case version == "ff":
+		return TraceContext{}, false
+	case version == "00" && rest != "":
+		return TraceContext{}, false
+	case traceID == "00000000000000000000000000000000", parentID == "0000000000000000":
+		return TraceContext{}, false
+	}
+
+	return TraceContext{TraceID: traceID, ParentID: parentID, Flags: flags}, true
+}
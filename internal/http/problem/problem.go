@@ -0,0 +1,48 @@
+package problem
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/Gilf4/effective-mobile-task/internal/errors"
+)
+
+// ContentType - тип содержимого ответа об ошибке по RFC 7807
+const ContentType = "application/problem+json"
+
+// Details - тело ответа об ошибке по RFC 7807
+// Code - стабильный машиночитаемый код ошибки, по которому клиенту следует ветвиться.
+// Error дублирует detail для клиентов, разбирающих прежний формат {"error": "..."}
+type Details struct {
+	Type       string             `json:"type"`
+	Title      string             `json:"title"`
+	Status     int                `json:"status"`
+	Detail     string             `json:"detail"`
+	Instance   string             `json:"instance,omitempty"`
+	Code       string             `json:"code"`
+	RequestID  string             `json:"request_id,omitempty"`
+	Violations []errors.Violation `json:"violations,omitempty"`
+	Error      string             `json:"error"`
+}
+
+// New описывает ошибку приложения appErr, возникшую при обработке ресурса instance
+func New(appErr *errors.AppError, instance, requestID string) Details {
+	return Details{
+		Type:       appErr.Type(),
+		Title:      http.StatusText(appErr.Code),
+		Status:     appErr.Code,
+		Detail:     appErr.Message,
+		Instance:   instance,
+		Code:       appErr.ErrorCode,
+		RequestID:  requestID,
+		Violations: appErr.Violations,
+		Error:      appErr.Message,
+	}
+}
+
+// Write отправляет описание ошибки клиенту
+func Write(w http.ResponseWriter, d Details) {
+	w.Header().Set("Content-Type", ContentType)
+	w.WriteHeader(d.Status)
+	json.NewEncoder(w).Encode(d)
+}
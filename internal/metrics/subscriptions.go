@@ -0,0 +1,52 @@
+package metrics
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// collectTimeout ограничивает время запроса к хранилищу при сборе бизнес-метрик
+const collectTimeout = 5 * time.Second
+
+// ActiveSubscriptionsCollector отдает количество действующих подписок по сервисам
+// Значения читаются из хранилища при каждом сборе метрик
+type ActiveSubscriptionsCollector struct {
+	count func(ctx context.Context) (map[string]int64, error)
+	log   *slog.Logger
+	desc  *prometheus.Desc
+}
+
+func NewActiveSubscriptionsCollector(count func(ctx context.Context) (map[string]int64, error), log *slog.Logger) *ActiveSubscriptionsCollector {
+	return &ActiveSubscriptionsCollector{
+		count: count,
+		log:   log,
+		desc: prometheus.NewDesc(
+			"subscriptions_active",
+			"Number of non-archived subscriptions active in the current month by service name.",
+			[]string{"service_name"}, nil,
+		),
+	}
+}
+
+func (c *ActiveSubscriptionsCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.desc
+}
+
+func (c *ActiveSubscriptionsCollector) Collect(ch chan<- prometheus.Metric) {
+	ctx, cancel := context.WithTimeout(context.Background(), collectTimeout)
+	defer cancel()
+
+	counts, err := c.count(ctx)
+	if err != nil {
+		c.log.Error("failed to collect active subscriptions", "err", err)
+		ch <- prometheus.NewInvalidMetric(c.desc, err)
+		return
+	}
+
+	for serviceName, count := range counts {
+		ch <- prometheus.MustNewConstMetric(c.desc, prometheus.GaugeValue, float64(count), serviceName)
+	}
+}
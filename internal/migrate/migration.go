@@ -0,0 +1,89 @@
+package migrate
+
+import (
+	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	upMarker   = "-- +goose Up"
+	downMarker = "-- +goose Down"
+)
+
+// Migration - одна миграция схемы в формате goose: VERSION_name.sql с разделами Up и Down
+type Migration struct {
+	Version int64
+	Name    string
+	Up      string
+	Down    string
+}
+
+// Load читает миграции *.sql из корня fsys и возвращает их по возрастанию версии
+func Load(fsys fs.FS) ([]Migration, error) {
+	files, err := fs.Glob(fsys, "*.sql")
+	if err != nil {
+		return nil, err
+	}
+
+	migrations := make([]Migration, 0, len(files))
+	seen := make(map[int64]string, len(files))
+
+	for _, file := range files {
+		name := path.Base(file)
+
+		prefix, _, ok := strings.Cut(name, "_")
+		if !ok {
+			return nil, fmt.Errorf("migration %s: name must be VERSION_description.sql", name)
+		}
+		version, err := strconv.ParseInt(prefix, 10, 64)
+		if err != nil || version <= 0 {
+			return nil, fmt.Errorf("migration %s: invalid version %q", name, prefix)
+		}
+		if other, ok := seen[version]; ok {
+			return nil, fmt.Errorf("migration %s: duplicate version of %s", name, other)
+		}
+		seen[version] = name
+
+		content, err := fs.ReadFile(fsys, file)
+		if err != nil {
+			return nil, err
+		}
+
+		up, down, err := parse(string(content))
+		if err != nil {
+			return nil, fmt.Errorf("migration %s: %w", name, err)
+		}
+
+		migrations = append(migrations, Migration{Version: version, Name: name, Up: up, Down: down})
+	}
+
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+
+	return migrations, nil
+}
+
+// parse разделяет содержимое миграции на SQL разделов Up и Down
+func parse(content string) (up, down string, err error) {
+	_, rest, ok := strings.Cut(content, upMarker)
+	if !ok {
+		return "", "", fmt.Errorf("missing %q section", upMarker)
+	}
+
+	up, down, _ = strings.Cut(rest, downMarker)
+
+	return strings.TrimSpace(up), strings.TrimSpace(down), nil
+}
+
+// Latest возвращает версию последней миграции или 0, если миграций нет
+func Latest(migrations []Migration) int64 {
+	if len(migrations) == 0 {
+		return 0
+	}
+	return migrations[len(migrations)-1].Version
+}
@@ -0,0 +1,266 @@
+package migrate
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// ErrSchemaTooNew возвращается, если в базе применены миграции, неизвестные приложению
+var ErrSchemaTooNew = errors.New("database schema is newer than the application")
+
+// advisoryLockID - ключ advisory lock Postgres, под которым реплики применяют миграции по очереди
+const advisoryLockID int64 = 7_294_018_335_112_640_117
+
+// versionTable - таблица учета миграций goose; использование того же формата позволяет
+// продолжить работу с базами, схема которых применялась утилитой goose
+const versionTable = "goose_db_version"
+
+// Status - состояние миграции в базе
+type Status struct {
+	Migration
+	AppliedAt *time.Time
+}
+
+// Migrator применяет встроенные миграции к Postgres
+type Migrator struct {
+	pool       *pgxpool.Pool
+	migrations []Migration
+}
+
+func New(pool *pgxpool.Pool, migrations []Migration) *Migrator {
+	return &Migrator{pool: pool, migrations: migrations}
+}
+
+// Up применяет все непримененные миграции по возрастанию версии и возвращает примененные
+// Каждая миграция выполняется в отдельной транзакции; параллельные вызовы из разных реплик
+// сериализуются advisory lock
+func (m *Migrator) Up(ctx context.Context) ([]Migration, error) {
+	var applied []Migration
+
+	err := m.withLock(ctx, func(conn *pgxpool.Conn) error {
+		versions, err := appliedVersions(ctx, conn)
+		if err != nil {
+			return err
+		}
+		if err := m.checkKnown(versions); err != nil {
+			return err
+		}
+
+		for _, mig := range m.migrations {
+			if _, ok := versions[mig.Version]; ok {
+				continue
+			}
+
+			err := pgx.BeginFunc(ctx, conn, func(tx pgx.Tx) error {
+				if _, err := tx.Exec(ctx, mig.Up); err != nil {
+					return err
+				}
+				_, err := tx.Exec(ctx, `INSERT INTO `+versionTable+` (version_id, is_applied) VALUES ($1, TRUE)`, mig.Version)
+				return err
+			})
+			if err != nil {
+				return fmt.Errorf("apply %s: %w", mig.Name, err)
+			}
+
+			applied = append(applied, mig)
+		}
+
+		return nil
+	})
+
+	return applied, err
+}
+
+// Down откатывает последнюю примененную миграцию и возвращает её
+// Если примененных миграций нет, возвращается nil
+func (m *Migrator) Down(ctx context.Context) (*Migration, error) {
+	var reverted *Migration
+
+	err := m.withLock(ctx, func(conn *pgxpool.Conn) error {
+		versions, err := appliedVersions(ctx, conn)
+		if err != nil {
+			return err
+		}
+		if err := m.checkKnown(versions); err != nil {
+			return err
+		}
+
+		for i := len(m.migrations) - 1; i >= 0; i-- {
+			mig := m.migrations[i]
+			if _, ok := versions[mig.Version]; !ok {
+				continue
+			}
+
+			err := pgx.BeginFunc(ctx, conn, func(tx pgx.Tx) error {
+				if _, err := tx.Exec(ctx, mig.Down); err != nil {
+					return err
+				}
+				_, err := tx.Exec(ctx, `DELETE FROM `+versionTable+` WHERE version_id = $1`, mig.Version)
+				return err
+			})
+			if err != nil {
+				return fmt.Errorf("revert %s: %w", mig.Name, err)
+			}
+
+			reverted = &mig
+			return nil
+		}
+
+		return nil
+	})
+
+	return reverted, err
+}
+
+// Status возвращает все известные приложению миграции с временем применения
+func (m *Migrator) Status(ctx context.Context) ([]Status, error) {
+	conn, err := m.pool.Acquire(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Release()
+
+	versions, err := appliedVersions(ctx, conn)
+	if err != nil {
+		return nil, err
+	}
+
+	statuses := make([]Status, 0, len(m.migrations))
+	for _, mig := range m.migrations {
+		status := Status{Migration: mig}
+		if appliedAt, ok := versions[mig.Version]; ok {
+			status.AppliedAt = &appliedAt
+		}
+		statuses = append(statuses, status)
+	}
+
+	return statuses, nil
+}
+
+// Pending возвращает количество непримененных миграций
+// Если в базе есть миграции новее известных приложению, возвращается ErrSchemaTooNew
+func (m *Migrator) Pending(ctx context.Context) (int, error) {
+	conn, err := m.pool.Acquire(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer conn.Release()
+
+	versions, err := appliedVersions(ctx, conn)
+	if err != nil {
+		return 0, err
+	}
+	if err := m.checkKnown(versions); err != nil {
+		return 0, err
+	}
+
+	pending := 0
+	for _, mig := range m.migrations {
+		if _, ok := versions[mig.Version]; !ok {
+			pending++
+		}
+	}
+
+	return pending, nil
+}
+
+// checkKnown проверяет, что база не содержит миграций новее последней известной приложению
+func (m *Migrator) checkKnown(versions map[int64]time.Time) error {
+	latest := Latest(m.migrations)
+	for version := range versions {
+		if version > latest {
+			return fmt.Errorf("%w: applied version %d, latest known %d", ErrSchemaTooNew, version, latest)
+		}
+	}
+	return nil
+}
+
+// withLock выполняет fn на выделенном соединении, удерживая advisory lock миграций
+func (m *Migrator) withLock(ctx context.Context, fn func(conn *pgxpool.Conn) error) error {
+	conn, err := m.pool.Acquire(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Release()
+
+	if _, err := conn.Exec(ctx, `SELECT pg_advisory_lock($1)`, advisoryLockID); err != nil {
+		return fmt.Errorf("acquire migration lock: %w", err)
+	}
+	defer conn.Exec(context.WithoutCancel(ctx), `SELECT pg_advisory_unlock($1)`, advisoryLockID)
+
+	if err := ensureVersionTable(ctx, conn); err != nil {
+		return err
+	}
+
+	return fn(conn)
+}
+
+// ensureVersionTable создает таблицу учета миграций в формате goose, если её нет
+func ensureVersionTable(ctx context.Context, conn *pgxpool.Conn) error {
+	return pgx.BeginFunc(ctx, conn, func(tx pgx.Tx) error {
+		var exists bool
+		if err := tx.QueryRow(ctx, `SELECT to_regclass($1) IS NOT NULL`, versionTable).Scan(&exists); err != nil {
+			return err
+		}
+		if exists {
+			return nil
+		}
+
+		_, err := tx.Exec(ctx, `
+			CREATE TABLE `+versionTable+` (
+			  id SERIAL PRIMARY KEY,
+			  version_id BIGINT NOT NULL,
+			  is_applied BOOLEAN NOT NULL,
+			  tstamp TIMESTAMP NOT NULL DEFAULT now()
+			);
+			INSERT INTO `+versionTable+` (version_id, is_applied) VALUES (0, TRUE);
+		`)
+		return err
+	})
+}
+
+// appliedVersions возвращает примененные версии со временем применения
+// Для каждой версии учитывается последняя запись таблицы; нулевая версия goose пропускается
+func appliedVersions(ctx context.Context, conn *pgxpool.Conn) (map[int64]time.Time, error) {
+	var exists bool
+	if err := conn.QueryRow(ctx, `SELECT to_regclass($1) IS NOT NULL`, versionTable).Scan(&exists); err != nil {
+		return nil, err
+	}
+
+	versions := make(map[int64]time.Time)
+	if !exists {
+		return versions, nil
+	}
+
+	rows, err := conn.Query(ctx, `
+		SELECT DISTINCT ON (version_id) version_id, is_applied, tstamp
+		FROM `+versionTable+`
+		WHERE version_id > 0
+		ORDER BY version_id, id DESC
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			version   int64
+			isApplied bool
+			appliedAt time.Time
+		)
+		if err := rows.Scan(&version, &isApplied, &appliedAt); err != nil {
+			return nil, err
+		}
+		if isApplied {
+			versions[version] = appliedAt
+		}
+	}
+
+	return versions, rows.Err()
+}
@@ -0,0 +1,41 @@
+package models
+
+import (
+	"time"
+
+	apperrors "github.com/Gilf4/effective-mobile-task/internal/errors"
+	"github.com/google/uuid"
+)
+
+var (
+	ErrAPIKeyNotFound     = apperrors.Coded("api_key_not_found", "api key not found")
+	ErrAPIKeyNameRequired = apperrors.Coded("api_key_name_required", "api key name is required")
+	ErrScopesRequired     = apperrors.Coded("scopes_required", "at least one scope is required")
+	ErrInvalidScope       = apperrors.Coded("invalid_scope", "scope must be one of: subscriptions:read, subscriptions:write, reports:read")
+	ErrAPIKeyExpiresAt    = apperrors.Coded("invalid_expires_at", "expires_at must be in the future")
+)
+
+// APIKey - ключ доступа сервисных клиентов. Хранится только хеш ключа (KeyHash),
+// сам ключ показывается один раз при выпуске
+type APIKey struct {
+	ID         uuid.UUID
+	Name       string
+	KeyHash    string
+	Scopes     []string
+	ExpiresAt  *time.Time
+	RevokedAt  *time.Time
+	LastUsedAt *time.Time
+	CreatedAt  time.Time
+}
+
+// Active сообщает, что ключ не отозван и не истек к моменту now
+func (k *APIKey) Active(now time.Time) bool {
+	return k.RevokedAt == nil && (k.ExpiresAt == nil || now.Before(*k.ExpiresAt))
+}
+
+// IssueAPIKeyRequest - параметры выпуска API-ключа. Без ExpiresAt ключ бессрочный
+type IssueAPIKeyRequest struct {
+	Name      string
+	Scopes    []string
+	ExpiresAt *time.Time
+}
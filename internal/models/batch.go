@@ -0,0 +1,50 @@
+package models
+
+import (
+	apperrors "github.com/Gilf4/effective-mobile-task/internal/errors"
+	"github.com/google/uuid"
+)
+
+// MaxBatchSize - максимальное количество элементов в одном пакетном запросе
+const MaxBatchSize = 1000
+
+var (
+	ErrEmptyBatch       = apperrors.Coded("empty_batch", "batch must contain at least one item")
+	ErrBatchTooLarge    = apperrors.Coded("batch_too_large", "batch cannot exceed 1000 items")
+	ErrInvalidBatchMode = apperrors.Coded("invalid_batch_mode", "mode must be one of: atomic, partial")
+	ErrBatchRolledBack  = apperrors.Coded("batch_rolled_back", "not applied: batch was rolled back because another item failed")
+)
+
+// BatchMode задает поведение пакетной операции при ошибке в одном из элементов
+type BatchMode string
+
+const (
+	// BatchModeAtomic - все или ничего: при любой ошибке изменения не сохраняются
+	BatchModeAtomic BatchMode = "atomic"
+	// BatchModePartial - успешные элементы сохраняются, ошибочные пропускаются
+	BatchModePartial BatchMode = "partial"
+)
+
+func (m BatchMode) Validate() error {
+	switch m {
+	case BatchModeAtomic, BatchModePartial:
+		return nil
+	}
+	return ErrInvalidBatchMode
+}
+
+type BatchItemResult struct {
+	Index        int                   `json:"index"`
+	Status       int                   `json:"status"`
+	ID           *uuid.UUID            `json:"id,omitempty"`
+	Subscription *SubscriptionResponse `json:"subscription,omitempty"`
+	Error        string                `json:"error,omitempty"`
+	Code         string                `json:"code,omitempty"`
+}
+
+type BatchResponse struct {
+	Mode      BatchMode         `json:"mode"`
+	Succeeded int               `json:"succeeded"`
+	Failed    int               `json:"failed"`
+	Results   []BatchItemResult `json:"results"`
+}
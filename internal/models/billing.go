@@ -0,0 +1,100 @@
+package models
+
+import (
+	"time"
+
+	apperrors "github.com/Gilf4/effective-mobile-task/internal/errors"
+)
+
+var (
+	ErrInvalidBillingPeriod = apperrors.Coded("invalid_billing_period", "billing_period must be one of: month, quarter, year, week")
+	ErrPartialBillingPeriod = apperrors.Coded("partial_billing_period", "subscription must last a whole number of billing periods: end_date does not fall on the last month of a billing period")
+)
+
+// BillingPeriod - периодичность списаний по подписке. Price - стоимость одного периода
+type BillingPeriod string
+
+const (
+	BillingPeriodMonth   BillingPeriod = "month"
+	BillingPeriodQuarter BillingPeriod = "quarter"
+	BillingPeriodYear    BillingPeriod = "year"
+	BillingPeriodWeek    BillingPeriod = "week"
+)
+
+// daysPerWeek - длина недельного периода в днях
+const daysPerWeek = 7
+
+func (p BillingPeriod) Validate() error {
+	switch p {
+	case BillingPeriodMonth, BillingPeriodQuarter, BillingPeriodYear, BillingPeriodWeek:
+		return nil
+	}
+	return ErrInvalidBillingPeriod
+}
+
+// Months возвращает длину периода в месяцах; для недельного периода - 0
+func (p BillingPeriod) Months() int {
+	switch p {
+	case BillingPeriodQuarter:
+		return 3
+	case BillingPeriodYear:
+		return 12
+	case BillingPeriodWeek:
+		return 0
+	}
+	return 1
+}
+
+// ValidateTerm проверяет, что подписка с началом start и последним месяцем end
+// длится целое число периодов. Недельные подписки не выравниваются по месяцам и не проверяются
+func (p BillingPeriod) ValidateTerm(start, end time.Time) error {
+	n := p.Months()
+	if n <= 1 {
+		return nil
+	}
+	if (monthNumber(end)-monthNumber(start)+1)%n != 0 {
+		return ErrPartialBillingPeriod
+	}
+	return nil
+}
+
+// Charges считает списания подписки, начавшейся start, с датами в интервале from..to включительно
+// Списания приходятся на start и далее через каждый период. Подписки начинаются с первого числа
+// месяца, поэтому для помесячных периодов from и to сравниваются с точностью до месяца, для недельных - до дня
+func (p BillingPeriod) Charges(start, from, to time.Time) int {
+	if from.Before(start) {
+		from = start
+	}
+	if to.Before(from) {
+		return 0
+	}
+
+	if p == BillingPeriodWeek {
+		first := ceilDiv(dayNumber(from)-dayNumber(start), daysPerWeek)
+		last := (dayNumber(to) - dayNumber(start)) / daysPerWeek
+		return max(0, last-first+1)
+	}
+
+	n := p.Months()
+	first := ceilDiv(monthNumber(from)-monthNumber(start), n)
+	last := (monthNumber(to) - monthNumber(start)) / n
+	return max(0, last-first+1)
+}
+
+// ChargesInMonth считает списания подписки в календарном месяце month
+func (p BillingPeriod) ChargesInMonth(start, month time.Time) int {
+	return p.Charges(start, month, month.AddDate(0, 1, -1))
+}
+
+func monthNumber(t time.Time) int {
+	return t.Year()*12 + int(t.Month())
+}
+
+func dayNumber(t time.Time) int {
+	return int(time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC).Unix() / 86400)
+}
+
+// ceilDiv делит неотрицательное a на положительное b с округлением вверх
+func ceilDiv(a, b int) int {
+	return (a + b - 1) / b
+}
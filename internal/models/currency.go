@@ -0,0 +1,59 @@
+package models
+
+import (
+	"strings"
+
+	apperrors "github.com/Gilf4/effective-mobile-task/internal/errors"
+)
+
+// DefaultCurrency - валюта подписок, для которых она не указана
+const DefaultCurrency = "RUB"
+
+var (
+	ErrInvalidCurrency  = apperrors.Coded("invalid_currency", "currency must be an ISO 4217 code in upper case, e.g. RUB, USD, EUR")
+	ErrCurrencyRequired = apperrors.Coded("currency_required", "subscriptions are priced in different currencies: pass currency to convert them into one")
+)
+
+// isoCurrencies - действующие коды валют ISO 4217
+const isoCurrencies = `AED AFN ALL AMD AOA ARS AUD AWG AZN BAM BBD BDT BGN BHD BIF BMD BND BOB BOV BRL BSD BTN BWP
+	BYN BZD CAD CDF CHE CHF CHW CLF CLP CNY COP COU CRC CUP CVE CZK DJF DKK DOP DZD EGP ERN ETB EUR FJD FKP
+	GBP GEL GHS GIP GMD GNF GTQ GYD HKD HNL HTG HUF IDR ILS INR IQD IRR ISK JMD JOD JPY KES KGS KHR KMF KPW
+	KRW KWD KYD KZT LAK LBP LKR LRD LSL LYD MAD MDL MGA MKD MMK MNT MOP MRU MUR MVR MWK MXN MXV MYR MZN NAD
+	NGN NIO NOK NPR NZD OMR PAB PEN PGK PHP PKR PLN PYG QAR RON RSD RUB RWF SAR SBD SCR SDG SEK SGD SHP SLE
+	SOS SRD SSP STN SVC SYP SZL THB TJS TMT TND TOP TRY TTD TWD TZS UAH UGX USD USN UYI UYU UYW UZS VED VES
+	VND VUV WST XAF XCD XCG XOF XPF YER ZAR ZMW ZWG`
+
+// minorUnitExceptions - валюты, у которых число знаков минорной единицы отличается от двух
+var minorUnitExceptions = map[string]int{
+	"BIF": 0, "CLP": 0, "DJF": 0, "GNF": 0, "ISK": 0, "JPY": 0, "KMF": 0, "KRW": 0, "PYG": 0,
+	"RWF": 0, "UGX": 0, "UYI": 0, "VND": 0, "VUV": 0, "XAF": 0, "XOF": 0, "XPF": 0,
+	"BHD": 3, "IQD": 3, "JOD": 3, "KWD": 3, "LYD": 3, "OMR": 3, "TND": 3,
+	"CLF": 4, "UYW": 4,
+}
+
+// minorUnits - число знаков минорной единицы по коду валюты
+var minorUnits = func() map[string]int {
+	units := make(map[string]int)
+	for _, code := range strings.Fields(isoCurrencies) {
+		exp, ok := minorUnitExceptions[code]
+		if !ok {
+			exp = 2
+		}
+		units[code] = exp
+	}
+	return units
+}()
+
+// ValidateCurrency проверяет, что code - действующий код валюты ISO 4217
+func ValidateCurrency(code string) error {
+	if _, ok := minorUnits[code]; !ok {
+		return ErrInvalidCurrency
+	}
+	return nil
+}
+
+// MinorUnits возвращает число знаков минорной единицы валюты: цены хранятся целыми числами
+// в минорных единицах, например 1990 RUB - это 19,90 рубля, а 1990 JPY - 1990 иен
+func MinorUnits(code string) int {
+	return minorUnits[code]
+}
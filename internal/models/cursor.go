@@ -0,0 +1,39 @@
+package models
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"time"
+
+	apperrors "github.com/Gilf4/effective-mobile-task/internal/errors"
+	"github.com/google/uuid"
+)
+
+var ErrInvalidCursor = apperrors.Coded("invalid_cursor", "cursor must be a next_cursor value returned by a previous page")
+
+// ListCursor - позиция в списке подписок, упорядоченном по (created_at, id) по убыванию
+type ListCursor struct {
+	CreatedAt time.Time `json:"c"`
+	ID        uuid.UUID `json:"i"`
+}
+
+// Encode возвращает непрозрачное строковое представление курсора
+func (c ListCursor) Encode() string {
+	b, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// DecodeListCursor разбирает курсор, полученный из Encode
+func DecodeListCursor(s string) (*ListCursor, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+
+	var c ListCursor
+	if err := json.Unmarshal(b, &c); err != nil || c.ID == uuid.Nil || c.CreatedAt.IsZero() {
+		return nil, ErrInvalidCursor
+	}
+
+	return &c, nil
+}
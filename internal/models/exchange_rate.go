@@ -0,0 +1,183 @@
+package models
+
+import (
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+	"time"
+
+	apperrors "github.com/Gilf4/effective-mobile-task/internal/errors"
+)
+
+// RateScale - масштаб хранения курса: курс хранится целым числом единиц 1e-8
+const RateScale = 100_000_000
+
+// rateFractionDigits - максимальное число знаков после запятой в курсе
+const rateFractionDigits = 8
+
+// maxRateIntegerDigits ограничивает целую часть курса, чтобы масштабированный курс помещался в int64
+const maxRateIntegerDigits = 10
+
+var (
+	ErrInvalidRate          = apperrors.Coded("invalid_rate", "rate must be a positive decimal number with at most 8 fraction digits, e.g. 92.5")
+	ErrSameCurrencies       = apperrors.Coded("same_currencies", "quote_currency must differ from base_currency")
+	ErrExchangeRateNotFound = apperrors.Coded("exchange_rate_not_found", "exchange rate not found")
+	ErrExchangeRatesEmpty   = apperrors.Coded("exchange_rates_required", "at least one exchange rate is required")
+)
+
+// Rate - курс валюты, умноженный на RateScale. Хранится целым числом, чтобы пересчет сумм
+// не зависел от ошибок округления чисел с плавающей точкой
+type Rate int64
+
+// ParseRate разбирает курс в десятичной записи ("92.5", "0.0108") без знака и экспоненты
+func ParseRate(s string) (Rate, error) {
+	intPart, fracPart, _ := strings.Cut(s, ".")
+	if intPart == "" && fracPart == "" || len(intPart) > maxRateIntegerDigits || len(fracPart) > rateFractionDigits {
+		return 0, ErrInvalidRate
+	}
+	for _, c := range intPart + fracPart {
+		if c < '0' || c > '9' {
+			return 0, ErrInvalidRate
+		}
+	}
+
+	digits := intPart + fracPart + strings.Repeat("0", rateFractionDigits-len(fracPart))
+	v, err := strconv.ParseInt(digits, 10, 64)
+	if err != nil || v <= 0 {
+		return 0, ErrInvalidRate
+	}
+
+	return Rate(v), nil
+}
+
+// String возвращает курс в десятичной записи без лишних нулей
+func (r Rate) String() string {
+	s := fmt.Sprintf("%d.%08d", r/RateScale, r%RateScale)
+	return strings.TrimSuffix(strings.TrimRight(s, "0"), ".")
+}
+
+// ExchangeRate - курс валютной пары, действующий с месяца Month до следующего курса этой пары:
+// одна единица BaseCurrency стоит Rate единиц QuoteCurrency
+type ExchangeRate struct {
+	BaseCurrency  string
+	QuoteCurrency string
+	Month         time.Time
+	Rate          Rate
+	UpdatedAt     time.Time
+}
+
+// Convert пересчитывает amount в минорных единицах валюты from (базовой или котируемой валюты пары)
+// в минорные единицы другой валюты пары. Результат округляется до ближайшего целого, половина - от нуля
+func (e ExchangeRate) Convert(amount int, from string) int {
+	to := e.QuoteCurrency
+	num := new(big.Int).SetInt64(int64(e.Rate))
+	den := big.NewInt(RateScale)
+	if from == e.QuoteCurrency {
+		to = e.BaseCurrency
+		num, den = den, num
+	}
+
+	num.Mul(num, big.NewInt(int64(amount)))
+	num.Mul(num, pow10(MinorUnits(to)))
+	den.Mul(den, pow10(MinorUnits(from)))
+
+	return int(roundQuo(num, den).Int64())
+}
+
+func pow10(n int) *big.Int {
+	return new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(n)), nil)
+}
+
+// roundQuo делит num на положительный den с округлением половины от нуля
+func roundQuo(num, den *big.Int) *big.Int {
+	q, r := new(big.Int).QuoRem(num, den, new(big.Int))
+	if r.Sign() == 0 {
+		return q
+	}
+	if new(big.Int).Mul(new(big.Int).Abs(r), big.NewInt(2)).Cmp(den) >= 0 {
+		q.Add(q, big.NewInt(int64(num.Sign())))
+	}
+	return q
+}
+
+// ExchangeRateRequest - курс валютной пары на месяц (MM-YYYY). Rate передается строкой
+// в десятичной записи, чтобы не терять точность при разборе JSON
+type ExchangeRateRequest struct {
+	BaseCurrency  string `json:"base_currency" example:"USD"`
+	QuoteCurrency string `json:"quote_currency" example:"RUB"`
+	Month         string `json:"month" example:"01-2026"`
+	Rate          string `json:"rate" example:"92.5"`
+}
+
+// Validate проверяет запрос и возвращает все нарушения сразу
+func (r ExchangeRateRequest) Validate() error {
+	var v apperrors.Validation
+	r.validate(&v, "")
+	return v.Err()
+}
+
+// ValidateExchangeRates проверяет список курсов; поля нарушений содержат индекс элемента, например "[1].rate"
+func ValidateExchangeRates(reqs []ExchangeRateRequest) error {
+	var v apperrors.Validation
+	if len(reqs) == 0 {
+		v.Add("rates", ErrExchangeRatesEmpty)
+	}
+	for i, r := range reqs {
+		r.validate(&v, fmt.Sprintf("[%d].", i))
+	}
+	return v.Err()
+}
+
+func (r ExchangeRateRequest) validate(v *apperrors.Validation, prefix string) {
+	baseErr := ValidateCurrency(r.BaseCurrency)
+	if baseErr != nil {
+		v.Add(prefix+"base_currency", baseErr)
+	}
+	if err := ValidateCurrency(r.QuoteCurrency); err != nil {
+		v.Add(prefix+"quote_currency", err)
+	} else if baseErr == nil && r.QuoteCurrency == r.BaseCurrency {
+		v.Add(prefix+"quote_currency", ErrSameCurrencies)
+	}
+	if _, err := time.Parse(DateLayout, r.Month); err != nil {
+		v.Add(prefix+"month", ErrInvalidDate)
+	}
+	if _, err := ParseRate(r.Rate); err != nil {
+		v.Add(prefix+"rate", err)
+	}
+}
+
+// ExchangeRate строит курс по проверенному запросу
+func (r ExchangeRateRequest) ExchangeRate() (ExchangeRate, error) {
+	if err := r.Validate(); err != nil {
+		return ExchangeRate{}, err
+	}
+
+	month, _ := time.Parse(DateLayout, r.Month)
+	rate, _ := ParseRate(r.Rate)
+
+	return ExchangeRate{
+		BaseCurrency:  r.BaseCurrency,
+		QuoteCurrency: r.QuoteCurrency,
+		Month:         month,
+		Rate:          rate,
+	}, nil
+}
+
+type ExchangeRateResponse struct {
+	BaseCurrency  string    `json:"base_currency" example:"USD"`
+	QuoteCurrency string    `json:"quote_currency" example:"RUB"`
+	Month         string    `json:"month" example:"01-2026"`
+	Rate          string    `json:"rate" example:"92.5"`
+	UpdatedAt     time.Time `json:"updated_at"`
+}
+
+func NewExchangeRateResponse(rate *ExchangeRate) *ExchangeRateResponse {
+	return &ExchangeRateResponse{
+		BaseCurrency:  rate.BaseCurrency,
+		QuoteCurrency: rate.QuoteCurrency,
+		Month:         rate.Month.Format(DateLayout),
+		Rate:          rate.Rate.String(),
+		UpdatedAt:     rate.UpdatedAt,
+	}
+}
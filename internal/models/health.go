@@ -0,0 +1,22 @@
+package models
+
+const (
+	HealthStatusOK           = "ok"
+	HealthStatusFail         = "fail"
+	HealthStatusShuttingDown = "shutting_down"
+)
+
+type HealthResponse struct {
+	Status string `json:"status" example:"ok"`
+}
+
+type ReadinessResponse struct {
+	Status     string                     `json:"status" example:"ok"`
+	Components map[string]ComponentStatus `json:"components"`
+}
+
+// ComponentStatus - результат проверки одной зависимости приложения
+type ComponentStatus struct {
+	Status string `json:"status" example:"ok"`
+	Error  string `json:"error,omitempty"`
+}
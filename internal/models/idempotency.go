@@ -0,0 +1,27 @@
+package models
+
+import (
+	"time"
+
+	apperrors "github.com/Gilf4/effective-mobile-task/internal/errors"
+)
+
+var (
+	ErrIdempotencyKeyReused     = apperrors.Coded("idempotency_key_reused", "idempotency key was already used with a different request body")
+	ErrIdempotencyKeyInProgress = apperrors.Coded("idempotency_key_in_progress", "request with this idempotency key is being processed")
+)
+
+// IdempotencyRecord - сохраненный результат запроса с заголовком Idempotency-Key
+// StatusCode и Response пусты, пока исходный запрос еще выполняется.
+// Резерв принадлежит запросу с токеном LockToken до LockedUntil; резерв с истекшей арендой
+// (например, после падения процесса) может занять повторный запрос
+type IdempotencyRecord struct {
+	Key         string
+	RequestHash string
+	LockToken   string
+	StatusCode  *int
+	Response    []byte
+	CreatedAt   time.Time
+	LockedUntil time.Time
+	ExpiresAt   time.Time
+}
@@ -0,0 +1,15 @@
+package models
+
+type ImportLineError struct {
+	Line  int    `json:"line"`
+	Error string `json:"error"`
+	Code  string `json:"code"`
+}
+
+type ImportReport struct {
+	DryRun    bool              `json:"dry_run"`
+	TotalRows int               `json:"total_rows"`
+	ValidRows int               `json:"valid_rows"`
+	Imported  int               `json:"imported"`
+	Errors    []ImportLineError `json:"errors"`
+}
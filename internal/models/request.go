@@ -1,80 +1,300 @@
 package models
 
 import (
-	"errors"
+	"strings"
+	"time"
 
+	apperrors "github.com/Gilf4/effective-mobile-task/internal/errors"
 	"github.com/google/uuid"
 )
 
+// DateLayout - формат дат подписок в API (MM-YYYY)
+const DateLayout = "01-2006"
+
 var (
-	ErrInvalidPrice       = errors.New("price must be greater than 0")
-	ErrInvalidServiceName = errors.New("service name is required")
-	ErrInvalidUserID      = errors.New("user id is required")
-	ErrInvalidDate        = errors.New("invalid date format (expected MM-YYYY)")
+	ErrInvalidPrice       = apperrors.Coded("invalid_price", "price must be greater than 0")
+	ErrInvalidServiceName = apperrors.Coded("service_name_required", "service name is required")
+	ErrInvalidUserID      = apperrors.Coded("user_id_required", "user id is required")
+	ErrInvalidDate        = apperrors.Coded("invalid_date", "invalid date format (expected MM-YYYY)")
+	ErrInvalidPeriod      = apperrors.Coded("invalid_period", "end_date must be greater than or equal to start_date")
+	ErrInvalidTotalMode   = apperrors.Coded("invalid_mode", "mode must be one of: prorated, per_subscription")
+	ErrInvalidGroupBy     = apperrors.Coded("invalid_group_by", "group_by must be a comma-separated list of: service_name, user_id, month")
+	ErrInvalidSort        = apperrors.Coded("invalid_sort", "sort must be a comma-separated list of: service_name, price, user_id, start_date, end_date, created_at, updated_at (prefix with - for descending)")
+	ErrInvalidPriceRange  = apperrors.Coded("invalid_price_range", "price_min must be less than or equal to price_max")
+	ErrInvalidDateRange   = apperrors.Coded("invalid_date_range", "date range start must be less than or equal to its end")
+	ErrLimitTooSmall      = apperrors.Coded("invalid_limit", "limit must be greater than 0")
+	ErrLimitTooLarge      = apperrors.Coded("invalid_limit", "limit cannot exceed 100")
+	ErrNegativeOffset     = apperrors.Coded("invalid_offset", "offset cannot be negative")
+	ErrCursorWithOffset   = apperrors.Coded("cursor_conflict", "cursor cannot be combined with offset")
+	ErrCursorWithSort     = apperrors.Coded("cursor_conflict", "cursor cannot be combined with sort")
+
+	ErrSubscriptionNotFound         = apperrors.Coded("subscription_not_found", "subscription not found")
+	ErrArchivedSubscriptionNotFound = apperrors.Coded("archived_subscription_not_found", "archived subscription not found")
+	ErrSubscriptionModified         = apperrors.Coded("subscription_modified", "subscription has been modified")
+	ErrForeignUserID                = apperrors.Coded("user_id_forbidden", "user_id must match the authenticated user")
 )
 
+// TotalCostMode задает способ расчета общей стоимости подписок
+type TotalCostMode string
+
+const (
+	// TotalCostModeProrated умножает цену подписки на количество оплаченных месяцев в периоде
+	TotalCostModeProrated TotalCostMode = "prorated"
+	// TotalCostModePerSubscription учитывает каждую подписку один раз
+	TotalCostModePerSubscription TotalCostMode = "per_subscription"
+)
+
+func (m TotalCostMode) Validate() error {
+	switch m {
+	case TotalCostModeProrated, TotalCostModePerSubscription:
+		return nil
+	}
+	return ErrInvalidTotalMode
+}
+
+// CreateSubscriptionRequest.BillingPeriod по умолчанию month, Currency - RUB
+// Price задается в минорных единицах валюты Currency
 type CreateSubscriptionRequest struct {
-	ServiceName string    `json:"service_name"`
-	Price       int       `json:"price"`
-	UserID      uuid.UUID `json:"user_id"`
-	StartDate   string    `json:"start_date"`
-	EndDate     *string   `json:"end_date"`
+	ServiceName   string        `json:"service_name"`
+	Price         int           `json:"price"`
+	Currency      string        `json:"currency,omitempty" example:"RUB"`
+	BillingPeriod BillingPeriod `json:"billing_period,omitempty" enums:"month,quarter,year,week"`
+	UserID        uuid.UUID     `json:"user_id"`
+	StartDate     string        `json:"start_date"`
+	EndDate       *string       `json:"end_date"`
 }
 
+// Validate проверяет запрос и возвращает все нарушения сразу
 func (r CreateSubscriptionRequest) Validate() error {
+	var v apperrors.Validation
+
 	if r.ServiceName == "" {
-		return ErrInvalidServiceName
+		v.Add("service_name", ErrInvalidServiceName)
 	}
 	if r.Price <= 0 {
-		return ErrInvalidPrice
+		v.Add("price", ErrInvalidPrice)
 	}
 	if r.UserID == uuid.Nil {
-		return ErrInvalidUserID
+		v.Add("user_id", ErrInvalidUserID)
 	}
-	if r.StartDate == "" {
-		return ErrInvalidDate
+	if r.Currency != "" {
+		if err := ValidateCurrency(r.Currency); err != nil {
+			v.Add("currency", err)
+		}
 	}
-	return nil
+	periodErr := r.BillingPeriod.Validate()
+	if r.BillingPeriod != "" && periodErr != nil {
+		v.Add("billing_period", periodErr)
+	}
+
+	startDate, startErr := time.Parse(DateLayout, r.StartDate)
+	if startErr != nil {
+		v.Add("start_date", ErrInvalidDate)
+	}
+	if r.EndDate != nil {
+		endDate, err := time.Parse(DateLayout, *r.EndDate)
+		switch {
+		case err != nil:
+			v.Add("end_date", ErrInvalidDate)
+		case startErr == nil && endDate.Before(startDate):
+			v.Add("end_date", ErrInvalidPeriod)
+		case startErr == nil && periodErr == nil:
+			if err := r.BillingPeriod.ValidateTerm(startDate, endDate); err != nil {
+				v.Add("end_date", err)
+			}
+		}
+	}
+
+	return v.Err()
+}
+
+// CostGroupBy - поле, по которому группируется общая стоимость подписок
+type CostGroupBy string
+
+const (
+	CostGroupByServiceName CostGroupBy = "service_name"
+	CostGroupByUserID      CostGroupBy = "user_id"
+	CostGroupByMonth       CostGroupBy = "month"
+)
+
+// ParseCostGroupBy разбирает список полей группировки через запятую
+// Повторяющиеся поля игнорируются, порядок сохраняется
+func ParseCostGroupBy(s string) ([]CostGroupBy, error) {
+	var (
+		result []CostGroupBy
+		seen   = make(map[CostGroupBy]bool)
+	)
+
+	for _, part := range strings.Split(s, ",") {
+		g := CostGroupBy(strings.TrimSpace(part))
+		switch g {
+		case CostGroupByServiceName, CostGroupByUserID, CostGroupByMonth:
+		default:
+			return nil, ErrInvalidGroupBy
+		}
+		if seen[g] {
+			continue
+		}
+		seen[g] = true
+		result = append(result, g)
+	}
+
+	return result, nil
 }
 
 type UpdateSubscriptionRequest struct {
-	ServiceName *string `json:"service_name"`
-	Price       *int    `json:"price"`
-	StartDate   *string `json:"start_date"`
-	EndDate     *string `json:"end_date"`
+	ServiceName   *string        `json:"service_name"`
+	Price         *int           `json:"price"`
+	Currency      *string        `json:"currency" example:"RUB"`
+	BillingPeriod *BillingPeriod `json:"billing_period" enums:"month,quarter,year,week"`
+	StartDate     *string        `json:"start_date"`
+	EndDate       *string        `json:"end_date"`
 }
 
+// Validate проверяет заданные поля запроса и возвращает все нарушения сразу
+// Соотношение дат и периода списаний проверяется после объединения с текущей подпиской
 func (r UpdateSubscriptionRequest) Validate() error {
+	var v apperrors.Validation
+
+	if r.ServiceName != nil && *r.ServiceName == "" {
+		v.Add("service_name", ErrInvalidServiceName)
+	}
 	if r.Price != nil && *r.Price <= 0 {
-		return ErrInvalidPrice
+		v.Add("price", ErrInvalidPrice)
 	}
-	if r.ServiceName != nil && *r.ServiceName == "" {
-		return ErrInvalidServiceName
+	if r.Currency != nil {
+		if err := ValidateCurrency(*r.Currency); err != nil {
+			v.Add("currency", err)
+		}
+	}
+	if r.BillingPeriod != nil {
+		if err := r.BillingPeriod.Validate(); err != nil {
+			v.Add("billing_period", err)
+		}
 	}
-	return nil
+	if r.StartDate != nil {
+		if _, err := time.Parse(DateLayout, *r.StartDate); err != nil {
+			v.Add("start_date", ErrInvalidDate)
+		}
+	}
+	if r.EndDate != nil {
+		if _, err := time.Parse(DateLayout, *r.EndDate); err != nil {
+			v.Add("end_date", ErrInvalidDate)
+		}
+	}
+
+	return v.Err()
 }
 
 type ListSubscriptionsRequest struct {
-	UserID *uuid.UUID
-	Limit  int
-	Offset int
+	UserID         *uuid.UUID
+	Limit          int
+	Offset         int
+	IncludeDeleted bool
+
+	ServiceName       string
+	ServiceNamePrefix string
+	PriceMin          *int
+	PriceMax          *int
+	ActiveAt          *time.Time
+	StartDateFrom     *time.Time
+	StartDateTo       *time.Time
+	EndDateFrom       *time.Time
+	EndDateTo         *time.Time
+	OpenEnded         *bool
+	Sort              []SortField
+
+	// Cursor включает keyset-пагинацию: возвращаются подписки, следующие за курсором
+	Cursor *ListCursor
+	// IncludeTotal включает подсчет общего количества подписок, требующий полного сканирования
+	IncludeTotal *bool
 }
 
+// Validate проверяет параметры пагинации и фильтры и возвращает все нарушения сразу
 func (r ListSubscriptionsRequest) Validate() error {
+	var v apperrors.Validation
+
 	if r.Limit <= 0 {
-		return errors.New("limit must be greater than 0")
+		v.Add("limit", ErrLimitTooSmall)
 	}
 	if r.Limit > 100 {
-		return errors.New("limit cannot exceed 100")
+		v.Add("limit", ErrLimitTooLarge)
 	}
 	if r.Offset < 0 {
-		return errors.New("offset cannot be negative")
+		v.Add("offset", ErrNegativeOffset)
+	}
+	if r.Cursor != nil && r.Offset > 0 {
+		v.Add("cursor", ErrCursorWithOffset)
+	}
+	if r.Cursor != nil && len(r.Sort) > 0 {
+		v.Add("cursor", ErrCursorWithSort)
+	}
+	r.validateFilters(&v)
+
+	return v.Err()
+}
+
+// ValidateFilters проверяет только фильтры списка, без параметров пагинации
+func (r ListSubscriptionsRequest) ValidateFilters() error {
+	var v apperrors.Validation
+	r.validateFilters(&v)
+	return v.Err()
+}
+
+func (r ListSubscriptionsRequest) validateFilters(v *apperrors.Validation) {
+	if r.PriceMin != nil && r.PriceMax != nil && *r.PriceMin > *r.PriceMax {
+		v.Add("price_min", ErrInvalidPriceRange)
+	}
+	if r.StartDateFrom != nil && r.StartDateTo != nil && r.StartDateFrom.After(*r.StartDateTo) {
+		v.Add("start_date_from", ErrInvalidDateRange)
+	}
+	if r.EndDateFrom != nil && r.EndDateTo != nil && r.EndDateFrom.After(*r.EndDateTo) {
+		v.Add("end_date_from", ErrInvalidDateRange)
 	}
-	return nil
 }
 
+// SetDefaults заполняет незаданные параметры пагинации
+// Общее количество по умолчанию считается только в режиме offset, для обратной совместимости
 func (r *ListSubscriptionsRequest) SetDefaults() {
 	if r.Limit == 0 {
 		r.Limit = 20
 	}
+	if r.IncludeTotal == nil {
+		includeTotal := r.Cursor == nil
+		r.IncludeTotal = &includeTotal
+	}
+}
+
+// SortField - поле сортировки списка подписок
+type SortField struct {
+	Field string
+	Desc  bool
+}
+
+// sortableFields - поля, по которым разрешена сортировка списка
+var sortableFields = map[string]bool{
+	"service_name": true,
+	"price":        true,
+	"user_id":      true,
+	"start_date":   true,
+	"end_date":     true,
+	"created_at":   true,
+	"updated_at":   true,
+}
+
+// ParseSort разбирает параметр сортировки вида "price,-start_date"
+// Префикс "-" означает сортировку по убыванию
+func ParseSort(s string) ([]SortField, error) {
+	var result []SortField
+
+	for _, part := range strings.Split(s, ",") {
+		part = strings.TrimSpace(part)
+		field := SortField{Field: strings.TrimPrefix(part, "-"), Desc: strings.HasPrefix(part, "-")}
+		if !sortableFields[field.Field] {
+			return nil, ErrInvalidSort
+		}
+		result = append(result, field)
+	}
+
+	return result, nil
 }
@@ -6,40 +6,76 @@ import (
 	"github.com/google/uuid"
 )
 
+// TotalCostResponse.Currency - валюта всех сумм ответа в минорных единицах
 type TotalCostResponse struct {
-	TotalCost int `json:"total_cost"`
+	TotalCost int                 `json:"total_cost"`
+	Currency  string              `json:"currency" example:"RUB"`
+	Groups    []CostGroupResponse `json:"groups,omitempty"`
+}
+
+type CostGroupResponse struct {
+	ServiceName        *string    `json:"service_name,omitempty"`
+	UserID             *uuid.UUID `json:"user_id,omitempty"`
+	Month              *string    `json:"month,omitempty" example:"01-2026"`
+	TotalCost          int        `json:"total_cost"`
+	SubscriptionsCount int        `json:"subscriptions_count"`
 }
 
 type SubscriptionResponse struct {
-	ID          uuid.UUID  `json:"id"`
-	ServiceName string     `json:"service_name"`
-	Price       int        `json:"price"`
-	UserID      uuid.UUID  `json:"user_id"`
-	StartDate   time.Time  `json:"start_date"`
-	EndDate     *time.Time `json:"end_date,omitempty"`
-	UpdatedAt   time.Time  `json:"updated_at"`
+	ID            uuid.UUID     `json:"id"`
+	ServiceName   string        `json:"service_name"`
+	Price         int           `json:"price"`
+	Currency      string        `json:"currency" example:"RUB"`
+	BillingPeriod BillingPeriod `json:"billing_period" enums:"month,quarter,year,week"`
+	UserID        uuid.UUID     `json:"user_id"`
+	StartDate     time.Time     `json:"start_date"`
+	EndDate       *time.Time    `json:"end_date,omitempty"`
+	Version       int           `json:"version"`
+	CreatedAt     time.Time     `json:"created_at"`
+	UpdatedAt     time.Time     `json:"updated_at"`
+	DeletedAt     *time.Time    `json:"deleted_at,omitempty"`
 }
 
 func NewSubscriptionResponse(sub *Subscription) *SubscriptionResponse {
 	return &SubscriptionResponse{
-		ID:          sub.ID,
-		ServiceName: sub.ServiceName,
-		Price:       sub.Price,
-		UserID:      sub.UserID,
-		StartDate:   sub.StartDate,
-		EndDate:     sub.EndDate,
-		UpdatedAt:   sub.UpdatedAt,
+		ID:            sub.ID,
+		ServiceName:   sub.ServiceName,
+		Price:         sub.Price,
+		Currency:      sub.Currency,
+		BillingPeriod: sub.BillingPeriod,
+		UserID:        sub.UserID,
+		StartDate:     sub.StartDate,
+		EndDate:       sub.EndDate,
+		Version:       sub.Version,
+		CreatedAt:     sub.CreatedAt,
+		UpdatedAt:     sub.UpdatedAt,
+		DeletedAt:     sub.DeletedAt,
 	}
 }
 
 type PaginatedSubscriptionResponse struct {
-	Data    []SubscriptionResponse `json:"data"`
-	Total   int64                  `json:"total"`
-	Limit   int                    `json:"limit"`
-	Offset  int                    `json:"offset"`
-	HasMore bool                   `json:"has_more"`
+	Data       []SubscriptionResponse `json:"data"`
+	Total      *int64                 `json:"total,omitempty"`
+	Limit      int                    `json:"limit"`
+	Offset     int                    `json:"offset"`
+	HasMore    bool                   `json:"has_more"`
+	NextCursor string                 `json:"next_cursor,omitempty"`
+}
+
+type MonthlyCostResponse struct {
+	Month         string                 `json:"month" example:"01-2026"`
+	TotalCost     int                    `json:"total_cost"`
+	Subscriptions []SubscriptionResponse `json:"subscriptions"`
+}
+
+// CostBreakdownResponse.Currency - валюта сумм по месяцам и итога; цены подписок остаются в их валютах.
+// Пуст, если в периоде нет подписок
+type CostBreakdownResponse struct {
+	Months    []MonthlyCostResponse `json:"months"`
+	TotalCost int                   `json:"total_cost"`
+	Currency  string                `json:"currency,omitempty" example:"RUB"`
 }
 
-func (p *PaginatedSubscriptionResponse) CalculateHasMore() {
-	p.HasMore = int64(p.Offset+len(p.Data)) < p.Total
+type PurgeArchivedResponse struct {
+	Deleted int64 `json:"deleted"`
 }
@@ -7,12 +7,33 @@ import (
 )
 
 type Subscription struct {
-	ID          uuid.UUID  `json:"id" db:"id"`
-	ServiceName string     `json:"service_name" db:"service_name"`
-	Price       int        `json:"price" db:"price"`
-	UserID      uuid.UUID  `json:"user_id" db:"user_id"`
-	StartDate   time.Time  `json:"start_date" db:"start_date"`
-	EndDate     *time.Time `json:"end_date,omitempty" db:"end_date"`
-	CreatedAt   time.Time  `json:"created_at" db:"created_at"`
-	UpdatedAt   time.Time  `json:"updated_at" db:"updated_at"`
+	ID            uuid.UUID     `json:"id" db:"id"`
+	ServiceName   string        `json:"service_name" db:"service_name"`
+	Price         int           `json:"price" db:"price"`
+	BillingPeriod BillingPeriod `json:"billing_period" db:"billing_period"`
+	Currency      string        `json:"currency" db:"currency"`
+	UserID        uuid.UUID     `json:"user_id" db:"user_id"`
+	StartDate     time.Time     `json:"start_date" db:"start_date"`
+	EndDate       *time.Time    `json:"end_date,omitempty" db:"end_date"`
+	Version       int           `json:"version" db:"version"`
+	CreatedAt     time.Time     `json:"created_at" db:"created_at"`
+	UpdatedAt     time.Time     `json:"updated_at" db:"updated_at"`
+	DeletedAt     *time.Time    `json:"deleted_at,omitempty" db:"deleted_at"`
+}
+
+// MonthlySubscription - подписка, активная в конкретном календарном месяце
+type MonthlySubscription struct {
+	Month time.Time
+	Subscription
+}
+
+// CostGroup - итог по группе подписок в валюте Currency
+// Заполнены только поля, по которым выполнялась группировка
+type CostGroup struct {
+	ServiceName *string
+	UserID      *uuid.UUID
+	Month       *time.Time
+	Currency    string
+	TotalCost   int
+	Count       int
 }
@@ -0,0 +1,37 @@
+package ratelimit
+
+import (
+	"context"
+	"time"
+)
+
+// Policy - ограничение token bucket: не более Limit запросов за Period
+// Корзина вмещает Limit токенов и пополняется равномерно, полностью за Period.
+// Policy с Limit <= 0 запросы не ограничивает
+type Policy struct {
+	Limit  int
+	Period time.Duration
+}
+
+// Unlimited сообщает, что политика не ограничивает запросы
+func (p Policy) Unlimited() bool {
+	return p.Limit <= 0 || p.Period <= 0
+}
+
+// Result - решение лимитера по одному запросу
+// Reset - время до полного пополнения корзины, RetryAfter - время до появления
+// следующего токена (нулевое, если запрос разрешен)
+type Result struct {
+	Allowed    bool
+	Limit      int
+	Remaining  int
+	Reset      time.Duration
+	RetryAfter time.Duration
+}
+
+// Limiter расходует токен корзины key по политике policy
+// Реализация в памяти процесса - Memory; общее хранилище (например, Redis) подключается
+// другой реализацией интерфейса
+type Limiter interface {
+	Allow(ctx context.Context, key string, policy Policy) (Result, error)
+}
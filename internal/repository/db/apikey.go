@@ -0,0 +1,103 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	apperrors "github.com/Gilf4/effective-mobile-task/internal/errors"
+	"github.com/Gilf4/effective-mobile-task/internal/models"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+// CreateAPIKey сохраняет новый API-ключ
+func (s *SubscriptionStorage) CreateAPIKey(ctx context.Context, key *models.APIKey) error {
+	query := `
+		INSERT INTO api_keys (name, key_hash, scopes, expires_at)
+		VALUES ($1, $2, $3, $4)
+		RETURNING id, created_at
+	`
+
+	err := s.db.QueryRow(ctx, query, key.Name, key.KeyHash, key.Scopes, key.ExpiresAt).Scan(&key.ID, &key.CreatedAt)
+	if err != nil {
+		return apperrors.NewInternal(err)
+	}
+
+	return nil
+}
+
+// GetAPIKeyByHash получает API-ключ по хешу, в том числе отозванный или истекший
+func (s *SubscriptionStorage) GetAPIKeyByHash(ctx context.Context, hash string) (*models.APIKey, error) {
+	query := `SELECT ` + apiKeyColumns + ` FROM api_keys WHERE key_hash = $1`
+
+	rows, err := s.db.Query(ctx, query, hash)
+	if err != nil {
+		return nil, apperrors.NewInternal(err)
+	}
+
+	key, err := pgx.CollectExactlyOneRow(rows, scanAPIKey)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, apperrors.NewNotFound(models.ErrAPIKeyNotFound.Error(), errors.Join(models.ErrAPIKeyNotFound, err))
+		}
+		return nil, apperrors.NewInternal(err)
+	}
+
+	return &key, nil
+}
+
+// TouchAPIKey запоминает время последнего использования API-ключа
+func (s *SubscriptionStorage) TouchAPIKey(ctx context.Context, id uuid.UUID, usedAt time.Time) error {
+	if _, err := s.db.Exec(ctx, `UPDATE api_keys SET last_used_at = $2 WHERE id = $1`, id, usedAt); err != nil {
+		return apperrors.NewInternal(err)
+	}
+	return nil
+}
+
+// RevokeAPIKey отзывает действующий API-ключ
+func (s *SubscriptionStorage) RevokeAPIKey(ctx context.Context, id uuid.UUID) error {
+	cmdTag, err := s.db.Exec(ctx, `UPDATE api_keys SET revoked_at = NOW() WHERE id = $1 AND revoked_at IS NULL`, id)
+	if err != nil {
+		return apperrors.NewInternal(err)
+	}
+
+	if cmdTag.RowsAffected() == 0 {
+		return apperrors.NewNotFound(models.ErrAPIKeyNotFound.Error(), models.ErrAPIKeyNotFound)
+	}
+
+	return nil
+}
+
+// ListAPIKeys возвращает все API-ключи в порядке выпуска
+func (s *SubscriptionStorage) ListAPIKeys(ctx context.Context) ([]models.APIKey, error) {
+	rows, err := s.db.Query(ctx, `SELECT `+apiKeyColumns+` FROM api_keys ORDER BY created_at, id`)
+	if err != nil {
+		return nil, apperrors.NewInternal(err)
+	}
+
+	keys, err := pgx.CollectRows(rows, scanAPIKey)
+	if err != nil {
+		return nil, apperrors.NewInternal(err)
+	}
+
+	return keys, nil
+}
+
+// apiKeyColumns - колонки, которые читает scanAPIKey
+const apiKeyColumns = `id, name, key_hash, scopes, expires_at, revoked_at, last_used_at, created_at`
+
+func scanAPIKey(row pgx.CollectableRow) (models.APIKey, error) {
+	var key models.APIKey
+	err := row.Scan(
+		&key.ID,
+		&key.Name,
+		&key.KeyHash,
+		&key.Scopes,
+		&key.ExpiresAt,
+		&key.RevokedAt,
+		&key.LastUsedAt,
+		&key.CreatedAt,
+	)
+	return key, err
+}
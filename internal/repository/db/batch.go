@@ -0,0 +1,94 @@
+package db
+
+import (
+	"context"
+
+	apperrors "github.com/Gilf4/effective-mobile-task/internal/errors"
+	"github.com/Gilf4/effective-mobile-task/internal/models"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+// CreateBatch создает подписки в одной транзакции
+// nil-элементы subs пропускаются. Возвращает ошибки по каждому элементу (nil при успехе).
+// В режиме atomic при первой ошибке транзакция откатывается и ничего не сохраняется;
+// в режиме partial каждая запись выполняется в отдельной точке сохранения
+func (s *SubscriptionStorage) CreateBatch(ctx context.Context, subs []*models.Subscription, atomic bool) ([]error, error) {
+	return s.runBatch(ctx, len(subs), atomic, func(tx pgx.Tx, i int) (bool, error) {
+		if subs[i] == nil {
+			return false, nil
+		}
+		return true, insertSubscription(ctx, tx, subs[i])
+	})
+}
+
+// ArchiveBatch архивирует подписки по списку ID в одной транзакции
+// Семантика режимов atomic и partial такая же, как у CreateBatch
+func (s *SubscriptionStorage) ArchiveBatch(ctx context.Context, ids []uuid.UUID, atomic bool) ([]error, error) {
+	query := `
+		UPDATE subscriptions
+		SET deleted_at = NOW(), version = version + 1, updated_at = NOW()
+		WHERE id = $1 AND deleted_at IS NULL
+	`
+
+	return s.runBatch(ctx, len(ids), atomic, func(tx pgx.Tx, i int) (bool, error) {
+		cmdTag, err := tx.Exec(ctx, query, ids[i])
+		if err != nil {
+			return true, apperrors.NewInternal(err)
+		}
+		if cmdTag.RowsAffected() == 0 {
+			return true, apperrors.NewNotFound(models.ErrSubscriptionNotFound.Error(), models.ErrSubscriptionNotFound)
+		}
+		return true, nil
+	})
+}
+
+// runBatch выполняет apply для каждого элемента в одной транзакции
+// apply возвращает false, если элемент пропущен
+func (s *SubscriptionStorage) runBatch(ctx context.Context, n int, atomic bool, apply func(tx pgx.Tx, i int) (bool, error)) ([]error, error) {
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return nil, apperrors.NewInternal(err)
+	}
+	defer tx.Rollback(ctx)
+
+	itemErrs := make([]error, n)
+
+	for i := range n {
+		if atomic {
+			if _, err := apply(tx, i); err != nil {
+				itemErrs[i] = err
+				return itemErrs, nil
+			}
+			continue
+		}
+
+		sp, err := tx.Begin(ctx)
+		if err != nil {
+			return nil, apperrors.NewInternal(err)
+		}
+
+		applied, err := apply(sp, i)
+		if err != nil {
+			itemErrs[i] = err
+			if rbErr := sp.Rollback(ctx); rbErr != nil {
+				return nil, apperrors.NewInternal(rbErr)
+			}
+			continue
+		}
+		if !applied {
+			sp.Rollback(ctx)
+			continue
+		}
+
+		if err := sp.Commit(ctx); err != nil {
+			return nil, apperrors.NewInternal(err)
+		}
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, apperrors.NewInternal(err)
+	}
+
+	return itemErrs, nil
+}
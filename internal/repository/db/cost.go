@@ -0,0 +1,221 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	apperrors "github.com/Gilf4/effective-mobile-task/internal/errors"
+	"github.com/Gilf4/effective-mobile-task/internal/models"
+	"github.com/google/uuid"
+)
+
+// effectiveEndDateExpr - дата окончания действия подписки с учетом архивации:
+// архивная подписка считается действующей по месяц архивации включительно
+const effectiveEndDateExpr = `LEAST(end_date, deleted_at::date)`
+
+// activeInPeriodCond отбирает подписки, пересекающиеся с периодом $2..$1
+// Подписка, архивированная до начала действия, не учитывается
+const activeInPeriodCond = `start_date <= $1
+		  AND (` + effectiveEndDateExpr + ` IS NULL OR ` + effectiveEndDateExpr + ` >= $2)
+		  AND (deleted_at IS NULL OR deleted_at::date >= start_date)`
+
+// monthlyActiveJoin разворачивает период $2..$1 в календарные месяцы m.month
+// и соединяет каждый месяц с подписками s, активными в нём
+const monthlyActiveJoin = `generate_series(date_trunc('month', $2::timestamp), $1::timestamp, interval '1 month') AS m(month)
+		JOIN subscriptions s
+		  ON date_trunc('month', start_date) <= m.month
+		 AND (` + effectiveEndDateExpr + ` IS NULL OR ` + effectiveEndDateExpr + ` >= m.month)
+		 AND (deleted_at IS NULL OR deleted_at::date >= start_date)`
+
+// periodChargesExpr считает списания подписки внутри периода $2..$1 (начало $2, последний месяц $1).
+// Бессрочная подписка считается активной до конца периода, архивная - до конца месяца архивации
+var periodChargesExpr = chargesExpr(
+	`GREATEST(start_date, $2::date)`,
+	`(date_trunc('month', LEAST(COALESCE(`+effectiveEndDateExpr+`, $1::date), $1::date)::timestamp) + interval '1 month - 1 day')::date`,
+)
+
+// monthChargesExpr считает списания подписки в месяце m.month из monthlyActiveJoin
+var monthChargesExpr = chargesExpr(
+	`GREATEST(start_date, m.month::date)`,
+	`(m.month + interval '1 month - 1 day')::date`,
+)
+
+// chargesExpr считает списания подписки с датами между firstDayExpr и lastDayExpr включительно
+// Списания приходятся на start_date и далее через каждый период billing_period. Подписки начинаются
+// с первого числа месяца, поэтому помесячные периоды считаются по номерам месяцев, недельные - по дням.
+// firstDayExpr не должен быть раньше start_date
+func chargesExpr(firstDayExpr, lastDayExpr string) string {
+	months := `(CASE billing_period WHEN 'quarter' THEN 3 WHEN 'year' THEN 12 ELSE 1 END)`
+	start := monthIndex("start_date")
+
+	return fmt.Sprintf(`(CASE billing_period
+		WHEN 'week' THEN (%[2]s - start_date) / 7 - (%[1]s - start_date + 6) / 7
+		ELSE (%[4]s - %[6]s) / %[5]s - (%[3]s - %[6]s + %[5]s - 1) / %[5]s
+	END + 1)`, firstDayExpr, lastDayExpr, monthIndex(firstDayExpr), monthIndex(lastDayExpr), months, start)
+}
+
+// monthIndex возвращает номер месяца даты от начала летоисчисления
+func monthIndex(dateExpr string) string {
+	return fmt.Sprintf(`(EXTRACT(YEAR FROM %[1]s)::int * 12 + EXTRACT(MONTH FROM %[1]s)::int)`, dateExpr)
+}
+
+// GetMonthlySubscriptions возвращает подписки, активные в каждом месяце периода
+// Подписка попадает в результат один раз для каждого месяца, в котором она действует
+// Параметры userID и serviceName опциональные
+func (r *SubscriptionStorage) GetMonthlySubscriptions(ctx context.Context, userID *uuid.UUID, serviceName string, startPeriod, endPeriod time.Time) ([]models.MonthlySubscription, error) {
+	query := `
+		SELECT m.month::date, s.id, s.service_name, s.price, s.currency, s.billing_period, s.user_id, s.start_date, s.end_date, s.version, s.created_at, s.updated_at, s.deleted_at
+		FROM ` + monthlyActiveJoin + `
+		WHERE TRUE
+	`
+
+	args := []any{endPeriod, startPeriod}
+	query, args = appendCostFilters(query, args, userID, serviceName)
+	query += " ORDER BY m.month, s.created_at"
+
+	rows, err := r.db.Query(ctx, query, args...)
+	if err != nil {
+		return nil, apperrors.NewInternal(err)
+	}
+	defer rows.Close()
+
+	var result []models.MonthlySubscription
+
+	for rows.Next() {
+		var item models.MonthlySubscription
+		err := rows.Scan(
+			&item.Month,
+			&item.ID,
+			&item.ServiceName,
+			&item.Price,
+			&item.Currency,
+			&item.BillingPeriod,
+			&item.UserID,
+			&item.StartDate,
+			&item.EndDate,
+			&item.Version,
+			&item.CreatedAt,
+			&item.UpdatedAt,
+			&item.DeletedAt,
+		)
+		if err != nil {
+			return nil, apperrors.NewInternal(err)
+		}
+		result = append(result, item)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, apperrors.NewInternal(err)
+	}
+
+	return result, nil
+}
+
+// costGroupColumns сопоставляет поля группировки с выражениями SQL
+var costGroupColumns = map[models.CostGroupBy]string{
+	models.CostGroupByServiceName: "service_name",
+	models.CostGroupByUserID:      "user_id",
+	models.CostGroupByMonth:       "m.month::date",
+}
+
+// GetGroupedTotalCost считает стоимость и количество подписок за период одним запросом
+// с группировкой по переданным полям и валюте: суммы в разных валютах не складываются.
+// При группировке по месяцу каждая подписка учитывается в каждом месяце, в котором она активна,
+// со списаниями этого месяца; иначе сумма считается в соответствии с mode
+// Параметры userID и serviceName опциональные, groupBy может быть пустым
+func (r *SubscriptionStorage) GetGroupedTotalCost(ctx context.Context, userID *uuid.UUID, serviceName string, startPeriod, endPeriod time.Time, mode models.TotalCostMode, groupBy []models.CostGroupBy) ([]models.CostGroup, error) {
+	columns := make([]string, 0, len(groupBy)+1)
+	byMonth := false
+	for _, g := range groupBy {
+		column, ok := costGroupColumns[g]
+		if !ok {
+			return nil, apperrors.NewBadRequest(models.ErrInvalidGroupBy.Error(), models.ErrInvalidGroupBy)
+		}
+		if g == models.CostGroupByMonth {
+			byMonth = true
+		}
+		columns = append(columns, column)
+	}
+	columns = append(columns, "currency")
+	groupList := strings.Join(columns, ", ")
+
+	var query string
+	switch {
+	case byMonth:
+		query = fmt.Sprintf(`
+			SELECT %s, SUM(s.price::bigint * %s)::bigint, COUNT(DISTINCT s.id)
+			FROM %s
+			WHERE TRUE
+		`, groupList, monthChargesExpr, monthlyActiveJoin)
+	default:
+		amountExpr := "price::bigint * " + periodChargesExpr
+		if mode == models.TotalCostModePerSubscription {
+			amountExpr = "price"
+		}
+		query = fmt.Sprintf(`
+			SELECT %s, SUM(%s)::bigint, COUNT(*)
+			FROM subscriptions
+			WHERE %s
+		`, groupList, amountExpr, activeInPeriodCond)
+	}
+
+	args := []any{endPeriod, startPeriod}
+	query, args = appendCostFilters(query, args, userID, serviceName)
+	query += fmt.Sprintf(" GROUP BY %s ORDER BY %s", groupList, groupList)
+
+	rows, err := r.db.Query(ctx, query, args...)
+	if err != nil {
+		return nil, apperrors.NewInternal(err)
+	}
+	defer rows.Close()
+
+	var groups []models.CostGroup
+
+	for rows.Next() {
+		var group models.CostGroup
+		dest := make([]any, 0, len(groupBy)+3)
+		for _, g := range groupBy {
+			switch g {
+			case models.CostGroupByServiceName:
+				dest = append(dest, &group.ServiceName)
+			case models.CostGroupByUserID:
+				dest = append(dest, &group.UserID)
+			case models.CostGroupByMonth:
+				dest = append(dest, &group.Month)
+			}
+		}
+		dest = append(dest, &group.Currency, &group.TotalCost, &group.Count)
+
+		if err := rows.Scan(dest...); err != nil {
+			return nil, apperrors.NewInternal(err)
+		}
+		groups = append(groups, group)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, apperrors.NewInternal(err)
+	}
+
+	return groups, nil
+}
+
+// appendCostFilters добавляет к запросу опциональные фильтры по user_id и service_name
+// Ожидается, что запрос уже содержит WHERE и использует параметры $1 и $2
+func appendCostFilters(query string, args []any, userID *uuid.UUID, serviceName string) (string, []any) {
+	argIdx := len(args) + 1
+
+	if userID != nil {
+		query += fmt.Sprintf(" AND user_id = $%d", argIdx)
+		args = append(args, *userID)
+		argIdx++
+	}
+
+	if serviceName != "" {
+		query += fmt.Sprintf(" AND service_name = $%d", argIdx)
+		args = append(args, serviceName)
+	}
+
+	return query, args
+}
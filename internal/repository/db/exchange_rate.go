@@ -0,0 +1,122 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	apperrors "github.com/Gilf4/effective-mobile-task/internal/errors"
+	"github.com/Gilf4/effective-mobile-task/internal/models"
+	"github.com/jackc/pgx/v5"
+)
+
+// UpsertExchangeRates сохраняет курсы в одной транзакции, заменяя курсы тех же пар на те же месяцы
+func (s *SubscriptionStorage) UpsertExchangeRates(ctx context.Context, rates []models.ExchangeRate) error {
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return apperrors.NewInternal(err)
+	}
+	defer tx.Rollback(ctx)
+
+	query := `
+		INSERT INTO exchange_rates (base_currency, quote_currency, month, rate_e8)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (base_currency, quote_currency, month) DO UPDATE
+		SET rate_e8 = EXCLUDED.rate_e8, updated_at = NOW()
+		RETURNING updated_at
+	`
+
+	for i := range rates {
+		rate := &rates[i]
+		err := tx.QueryRow(ctx, query, rate.BaseCurrency, rate.QuoteCurrency, rate.Month, int64(rate.Rate)).Scan(&rate.UpdatedAt)
+		if err != nil {
+			return apperrors.NewInternal(err)
+		}
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return apperrors.NewInternal(err)
+	}
+
+	return nil
+}
+
+// ListExchangeRates возвращает курсы, упорядоченные по паре и месяцу
+// Пустые base и quote не ограничивают выборку
+func (s *SubscriptionStorage) ListExchangeRates(ctx context.Context, base, quote string) ([]models.ExchangeRate, error) {
+	query := `
+		SELECT ` + exchangeRateColumns + `
+		FROM exchange_rates
+		WHERE ($1 = '' OR base_currency = $1) AND ($2 = '' OR quote_currency = $2)
+		ORDER BY base_currency, quote_currency, month
+	`
+
+	rows, err := s.db.Query(ctx, query, base, quote)
+	if err != nil {
+		return nil, apperrors.NewInternal(err)
+	}
+
+	rates, err := pgx.CollectRows(rows, scanExchangeRate)
+	if err != nil {
+		return nil, apperrors.NewInternal(err)
+	}
+
+	return rates, nil
+}
+
+// DeleteExchangeRate удаляет курс пары на месяц
+func (s *SubscriptionStorage) DeleteExchangeRate(ctx context.Context, base, quote string, month time.Time) error {
+	query := `DELETE FROM exchange_rates WHERE base_currency = $1 AND quote_currency = $2 AND month = $3`
+
+	cmdTag, err := s.db.Exec(ctx, query, base, quote, month)
+	if err != nil {
+		return apperrors.NewInternal(err)
+	}
+
+	if cmdTag.RowsAffected() == 0 {
+		return apperrors.NewNotFound(models.ErrExchangeRateNotFound.Error(), models.ErrExchangeRateNotFound)
+	}
+
+	return nil
+}
+
+// GetExchangeRate возвращает курс пары, действующий в месяце month: последний курс на месяц не позже month
+func (s *SubscriptionStorage) GetExchangeRate(ctx context.Context, base, quote string, month time.Time) (*models.ExchangeRate, error) {
+	query := `
+		SELECT ` + exchangeRateColumns + `
+		FROM exchange_rates
+		WHERE base_currency = $1 AND quote_currency = $2 AND month <= $3
+		ORDER BY month DESC
+		LIMIT 1
+	`
+
+	rows, err := s.db.Query(ctx, query, base, quote, month)
+	if err != nil {
+		return nil, apperrors.NewInternal(err)
+	}
+
+	rate, err := pgx.CollectExactlyOneRow(rows, scanExchangeRate)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, apperrors.NewNotFound(models.ErrExchangeRateNotFound.Error(), errors.Join(models.ErrExchangeRateNotFound, err))
+		}
+		return nil, apperrors.NewInternal(err)
+	}
+
+	return &rate, nil
+}
+
+// exchangeRateColumns - колонки, которые читает scanExchangeRate
+const exchangeRateColumns = `base_currency, quote_currency, month, rate_e8, updated_at`
+
+func scanExchangeRate(row pgx.CollectableRow) (models.ExchangeRate, error) {
+	var rate models.ExchangeRate
+	err := row.Scan(
+		&rate.BaseCurrency,
+		&rate.QuoteCurrency,
+		&rate.Month,
+		(*int64)(&rate.Rate),
+		&rate.UpdatedAt,
+	)
+	return rate, err
+}
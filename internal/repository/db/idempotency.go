@@ -0,0 +1,136 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	apperrors "github.com/Gilf4/effective-mobile-task/internal/errors"
+	"github.com/Gilf4/effective-mobile-task/internal/models"
+	"github.com/jackc/pgx/v5"
+)
+
+// ReserveIdempotencyKey резервирует ключ идемпотентности за текущим запросом
+// Ключ занимается заново, если он истек или его незавершенный резерв пережил аренду.
+// Если ключ уже занят, возвращается существующая запись и false
+func (s *SubscriptionStorage) ReserveIdempotencyKey(ctx context.Context, rec *models.IdempotencyRecord) (*models.IdempotencyRecord, bool, error) {
+	query := `
+		INSERT INTO idempotency_keys (key, request_hash, lock_token, locked_until, expires_at)
+		VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (key) DO UPDATE
+		SET request_hash = EXCLUDED.request_hash,
+		    lock_token = EXCLUDED.lock_token,
+		    locked_until = EXCLUDED.locked_until,
+		    status_code = NULL,
+		    response_body = NULL,
+		    created_at = NOW(),
+		    expires_at = EXCLUDED.expires_at
+		WHERE idempotency_keys.expires_at <= NOW()
+		   OR (idempotency_keys.status_code IS NULL
+		       AND (idempotency_keys.locked_until IS NULL OR idempotency_keys.locked_until <= NOW()))
+		RETURNING created_at
+	`
+
+	err := s.db.QueryRow(ctx, query, rec.Key, rec.RequestHash, rec.LockToken, rec.LockedUntil, rec.ExpiresAt).Scan(&rec.CreatedAt)
+	if err == nil {
+		return rec, true, nil
+	}
+	if !errors.Is(err, pgx.ErrNoRows) {
+		return nil, false, apperrors.NewInternal(err)
+	}
+
+	existing, err := s.getIdempotencyKey(ctx, rec.Key)
+	if err != nil {
+		return nil, false, err
+	}
+
+	return existing, false, nil
+}
+
+// CreateIdempotent в одной транзакции создает подписку и сохраняет ответ на запрос,
+// зарезервировавший ключ. Если резерв за это время занял другой запрос, подписка не создается
+func (s *SubscriptionStorage) CreateIdempotent(ctx context.Context, sub *models.Subscription, rec *models.IdempotencyRecord, statusCode int, response func(*models.Subscription) ([]byte, error)) error {
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return apperrors.NewInternal(err)
+	}
+	defer tx.Rollback(ctx)
+
+	if err := insertSubscription(ctx, tx, sub); err != nil {
+		return err
+	}
+
+	body, err := response(sub)
+	if err != nil {
+		return apperrors.NewInternal(err)
+	}
+
+	query := `
+		UPDATE idempotency_keys
+		SET status_code = $3, response_body = $4, lock_token = NULL, locked_until = NULL
+		WHERE key = $1 AND lock_token = $2 AND status_code IS NULL
+	`
+
+	cmdTag, err := tx.Exec(ctx, query, rec.Key, rec.LockToken, statusCode, body)
+	if err != nil {
+		return apperrors.NewInternal(err)
+	}
+	if cmdTag.RowsAffected() == 0 {
+		return apperrors.NewConflict(models.ErrIdempotencyKeyInProgress.Error(), models.ErrIdempotencyKeyInProgress)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return apperrors.NewInternal(err)
+	}
+
+	return nil
+}
+
+// ReleaseIdempotencyKey удаляет резерв ключа с токеном lockToken, если запрос завершился ошибкой
+func (s *SubscriptionStorage) ReleaseIdempotencyKey(ctx context.Context, key, lockToken string) error {
+	query := `DELETE FROM idempotency_keys WHERE key = $1 AND lock_token = $2 AND status_code IS NULL`
+
+	if _, err := s.db.Exec(ctx, query, key, lockToken); err != nil {
+		return apperrors.NewInternal(err)
+	}
+
+	return nil
+}
+
+// DeleteExpiredIdempotencyKeys удаляет ключи идемпотентности, срок хранения которых истек
+func (s *SubscriptionStorage) DeleteExpiredIdempotencyKeys(ctx context.Context, now time.Time) (int64, error) {
+	cmdTag, err := s.db.Exec(ctx, `DELETE FROM idempotency_keys WHERE expires_at <= $1`, now)
+	if err != nil {
+		return 0, apperrors.NewInternal(err)
+	}
+
+	return cmdTag.RowsAffected(), nil
+}
+
+func (s *SubscriptionStorage) getIdempotencyKey(ctx context.Context, key string) (*models.IdempotencyRecord, error) {
+	query := `
+		SELECT key, request_hash, status_code, response_body, created_at, expires_at
+		FROM idempotency_keys
+		WHERE key = $1
+	`
+
+	var rec models.IdempotencyRecord
+	err := s.db.QueryRow(ctx, query, key).Scan(
+		&rec.Key,
+		&rec.RequestHash,
+		&rec.StatusCode,
+		&rec.Response,
+		&rec.CreatedAt,
+		&rec.ExpiresAt,
+	)
+
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			// ключ освобожден между попыткой резерва и чтением
+			return nil, apperrors.NewConflict(models.ErrIdempotencyKeyInProgress.Error(), errors.Join(models.ErrIdempotencyKeyInProgress, err))
+		}
+		return nil, apperrors.NewInternal(err)
+	}
+
+	return &rec, nil
+}
@@ -0,0 +1,36 @@
+package db
+
+import (
+	"context"
+
+	apperrors "github.com/Gilf4/effective-mobile-task/internal/errors"
+	"github.com/Gilf4/effective-mobile-task/internal/models"
+	"github.com/jackc/pgx/v5"
+)
+
+// Import загружает подписки через COPY в одной транзакции
+func (s *SubscriptionStorage) Import(ctx context.Context, subs []*models.Subscription) (int64, error) {
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return 0, apperrors.NewInternal(err)
+	}
+	defer tx.Rollback(ctx)
+
+	copied, err := tx.CopyFrom(ctx,
+		pgx.Identifier{"subscriptions"},
+		[]string{"service_name", "price", "currency", "billing_period", "user_id", "start_date", "end_date"},
+		pgx.CopyFromSlice(len(subs), func(i int) ([]any, error) {
+			sub := subs[i]
+			return []any{sub.ServiceName, sub.Price, sub.Currency, sub.BillingPeriod, sub.UserID, sub.StartDate, sub.EndDate}, nil
+		}),
+	)
+	if err != nil {
+		return 0, apperrors.NewInternal(err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return 0, apperrors.NewInternal(err)
+	}
+
+	return copied, nil
+}
@@ -0,0 +1,103 @@
+package db
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/Gilf4/effective-mobile-task/internal/migrate"
+	"github.com/Gilf4/effective-mobile-task/internal/models"
+	"github.com/Gilf4/effective-mobile-task/migrations"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// testPool подключается к Postgres из TEST_DATABASE_URL и применяет миграции.
+// Без переменной тест пропускается: база для тестов нужна отдельная, таблицы очищаются
+func testPool(t *testing.T) *pgxpool.Pool {
+	t.Helper()
+
+	dsn := os.Getenv("TEST_DATABASE_URL")
+	if dsn == "" {
+		t.Skip("TEST_DATABASE_URL is not set")
+	}
+
+	ctx := context.Background()
+	pool, err := pgxpool.New(ctx, dsn)
+	if err != nil {
+		t.Fatalf("connect: %v", err)
+	}
+	t.Cleanup(pool.Close)
+
+	list, err := migrate.Load(migrations.FS)
+	if err != nil {
+		t.Fatalf("load migrations: %v", err)
+	}
+	if _, err := migrate.New(pool, list).Up(ctx); err != nil {
+		t.Fatalf("migrate up: %v", err)
+	}
+	if _, err := pool.Exec(ctx, "TRUNCATE subscriptions"); err != nil {
+		t.Fatalf("truncate: %v", err)
+	}
+
+	return pool
+}
+
+func TestImportRoundTrip(t *testing.T) {
+	repo := NewSubscriptionRepository(testPool(t))
+	ctx := context.Background()
+
+	userID := uuid.New()
+	end := time.Date(2026, 12, 1, 0, 0, 0, 0, time.UTC)
+	subs := []*models.Subscription{
+		{
+			ServiceName:   "Netflix",
+			Price:         1299,
+			Currency:      "USD",
+			BillingPeriod: models.BillingPeriodMonth,
+			UserID:        userID,
+			StartDate:     time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			ServiceName:   "Yandex Plus",
+			Price:         39900,
+			Currency:      "RUB",
+			BillingPeriod: models.BillingPeriodYear,
+			UserID:        userID,
+			StartDate:     time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC),
+			EndDate:       &end,
+		},
+	}
+
+	n, err := repo.Import(ctx, subs)
+	if err != nil {
+		t.Fatalf("Import: %v", err)
+	}
+	if n != int64(len(subs)) {
+		t.Fatalf("Import copied %d rows, want %d", n, len(subs))
+	}
+
+	got, err := repo.List(ctx, models.ListSubscriptionsRequest{
+		UserID: &userID,
+		Limit:  10,
+		Sort:   []models.SortField{{Field: "service_name"}},
+	})
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+	if len(got) != len(subs) {
+		t.Fatalf("List returned %d rows, want %d", len(got), len(subs))
+	}
+
+	for i, want := range subs {
+		g := got[i]
+		if g.ServiceName != want.ServiceName || g.Price != want.Price || g.Currency != want.Currency ||
+			g.BillingPeriod != want.BillingPeriod || g.UserID != want.UserID || !g.StartDate.Equal(want.StartDate) {
+			t.Errorf("row %d = %+v, want %+v", i, g, *want)
+		}
+		if (g.EndDate == nil) != (want.EndDate == nil) || g.EndDate != nil && !g.EndDate.Equal(*want.EndDate) {
+			t.Errorf("row %d end_date = %v, want %v", i, g.EndDate, want.EndDate)
+		}
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"strings"
 	"time"
 
 	"github.com/Gilf4/effective-mobile-task/internal/config"
@@ -11,6 +12,7 @@ import (
 	"github.com/Gilf4/effective-mobile-task/internal/models"
 	"github.com/google/uuid"
 	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/jackc/pgx/v5/pgxpool"
 )
 
@@ -18,7 +20,8 @@ type SubscriptionStorage struct {
 	db *pgxpool.Pool
 }
 
-func NewSubscriptionRepository(ctx context.Context, dbCfg *config.DBConfig) (*SubscriptionStorage, error) {
+// NewPool открывает пул соединений с Postgres и проверяет доступность базы
+func NewPool(ctx context.Context, dbCfg *config.DBConfig) (*pgxpool.Pool, error) {
 	dsn := fmt.Sprintf("user=%s password=%s host=%s port=%d dbname=%s sslmode=disable",
 		dbCfg.User, dbCfg.Password, dbCfg.Host, dbCfg.Port, dbCfg.DBName)
 
@@ -32,24 +35,40 @@ func NewSubscriptionRepository(ctx context.Context, dbCfg *config.DBConfig) (*Su
 		return nil, err
 	}
 
-	return &SubscriptionStorage{db: pool}, nil
+	return pool, nil
+}
+
+func NewSubscriptionRepository(pool *pgxpool.Pool) *SubscriptionStorage {
+	return &SubscriptionStorage{db: pool}
+}
+
+// querier - общий интерфейс пула соединений и транзакции pgx
+type querier interface {
+	Exec(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error)
+	QueryRow(ctx context.Context, sql string, args ...any) pgx.Row
 }
 
 // Create создает новую запись о подписке
 func (s *SubscriptionStorage) Create(ctx context.Context, sub *models.Subscription) error {
+	return insertSubscription(ctx, s.db, sub)
+}
+
+func insertSubscription(ctx context.Context, q querier, sub *models.Subscription) error {
 	query := `
-		INSERT INTO subscriptions (service_name, price, user_id, start_date, end_date)
-		VALUES ($1, $2, $3, $4, $5)
-		RETURNING id, created_at, updated_at
+		INSERT INTO subscriptions (service_name, price, currency, billing_period, user_id, start_date, end_date)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		RETURNING id, version, created_at, updated_at
 	`
 
-	err := s.db.QueryRow(ctx, query,
+	err := q.QueryRow(ctx, query,
 		sub.ServiceName,
 		sub.Price,
+		sub.Currency,
+		sub.BillingPeriod,
 		sub.UserID,
 		sub.StartDate,
 		sub.EndDate,
-	).Scan(&sub.ID, &sub.CreatedAt, &sub.UpdatedAt)
+	).Scan(&sub.ID, &sub.Version, &sub.CreatedAt, &sub.UpdatedAt)
 
 	if err != nil {
 		return apperrors.NewInternal(err)
@@ -58,12 +77,12 @@ func (s *SubscriptionStorage) Create(ctx context.Context, sub *models.Subscripti
 	return nil
 }
 
-// GetByID получает подписку по ID
+// GetByID получает неархивную подписку по ID
 func (s *SubscriptionStorage) GetByID(ctx context.Context, id uuid.UUID) (*models.Subscription, error) {
 	query := `
-		SELECT id, service_name, price, user_id, start_date, end_date, created_at, updated_at
+		SELECT id, service_name, price, currency, billing_period, user_id, start_date, end_date, version, created_at, updated_at
 		FROM subscriptions
-		WHERE id = $1
+		WHERE id = $1 AND deleted_at IS NULL
 	`
 
 	var sub models.Subscription
@@ -71,16 +90,19 @@ func (s *SubscriptionStorage) GetByID(ctx context.Context, id uuid.UUID) (*model
 		&sub.ID,
 		&sub.ServiceName,
 		&sub.Price,
+		&sub.Currency,
+		&sub.BillingPeriod,
 		&sub.UserID,
 		&sub.StartDate,
 		&sub.EndDate,
+		&sub.Version,
 		&sub.CreatedAt,
 		&sub.UpdatedAt,
 	)
 
 	if err != nil {
 		if errors.Is(err, pgx.ErrNoRows) {
-			return nil, apperrors.NewNotFound("subscription not found", err)
+			return nil, apperrors.NewNotFound(models.ErrSubscriptionNotFound.Error(), errors.Join(models.ErrSubscriptionNotFound, err))
 		}
 		return nil, apperrors.NewInternal(err)
 	}
@@ -88,145 +110,377 @@ func (s *SubscriptionStorage) GetByID(ctx context.Context, id uuid.UUID) (*model
 	return &sub, nil
 }
 
-// Update обновляет данные подписки
+// GetOwnerID возвращает user_id подписки, в том числе архивной
+func (s *SubscriptionStorage) GetOwnerID(ctx context.Context, id uuid.UUID) (uuid.UUID, error) {
+	var userID uuid.UUID
+	err := s.db.QueryRow(ctx, `SELECT user_id FROM subscriptions WHERE id = $1`, id).Scan(&userID)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return uuid.Nil, apperrors.NewNotFound(models.ErrSubscriptionNotFound.Error(), errors.Join(models.ErrSubscriptionNotFound, err))
+		}
+		return uuid.Nil, apperrors.NewInternal(err)
+	}
+
+	return userID, nil
+}
+
+// Update обновляет данные подписки, если её версия не изменилась с момента чтения
+// При успешном обновлении версия подписки увеличивается
 func (s *SubscriptionStorage) Update(ctx context.Context, sub *models.Subscription) error {
 	query := `
 		UPDATE subscriptions
-		SET service_name = $1, price = $2, start_date = $3, end_date = $4, updated_at = NOW()
-		WHERE id = $5
+		SET service_name = $1, price = $2, currency = $3, billing_period = $4, start_date = $5, end_date = $6,
+		    version = version + 1, updated_at = NOW()
+		WHERE id = $7 AND version = $8 AND deleted_at IS NULL
+		RETURNING version, updated_at
 	`
 
-	cmdTag, err := s.db.Exec(ctx, query,
+	err := s.db.QueryRow(ctx, query,
 		sub.ServiceName,
 		sub.Price,
+		sub.Currency,
+		sub.BillingPeriod,
 		sub.StartDate,
 		sub.EndDate,
 		sub.ID,
-	)
+		sub.Version,
+	).Scan(&sub.Version, &sub.UpdatedAt)
 
 	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return s.versionMismatchError(ctx, sub.ID, false)
+		}
 		return apperrors.NewInternal(err)
 	}
 
-	if cmdTag.RowsAffected() == 0 {
-		return apperrors.NewNotFound("subscription not found", nil)
+	return nil
+}
+
+// Archive помечает подписку как архивную вместо удаления, чтобы сохранить историю для отчетов
+// Если expectedVersion не nil, подписка архивируется только при совпадении версии
+func (s *SubscriptionStorage) Archive(ctx context.Context, id uuid.UUID, expectedVersion *int) error {
+	return s.setArchived(ctx, id, true, expectedVersion)
+}
+
+// Restore возвращает архивную подписку в активное состояние
+// Если expectedVersion не nil, подписка восстанавливается только при совпадении версии
+func (s *SubscriptionStorage) Restore(ctx context.Context, id uuid.UUID, expectedVersion *int) (*models.Subscription, error) {
+	if err := s.setArchived(ctx, id, false, expectedVersion); err != nil {
+		return nil, err
 	}
 
-	return nil
+	return s.GetByID(ctx, id)
 }
 
-// Delete удаляет подписку по ID
-func (s *SubscriptionStorage) Delete(ctx context.Context, id uuid.UUID) error {
-	query := `DELETE FROM subscriptions WHERE id = $1`
+func (s *SubscriptionStorage) setArchived(ctx context.Context, id uuid.UUID, archive bool, expectedVersion *int) error {
+	query := `
+		UPDATE subscriptions
+		SET deleted_at = CASE WHEN $2 THEN NOW() END, version = version + 1, updated_at = NOW()
+		WHERE id = $1 AND (deleted_at IS NULL) = $2
+	`
+	args := []any{id, archive}
+
+	if expectedVersion != nil {
+		query += ` AND version = $3`
+		args = append(args, *expectedVersion)
+	}
 
-	cmdTag, err := s.db.Exec(ctx, query, id)
+	cmdTag, err := s.db.Exec(ctx, query, args...)
 	if err != nil {
 		return apperrors.NewInternal(err)
 	}
 
 	if cmdTag.RowsAffected() == 0 {
-		return apperrors.NewNotFound("subscription not found", nil)
+		return s.versionMismatchError(ctx, id, !archive)
 	}
 
 	return nil
 }
 
-func (s *SubscriptionStorage) List(ctx context.Context, req models.ListSubscriptionsRequest) ([]models.Subscription, int64, error) {
-	where := ""
-	args := []any{}
-	argNum := 1
+// PurgeArchived окончательно удаляет подписки, архивированные раньше olderThan
+func (s *SubscriptionStorage) PurgeArchived(ctx context.Context, olderThan time.Time) (int64, error) {
+	query := `DELETE FROM subscriptions WHERE deleted_at IS NOT NULL AND deleted_at < $1`
 
-	if req.UserID != nil {
-		where = fmt.Sprintf("WHERE user_id = $%d", argNum)
-		args = append(args, *req.UserID)
-		argNum++
+	cmdTag, err := s.db.Exec(ctx, query, olderThan)
+	if err != nil {
+		return 0, apperrors.NewInternal(err)
+	}
+
+	return cmdTag.RowsAffected(), nil
+}
+
+// versionMismatchError определяет, почему условная запись не затронула ни одной строки:
+// подписки в нужном состоянии (архивной или активной) нет или её версия изменилась
+func (s *SubscriptionStorage) versionMismatchError(ctx context.Context, id uuid.UUID, archived bool) error {
+	query := `SELECT EXISTS(SELECT 1 FROM subscriptions WHERE id = $1 AND (deleted_at IS NOT NULL) = $2)`
+
+	var exists bool
+	if err := s.db.QueryRow(ctx, query, id, archived).Scan(&exists); err != nil {
+		return apperrors.NewInternal(err)
+	}
+
+	if !exists {
+		if archived {
+			return apperrors.NewNotFound(models.ErrArchivedSubscriptionNotFound.Error(), models.ErrArchivedSubscriptionNotFound)
+		}
+		return apperrors.NewNotFound(models.ErrSubscriptionNotFound.Error(), models.ErrSubscriptionNotFound)
+	}
+
+	return apperrors.NewPreconditionFailed(models.ErrSubscriptionModified.Error(), models.ErrSubscriptionModified)
+}
+
+// sortColumns сопоставляет разрешенные поля сортировки с колонками таблицы
+var sortColumns = map[string]string{
+	"service_name": "service_name",
+	"price":        "price",
+	"user_id":      "user_id",
+	"start_date":   "start_date",
+	"end_date":     "end_date",
+	"created_at":   "created_at",
+	"updated_at":   "updated_at",
+}
+
+// List возвращает страницу подписок, удовлетворяющих фильтрам
+// Если задан курсор, возвращаются подписки, следующие за ним в порядке (created_at, id) по убыванию
+func (s *SubscriptionStorage) List(ctx context.Context, req models.ListSubscriptionsRequest) ([]models.Subscription, error) {
+	where, args := listConditions(req)
+
+	orderBy, err := listOrderBy(req.Sort)
+	if err != nil {
+		return nil, err
 	}
 
+	argNum := len(args) + 1
 	query := fmt.Sprintf(`
-		SELECT id, service_name, price, user_id, start_date, end_date, created_at, updated_at,
-		       COUNT(*) OVER() AS total_count
+		SELECT %s
 		FROM subscriptions
 		%s
-		ORDER BY created_at DESC
+		ORDER BY %s
 		LIMIT $%d OFFSET $%d
-	`, where, argNum, argNum+1)
+	`, subscriptionColumns, where, orderBy, argNum, argNum+1)
 
 	args = append(args, req.Limit, req.Offset)
 
 	rows, err := s.db.Query(ctx, query, args...)
 	if err != nil {
-		return nil, 0, apperrors.NewInternal(err)
+		return nil, apperrors.NewInternal(err)
 	}
 	defer rows.Close()
 
-	var (
-		subscriptions []models.Subscription
-		total         int64
-	)
+	var subscriptions []models.Subscription
 
 	for rows.Next() {
-		var sub models.Subscription
-		err := rows.Scan(
-			&sub.ID,
-			&sub.ServiceName,
-			&sub.Price,
-			&sub.UserID,
-			&sub.StartDate,
-			&sub.EndDate,
-			&sub.CreatedAt,
-			&sub.UpdatedAt,
-			&total,
-		)
+		sub, err := scanSubscription(rows)
 		if err != nil {
-			return nil, 0, apperrors.NewInternal(err)
+			return nil, apperrors.NewInternal(err)
 		}
 		subscriptions = append(subscriptions, sub)
 	}
 
 	if err = rows.Err(); err != nil {
-		return nil, 0, apperrors.NewInternal(err)
+		return nil, apperrors.NewInternal(err)
 	}
 
-	return subscriptions, total, nil
+	return subscriptions, nil
 }
 
-// GetTotalCost считает сумму стоимости подписок за период
-// Параметры userID и serviceName опциональные
-func (r *SubscriptionStorage) GetTotalCost(ctx context.Context, userID *uuid.UUID, serviceName string, startPeriod, endPeriod time.Time) (int, error) {
+// Export последовательно передает в fn все подписки, удовлетворяющие фильтрам, без пагинации
+// Строки читаются из открытого курсора по мере обработки, поэтому память не зависит от объема выборки
+func (s *SubscriptionStorage) Export(ctx context.Context, req models.ListSubscriptionsRequest, fn func(*models.Subscription) error) error {
+	req.Cursor = nil
+	where, args := listConditions(req)
+
+	orderBy, err := listOrderBy(req.Sort)
+	if err != nil {
+		return err
+	}
+
+	query := fmt.Sprintf(`SELECT %s FROM subscriptions %s ORDER BY %s`, subscriptionColumns, where, orderBy)
+
+	rows, err := s.db.Query(ctx, query, args...)
+	if err != nil {
+		return apperrors.NewInternal(err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		sub, err := scanSubscription(rows)
+		if err != nil {
+			return apperrors.NewInternal(err)
+		}
+		if err := fn(&sub); err != nil {
+			return err
+		}
+	}
+
+	if err := rows.Err(); err != nil {
+		return apperrors.NewInternal(err)
+	}
+
+	return nil
+}
+
+// Count возвращает количество подписок, удовлетворяющих фильтрам, без учета курсора и пагинации
+func (s *SubscriptionStorage) Count(ctx context.Context, req models.ListSubscriptionsRequest) (int64, error) {
+	req.Cursor = nil
+	where, args := listConditions(req)
+
+	var total int64
+	err := s.db.QueryRow(ctx, fmt.Sprintf(`SELECT COUNT(*) FROM subscriptions %s`, where), args...).Scan(&total)
+	if err != nil {
+		return 0, apperrors.NewInternal(err)
+	}
+
+	return total, nil
+}
+
+// CountActiveByService возвращает количество неархивных подписок, действующих в месяце month,
+// по названиям сервисов
+func (s *SubscriptionStorage) CountActiveByService(ctx context.Context, month time.Time) (map[string]int64, error) {
 	query := `
-		SELECT SUM(price) as total
+		SELECT service_name, COUNT(*)
 		FROM subscriptions
-		WHERE start_date <= $1
-		  AND (
-		    end_date IS NULL
-		    OR end_date >= $2
-		  )
+		WHERE deleted_at IS NULL AND start_date <= $1 AND (end_date IS NULL OR end_date >= $1)
+		GROUP BY service_name
 	`
 
-	args := []any{endPeriod, startPeriod}
-	argIdx := 3
+	rows, err := s.db.Query(ctx, query, month)
+	if err != nil {
+		return nil, apperrors.NewInternal(err)
+	}
+	defer rows.Close()
+
+	counts := make(map[string]int64)
 
-	if userID != nil {
-		query += fmt.Sprintf(" AND user_id = $%d", argIdx)
-		args = append(args, *userID)
-		argIdx++
+	for rows.Next() {
+		var (
+			serviceName string
+			count       int64
+		)
+		if err := rows.Scan(&serviceName, &count); err != nil {
+			return nil, apperrors.NewInternal(err)
+		}
+		counts[serviceName] = count
 	}
 
-	if serviceName != "" {
-		query += fmt.Sprintf(" AND service_name = $%d", argIdx)
-		args = append(args, serviceName)
-		argIdx++
+	if err = rows.Err(); err != nil {
+		return nil, apperrors.NewInternal(err)
 	}
 
-	var total *int
-	err := r.db.QueryRow(ctx, query, args...).Scan(&total)
-	if err != nil {
-		return 0, apperrors.NewInternal(err)
+	return counts, nil
+}
+
+// subscriptionColumns - колонки, которые читает scanSubscription
+const subscriptionColumns = `id, service_name, price, currency, billing_period, user_id, start_date, end_date, version, created_at, updated_at, deleted_at`
+
+func scanSubscription(rows pgx.Rows) (models.Subscription, error) {
+	var sub models.Subscription
+	err := rows.Scan(
+		&sub.ID,
+		&sub.ServiceName,
+		&sub.Price,
+		&sub.Currency,
+		&sub.BillingPeriod,
+		&sub.UserID,
+		&sub.StartDate,
+		&sub.EndDate,
+		&sub.Version,
+		&sub.CreatedAt,
+		&sub.UpdatedAt,
+		&sub.DeletedAt,
+	)
+	return sub, err
+}
+
+// listConditions строит условие WHERE по фильтрам списка подписок
+// Значения фильтров передаются только через параметры запроса
+func listConditions(req models.ListSubscriptionsRequest) (string, []any) {
+	var (
+		conditions []string
+		args       []any
+	)
+
+	add := func(cond string, arg any) {
+		args = append(args, arg)
+		conditions = append(conditions, fmt.Sprintf(cond, len(args)))
 	}
 
-	if total == nil {
-		return 0, apperrors.NewNotFound("no subscriptions found for the specified criteria", nil)
+	if !req.IncludeDeleted {
+		conditions = append(conditions, "deleted_at IS NULL")
+	}
+	if req.UserID != nil {
+		add("user_id = $%d", *req.UserID)
+	}
+	if req.ServiceName != "" {
+		add("service_name = $%d", req.ServiceName)
+	}
+	if req.ServiceNamePrefix != "" {
+		add(`service_name LIKE $%d || '%%'`, escapeLike(req.ServiceNamePrefix))
+	}
+	if req.PriceMin != nil {
+		add("price >= $%d", *req.PriceMin)
+	}
+	if req.PriceMax != nil {
+		add("price <= $%d", *req.PriceMax)
+	}
+	if req.ActiveAt != nil {
+		add("start_date <= $%[1]d AND (end_date IS NULL OR end_date >= $%[1]d)", *req.ActiveAt)
+	}
+	if req.StartDateFrom != nil {
+		add("start_date >= $%d", *req.StartDateFrom)
+	}
+	if req.StartDateTo != nil {
+		add("start_date <= $%d", *req.StartDateTo)
 	}
+	if req.EndDateFrom != nil {
+		add("end_date >= $%d", *req.EndDateFrom)
+	}
+	if req.EndDateTo != nil {
+		add("end_date <= $%d", *req.EndDateTo)
+	}
+	if req.OpenEnded != nil {
+		if *req.OpenEnded {
+			conditions = append(conditions, "end_date IS NULL")
+		} else {
+			conditions = append(conditions, "end_date IS NOT NULL")
+		}
+	}
+	if req.Cursor != nil {
+		args = append(args, req.Cursor.CreatedAt, req.Cursor.ID)
+		conditions = append(conditions, fmt.Sprintf("(created_at, id) < ($%d, $%d)", len(args)-1, len(args)))
+	}
+
+	if len(conditions) == 0 {
+		return "", args
+	}
+
+	return "WHERE " + strings.Join(conditions, " AND "), args
+}
+
+// listOrderBy строит ORDER BY по полям сортировки из белого списка
+// Без сортировки подписки возвращаются от новых к старым; id обеспечивает стабильный порядок
+func listOrderBy(sort []models.SortField) (string, error) {
+	if len(sort) == 0 {
+		return "created_at DESC, id DESC", nil
+	}
+
+	parts := make([]string, 0, len(sort)+1)
+	for _, f := range sort {
+		column, ok := sortColumns[f.Field]
+		if !ok {
+			return "", apperrors.NewBadRequest(models.ErrInvalidSort.Error(), models.ErrInvalidSort)
+		}
+		if f.Desc {
+			column += " DESC"
+		}
+		parts = append(parts, column)
+	}
+	parts = append(parts, "id")
+
+	return strings.Join(parts, ", "), nil
+}
 
-	return *total, nil
+// escapeLike экранирует спецсимволы шаблона LIKE
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
 }
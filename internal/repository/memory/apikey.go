@@ -0,0 +1,100 @@
+package memory
+
+import (
+	"context"
+	"slices"
+	"time"
+
+	apperrors "github.com/Gilf4/effective-mobile-task/internal/errors"
+	"github.com/Gilf4/effective-mobile-task/internal/models"
+	"github.com/google/uuid"
+)
+
+// CreateAPIKey сохраняет новый API-ключ
+func (s *SubscriptionStorage) CreateAPIKey(ctx context.Context, key *models.APIKey) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, existing := range s.apiKeys {
+		if existing.KeyHash == key.KeyHash {
+			return apperrors.NewConflict("api key already exists", nil)
+		}
+	}
+
+	key.ID = uuid.New()
+	key.CreatedAt = time.Now()
+	s.apiKeys[key.ID] = cloneAPIKey(key)
+
+	return nil
+}
+
+// GetAPIKeyByHash получает API-ключ по хешу, в том числе отозванный или истекший
+func (s *SubscriptionStorage) GetAPIKeyByHash(ctx context.Context, hash string) (*models.APIKey, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, key := range s.apiKeys {
+		if key.KeyHash == hash {
+			return cloneAPIKey(key), nil
+		}
+	}
+
+	return nil, apperrors.NewNotFound(models.ErrAPIKeyNotFound.Error(), models.ErrAPIKeyNotFound)
+}
+
+// TouchAPIKey запоминает время последнего использования API-ключа
+func (s *SubscriptionStorage) TouchAPIKey(ctx context.Context, id uuid.UUID, usedAt time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if key, ok := s.apiKeys[id]; ok {
+		key.LastUsedAt = &usedAt
+	}
+
+	return nil
+}
+
+// RevokeAPIKey отзывает действующий API-ключ
+func (s *SubscriptionStorage) RevokeAPIKey(ctx context.Context, id uuid.UUID) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key, ok := s.apiKeys[id]
+	if !ok || key.RevokedAt != nil {
+		return apperrors.NewNotFound(models.ErrAPIKeyNotFound.Error(), models.ErrAPIKeyNotFound)
+	}
+
+	now := time.Now()
+	key.RevokedAt = &now
+
+	return nil
+}
+
+// ListAPIKeys возвращает все API-ключи в порядке выпуска
+func (s *SubscriptionStorage) ListAPIKeys(ctx context.Context) ([]models.APIKey, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	keys := make([]models.APIKey, 0, len(s.apiKeys))
+	for _, key := range s.apiKeys {
+		keys = append(keys, *cloneAPIKey(key))
+	}
+
+	slices.SortFunc(keys, func(a, b models.APIKey) int {
+		if c := a.CreatedAt.Compare(b.CreatedAt); c != 0 {
+			return c
+		}
+		return slices.Compare(a.ID[:], b.ID[:])
+	})
+
+	return keys, nil
+}
+
+func cloneAPIKey(key *models.APIKey) *models.APIKey {
+	c := *key
+	c.Scopes = slices.Clone(key.Scopes)
+	c.ExpiresAt = cloneTime(key.ExpiresAt)
+	c.RevokedAt = cloneTime(key.RevokedAt)
+	c.LastUsedAt = cloneTime(key.LastUsedAt)
+	return &c
+}
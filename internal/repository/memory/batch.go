@@ -0,0 +1,88 @@
+package memory
+
+import (
+	"context"
+
+	apperrors "github.com/Gilf4/effective-mobile-task/internal/errors"
+	"github.com/Gilf4/effective-mobile-task/internal/models"
+	"github.com/google/uuid"
+)
+
+// CreateBatch создает подписки атомарно относительно других операций хранилища
+// nil-элементы subs пропускаются. Возвращает ошибки по каждому элементу (nil при успехе).
+// В режиме atomic при первой ошибке ничего не сохраняется; в режиме partial
+// сохраняются все элементы без ошибок
+func (s *SubscriptionStorage) CreateBatch(ctx context.Context, subs []*models.Subscription, atomic bool) ([]error, error) {
+	itemErrs := make([]error, len(subs))
+
+	for i, sub := range subs {
+		if sub == nil {
+			continue
+		}
+		if err := checkConstraints(sub); err != nil {
+			itemErrs[i] = err
+			if atomic {
+				return itemErrs, nil
+			}
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i, sub := range subs {
+		if sub != nil && itemErrs[i] == nil {
+			s.insert(sub)
+		}
+	}
+
+	return itemErrs, nil
+}
+
+// ArchiveBatch архивирует подписки по списку ID
+// Семантика режимов atomic и partial такая же, как у CreateBatch
+func (s *SubscriptionStorage) ArchiveBatch(ctx context.Context, ids []uuid.UUID, atomic bool) ([]error, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	itemErrs := make([]error, len(ids))
+	archived := make(map[uuid.UUID]bool, len(ids))
+
+	for i, id := range ids {
+		sub, ok := s.subscriptions[id]
+		if !ok || sub.DeletedAt != nil || archived[id] {
+			itemErrs[i] = apperrors.NewNotFound(models.ErrSubscriptionNotFound.Error(), models.ErrSubscriptionNotFound)
+			if atomic {
+				return itemErrs, nil
+			}
+			continue
+		}
+		archived[id] = true
+	}
+
+	for id := range archived {
+		if err := s.setArchived(id, true, nil); err != nil {
+			return nil, err
+		}
+	}
+
+	return itemErrs, nil
+}
+
+// Import загружает подписки: либо все, либо ни одной
+func (s *SubscriptionStorage) Import(ctx context.Context, subs []*models.Subscription) (int64, error) {
+	for _, sub := range subs {
+		if err := checkConstraints(sub); err != nil {
+			return 0, err
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, sub := range subs {
+		s.insert(sub)
+	}
+
+	return int64(len(subs)), nil
+}
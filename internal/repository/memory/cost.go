@@ -0,0 +1,219 @@
+package memory
+
+import (
+	"bytes"
+	"context"
+	"slices"
+	"strings"
+	"time"
+
+	apperrors "github.com/Gilf4/effective-mobile-task/internal/errors"
+	"github.com/Gilf4/effective-mobile-task/internal/models"
+	"github.com/google/uuid"
+)
+
+// GetMonthlySubscriptions возвращает подписки, активные в каждом месяце периода
+// Подписка попадает в результат один раз для каждого месяца, в котором она действует
+// Параметры userID и serviceName опциональные
+func (s *SubscriptionStorage) GetMonthlySubscriptions(ctx context.Context, userID *uuid.UUID, serviceName string, startPeriod, endPeriod time.Time) ([]models.MonthlySubscription, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var result []models.MonthlySubscription
+	for _, month := range periodMonths(startPeriod, endPeriod) {
+		var inMonth []models.MonthlySubscription
+		for _, sub := range s.subscriptions {
+			if matchesCost(sub, userID, serviceName) && activeInMonth(sub, month) {
+				inMonth = append(inMonth, models.MonthlySubscription{Month: month, Subscription: *clone(sub)})
+			}
+		}
+		slices.SortFunc(inMonth, func(a, b models.MonthlySubscription) int {
+			return a.CreatedAt.Compare(b.CreatedAt)
+		})
+		result = append(result, inMonth...)
+	}
+
+	return result, nil
+}
+
+// GetGroupedTotalCost считает стоимость и количество подписок за период
+// с группировкой по переданным полям и валюте: суммы в разных валютах не складываются.
+// При группировке по месяцу каждая подписка учитывается в каждом месяце, в котором она активна,
+// со списаниями этого месяца; иначе сумма считается в соответствии с mode
+// Параметры userID и serviceName опциональные, groupBy может быть пустым
+func (s *SubscriptionStorage) GetGroupedTotalCost(ctx context.Context, userID *uuid.UUID, serviceName string, startPeriod, endPeriod time.Time, mode models.TotalCostMode, groupBy []models.CostGroupBy) ([]models.CostGroup, error) {
+	byMonth := slices.Contains(groupBy, models.CostGroupByMonth)
+	for _, g := range groupBy {
+		switch g {
+		case models.CostGroupByServiceName, models.CostGroupByUserID, models.CostGroupByMonth:
+		default:
+			return nil, apperrors.NewBadRequest(models.ErrInvalidGroupBy.Error(), models.ErrInvalidGroupBy)
+		}
+	}
+
+	type groupKey struct {
+		serviceName string
+		userID      uuid.UUID
+		month       time.Time
+		currency    string
+	}
+
+	var (
+		order  []groupKey
+		groups = make(map[groupKey]*models.CostGroup)
+		seen   = make(map[groupKey]map[uuid.UUID]bool)
+	)
+
+	add := func(sub *models.Subscription, month time.Time, amount int) {
+		key := groupKey{currency: sub.Currency}
+		group := models.CostGroup{Currency: sub.Currency}
+		for _, g := range groupBy {
+			switch g {
+			case models.CostGroupByServiceName:
+				key.serviceName = sub.ServiceName
+				group.ServiceName = &key.serviceName
+			case models.CostGroupByUserID:
+				key.userID = sub.UserID
+				group.UserID = &key.userID
+			case models.CostGroupByMonth:
+				key.month = month
+				group.Month = &key.month
+			}
+		}
+
+		existing, ok := groups[key]
+		if !ok {
+			existing = &group
+			groups[key] = existing
+			seen[key] = make(map[uuid.UUID]bool)
+			order = append(order, key)
+		}
+
+		existing.TotalCost += amount
+		if !seen[key][sub.ID] {
+			seen[key][sub.ID] = true
+			existing.Count++
+		}
+	}
+
+	s.mu.RLock()
+	for _, sub := range s.subscriptions {
+		if !matchesCost(sub, userID, serviceName) {
+			continue
+		}
+		switch {
+		case byMonth:
+			for _, month := range periodMonths(startPeriod, endPeriod) {
+				if activeInMonth(sub, month) {
+					add(sub, month, sub.Price*sub.BillingPeriod.ChargesInMonth(sub.StartDate, month))
+				}
+			}
+		case activeInPeriod(sub, startPeriod, endPeriod):
+			amount := sub.Price * periodCharges(sub, startPeriod, endPeriod)
+			if mode == models.TotalCostModePerSubscription {
+				amount = sub.Price
+			}
+			add(sub, time.Time{}, amount)
+		}
+	}
+	s.mu.RUnlock()
+
+	result := make([]models.CostGroup, 0, len(order))
+	for _, key := range order {
+		result = append(result, *groups[key])
+	}
+
+	slices.SortFunc(result, func(a, b models.CostGroup) int {
+		for _, g := range groupBy {
+			var c int
+			switch g {
+			case models.CostGroupByServiceName:
+				c = strings.Compare(*a.ServiceName, *b.ServiceName)
+			case models.CostGroupByUserID:
+				c = bytes.Compare(a.UserID[:], b.UserID[:])
+			case models.CostGroupByMonth:
+				c = a.Month.Compare(*b.Month)
+			}
+			if c != 0 {
+				return c
+			}
+		}
+		return strings.Compare(a.Currency, b.Currency)
+	})
+
+	return result, nil
+}
+
+func matchesCost(sub *models.Subscription, userID *uuid.UUID, serviceName string) bool {
+	if userID != nil && sub.UserID != *userID {
+		return false
+	}
+	if serviceName != "" && sub.ServiceName != serviceName {
+		return false
+	}
+	return true
+}
+
+// effectiveEndDate - дата окончания действия подписки с учетом архивации:
+// архивная подписка считается действующей по месяц архивации включительно
+func effectiveEndDate(sub *models.Subscription) *time.Time {
+	if sub.DeletedAt == nil {
+		return sub.EndDate
+	}
+
+	deleted := truncateDay(*sub.DeletedAt)
+	if sub.EndDate != nil && sub.EndDate.Before(deleted) {
+		return sub.EndDate
+	}
+	return &deleted
+}
+
+// archivedBeforeStart сообщает, что подписка была архивирована до начала действия и не учитывается
+func archivedBeforeStart(sub *models.Subscription) bool {
+	return sub.DeletedAt != nil && truncateDay(*sub.DeletedAt).Before(sub.StartDate)
+}
+
+// activeInPeriod сообщает, пересекается ли подписка с периодом start..end
+func activeInPeriod(sub *models.Subscription, start, end time.Time) bool {
+	if sub.StartDate.After(end) || archivedBeforeStart(sub) {
+		return false
+	}
+	effectiveEnd := effectiveEndDate(sub)
+	return effectiveEnd == nil || !effectiveEnd.Before(start)
+}
+
+// activeInMonth сообщает, действует ли подписка в календарном месяце month
+func activeInMonth(sub *models.Subscription, month time.Time) bool {
+	if truncateMonth(sub.StartDate).After(month) || archivedBeforeStart(sub) {
+		return false
+	}
+	effectiveEnd := effectiveEndDate(sub)
+	return effectiveEnd == nil || !effectiveEnd.Before(month)
+}
+
+// periodCharges считает списания подписки внутри периода start..end (первые числа первого и последнего месяцев)
+// Бессрочная подписка считается активной до конца периода, архивная - до конца месяца архивации
+func periodCharges(sub *models.Subscription, start, end time.Time) int {
+	last := end
+	if effectiveEnd := effectiveEndDate(sub); effectiveEnd != nil && effectiveEnd.Before(end) {
+		last = *effectiveEnd
+	}
+	return sub.BillingPeriod.Charges(sub.StartDate, start, truncateMonth(last).AddDate(0, 1, -1))
+}
+
+// periodMonths возвращает первые дни всех календарных месяцев периода start..end
+func periodMonths(start, end time.Time) []time.Time {
+	var months []time.Time
+	for month := truncateMonth(start); !month.After(end); month = month.AddDate(0, 1, 0) {
+		months = append(months, month)
+	}
+	return months
+}
+
+func truncateMonth(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC)
+}
+
+func truncateDay(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
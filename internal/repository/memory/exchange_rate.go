@@ -0,0 +1,97 @@
+package memory
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"time"
+
+	apperrors "github.com/Gilf4/effective-mobile-task/internal/errors"
+	"github.com/Gilf4/effective-mobile-task/internal/models"
+)
+
+// exchangeRateKey - первичный ключ таблицы exchange_rates
+type exchangeRateKey struct {
+	base  string
+	quote string
+	month time.Time
+}
+
+// UpsertExchangeRates сохраняет курсы, заменяя курсы тех же пар на те же месяцы
+func (s *SubscriptionStorage) UpsertExchangeRates(ctx context.Context, rates []models.ExchangeRate) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	updatedAt := now()
+	for i := range rates {
+		rates[i].UpdatedAt = updatedAt
+		rate := rates[i]
+		s.exchangeRates[exchangeRateKey{rate.BaseCurrency, rate.QuoteCurrency, rate.Month}] = &rate
+	}
+
+	return nil
+}
+
+// ListExchangeRates возвращает курсы, упорядоченные по паре и месяцу
+// Пустые base и quote не ограничивают выборку
+func (s *SubscriptionStorage) ListExchangeRates(ctx context.Context, base, quote string) ([]models.ExchangeRate, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	rates := make([]models.ExchangeRate, 0, len(s.exchangeRates))
+	for _, rate := range s.exchangeRates {
+		if base != "" && rate.BaseCurrency != base || quote != "" && rate.QuoteCurrency != quote {
+			continue
+		}
+		rates = append(rates, *rate)
+	}
+
+	slices.SortFunc(rates, func(a, b models.ExchangeRate) int {
+		if c := strings.Compare(a.BaseCurrency, b.BaseCurrency); c != 0 {
+			return c
+		}
+		if c := strings.Compare(a.QuoteCurrency, b.QuoteCurrency); c != 0 {
+			return c
+		}
+		return a.Month.Compare(b.Month)
+	})
+
+	return rates, nil
+}
+
+// DeleteExchangeRate удаляет курс пары на месяц
+func (s *SubscriptionStorage) DeleteExchangeRate(ctx context.Context, base, quote string, month time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key := exchangeRateKey{base, quote, month}
+	if _, ok := s.exchangeRates[key]; !ok {
+		return apperrors.NewNotFound(models.ErrExchangeRateNotFound.Error(), models.ErrExchangeRateNotFound)
+	}
+	delete(s.exchangeRates, key)
+
+	return nil
+}
+
+// GetExchangeRate возвращает курс пары, действующий в месяце month: последний курс на месяц не позже month
+func (s *SubscriptionStorage) GetExchangeRate(ctx context.Context, base, quote string, month time.Time) (*models.ExchangeRate, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var found *models.ExchangeRate
+	for _, rate := range s.exchangeRates {
+		if rate.BaseCurrency != base || rate.QuoteCurrency != quote || rate.Month.After(month) {
+			continue
+		}
+		if found == nil || rate.Month.After(found.Month) {
+			found = rate
+		}
+	}
+
+	if found == nil {
+		return nil, apperrors.NewNotFound(models.ErrExchangeRateNotFound.Error(), models.ErrExchangeRateNotFound)
+	}
+
+	rate := *found
+	return &rate, nil
+}
@@ -0,0 +1,99 @@
+package memory
+
+import (
+	"context"
+	"slices"
+	"time"
+
+	apperrors "github.com/Gilf4/effective-mobile-task/internal/errors"
+	"github.com/Gilf4/effective-mobile-task/internal/models"
+)
+
+// ReserveIdempotencyKey резервирует ключ идемпотентности за текущим запросом
+// Ключ занимается заново, если он истек или его незавершенный резерв пережил аренду.
+// Если ключ уже занят, возвращается существующая запись и false
+func (s *SubscriptionStorage) ReserveIdempotencyKey(ctx context.Context, rec *models.IdempotencyRecord) (*models.IdempotencyRecord, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	t := time.Now()
+	if existing, ok := s.idempotency[rec.Key]; ok && existing.ExpiresAt.After(t) &&
+		(existing.StatusCode != nil || existing.LockedUntil.After(t)) {
+		return cloneRecord(existing), false, nil
+	}
+
+	rec.CreatedAt = now()
+	rec.StatusCode = nil
+	rec.Response = nil
+	s.idempotency[rec.Key] = cloneRecord(rec)
+
+	return rec, true, nil
+}
+
+// CreateIdempotent атомарно создает подписку и сохраняет ответ на запрос, зарезервировавший ключ.
+// Если резерв за это время занял другой запрос, подписка не создается
+func (s *SubscriptionStorage) CreateIdempotent(ctx context.Context, sub *models.Subscription, rec *models.IdempotencyRecord, statusCode int, response func(*models.Subscription) ([]byte, error)) error {
+	if err := checkConstraints(sub); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stored, ok := s.idempotency[rec.Key]
+	if !ok || stored.LockToken != rec.LockToken || stored.StatusCode != nil {
+		return apperrors.NewConflict(models.ErrIdempotencyKeyInProgress.Error(), models.ErrIdempotencyKeyInProgress)
+	}
+
+	s.insert(sub)
+	body, err := response(sub)
+	if err != nil {
+		delete(s.subscriptions, sub.ID)
+		return apperrors.NewInternal(err)
+	}
+
+	stored.StatusCode = &statusCode
+	stored.Response = body
+	stored.LockToken = ""
+	stored.LockedUntil = time.Time{}
+
+	return nil
+}
+
+// ReleaseIdempotencyKey удаляет резерв ключа с токеном lockToken, если запрос завершился ошибкой
+func (s *SubscriptionStorage) ReleaseIdempotencyKey(ctx context.Context, key, lockToken string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if rec, ok := s.idempotency[key]; ok && rec.LockToken == lockToken && rec.StatusCode == nil {
+		delete(s.idempotency, key)
+	}
+
+	return nil
+}
+
+// DeleteExpiredIdempotencyKeys удаляет ключи идемпотентности, срок хранения которых истек
+func (s *SubscriptionStorage) DeleteExpiredIdempotencyKeys(ctx context.Context, now time.Time) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var deleted int64
+	for key, rec := range s.idempotency {
+		if !rec.ExpiresAt.After(now) {
+			delete(s.idempotency, key)
+			deleted++
+		}
+	}
+
+	return deleted, nil
+}
+
+func cloneRecord(rec *models.IdempotencyRecord) *models.IdempotencyRecord {
+	c := *rec
+	c.Response = slices.Clone(rec.Response)
+	if rec.StatusCode != nil {
+		code := *rec.StatusCode
+		c.StatusCode = &code
+	}
+	return &c
+}
@@ -0,0 +1,406 @@
+package memory
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	apperrors "github.com/Gilf4/effective-mobile-task/internal/errors"
+	"github.com/Gilf4/effective-mobile-task/internal/models"
+	"github.com/google/uuid"
+)
+
+// SubscriptionStorage - потокобезопасное хранилище подписок в памяти
+// Повторяет семантику Postgres-хранилища: ошибки, порядок, пагинацию и расчет стоимости
+type SubscriptionStorage struct {
+	mu            sync.RWMutex
+	subscriptions map[uuid.UUID]*models.Subscription
+	idempotency   map[string]*models.IdempotencyRecord
+	apiKeys       map[uuid.UUID]*models.APIKey
+	exchangeRates map[exchangeRateKey]*models.ExchangeRate
+}
+
+func NewSubscriptionRepository() *SubscriptionStorage {
+	return &SubscriptionStorage{
+		subscriptions: make(map[uuid.UUID]*models.Subscription),
+		idempotency:   make(map[string]*models.IdempotencyRecord),
+		apiKeys:       make(map[uuid.UUID]*models.APIKey),
+		exchangeRates: make(map[exchangeRateKey]*models.ExchangeRate),
+	}
+}
+
+// Create создает новую запись о подписке
+func (s *SubscriptionStorage) Create(ctx context.Context, sub *models.Subscription) error {
+	if err := checkConstraints(sub); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.insert(sub)
+	return nil
+}
+
+// GetByID получает неархивную подписку по ID
+func (s *SubscriptionStorage) GetByID(ctx context.Context, id uuid.UUID) (*models.Subscription, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	sub, ok := s.subscriptions[id]
+	if !ok || sub.DeletedAt != nil {
+		return nil, apperrors.NewNotFound(models.ErrSubscriptionNotFound.Error(), models.ErrSubscriptionNotFound)
+	}
+
+	return clone(sub), nil
+}
+
+// GetOwnerID возвращает user_id подписки, в том числе архивной
+func (s *SubscriptionStorage) GetOwnerID(ctx context.Context, id uuid.UUID) (uuid.UUID, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	sub, ok := s.subscriptions[id]
+	if !ok {
+		return uuid.Nil, apperrors.NewNotFound(models.ErrSubscriptionNotFound.Error(), models.ErrSubscriptionNotFound)
+	}
+
+	return sub.UserID, nil
+}
+
+// Update обновляет данные подписки, если её версия не изменилась с момента чтения
+// При успешном обновлении версия подписки увеличивается
+func (s *SubscriptionStorage) Update(ctx context.Context, sub *models.Subscription) error {
+	if err := checkConstraints(sub); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stored, ok := s.subscriptions[sub.ID]
+	if !ok || stored.DeletedAt != nil || stored.Version != sub.Version {
+		return s.versionMismatchError(sub.ID, false)
+	}
+
+	stored.ServiceName = sub.ServiceName
+	stored.Price = sub.Price
+	stored.Currency = sub.Currency
+	stored.BillingPeriod = sub.BillingPeriod
+	stored.StartDate = sub.StartDate
+	stored.EndDate = cloneTime(sub.EndDate)
+	stored.Version++
+	stored.UpdatedAt = now()
+
+	sub.Version = stored.Version
+	sub.UpdatedAt = stored.UpdatedAt
+
+	return nil
+}
+
+// Archive помечает подписку как архивную вместо удаления
+// Если expectedVersion не nil, подписка архивируется только при совпадении версии
+func (s *SubscriptionStorage) Archive(ctx context.Context, id uuid.UUID, expectedVersion *int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.setArchived(id, true, expectedVersion)
+}
+
+// Restore возвращает архивную подписку в активное состояние
+// Если expectedVersion не nil, подписка восстанавливается только при совпадении версии
+func (s *SubscriptionStorage) Restore(ctx context.Context, id uuid.UUID, expectedVersion *int) (*models.Subscription, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.setArchived(id, false, expectedVersion); err != nil {
+		return nil, err
+	}
+
+	return clone(s.subscriptions[id]), nil
+}
+
+// PurgeArchived окончательно удаляет подписки, архивированные раньше olderThan
+func (s *SubscriptionStorage) PurgeArchived(ctx context.Context, olderThan time.Time) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var deleted int64
+	for id, sub := range s.subscriptions {
+		if sub.DeletedAt != nil && sub.DeletedAt.Before(olderThan) {
+			delete(s.subscriptions, id)
+			deleted++
+		}
+	}
+
+	return deleted, nil
+}
+
+// List возвращает страницу подписок, удовлетворяющих фильтрам
+// Если задан курсор, возвращаются подписки, следующие за ним в порядке (created_at, id) по убыванию
+func (s *SubscriptionStorage) List(ctx context.Context, req models.ListSubscriptionsRequest) ([]models.Subscription, error) {
+	subs, err := s.filtered(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if req.Offset >= len(subs) {
+		return nil, nil
+	}
+	subs = subs[req.Offset:]
+	if len(subs) > req.Limit {
+		subs = subs[:req.Limit]
+	}
+
+	return subs, nil
+}
+
+// Count возвращает количество подписок, удовлетворяющих фильтрам, без учета курсора и пагинации
+func (s *SubscriptionStorage) Count(ctx context.Context, req models.ListSubscriptionsRequest) (int64, error) {
+	req.Cursor = nil
+	req.Sort = nil
+
+	subs, err := s.filtered(req)
+	if err != nil {
+		return 0, err
+	}
+
+	return int64(len(subs)), nil
+}
+
+// Export последовательно передает в fn все подписки, удовлетворяющие фильтрам, без пагинации
+func (s *SubscriptionStorage) Export(ctx context.Context, req models.ListSubscriptionsRequest, fn func(*models.Subscription) error) error {
+	req.Cursor = nil
+
+	subs, err := s.filtered(req)
+	if err != nil {
+		return err
+	}
+
+	for i := range subs {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if err := fn(&subs[i]); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// CountActiveByService возвращает количество неархивных подписок, действующих в месяце month,
+// по названиям сервисов
+func (s *SubscriptionStorage) CountActiveByService(ctx context.Context, month time.Time) (map[string]int64, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	counts := make(map[string]int64)
+	for _, sub := range s.subscriptions {
+		if sub.DeletedAt != nil || sub.StartDate.After(month) || (sub.EndDate != nil && sub.EndDate.Before(month)) {
+			continue
+		}
+		counts[sub.ServiceName]++
+	}
+
+	return counts, nil
+}
+
+// filtered возвращает копии подписок, удовлетворяющих фильтрам, в порядке сортировки запроса
+func (s *SubscriptionStorage) filtered(req models.ListSubscriptionsRequest) ([]models.Subscription, error) {
+	less, err := listLess(req.Sort)
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.RLock()
+	var subs []models.Subscription
+	for _, sub := range s.subscriptions {
+		if matchesList(sub, req) {
+			subs = append(subs, *clone(sub))
+		}
+	}
+	s.mu.RUnlock()
+
+	slices.SortFunc(subs, less)
+
+	return subs, nil
+}
+
+func (s *SubscriptionStorage) insert(sub *models.Subscription) {
+	now := now()
+	sub.ID = uuid.New()
+	sub.Version = 1
+	sub.CreatedAt = now
+	sub.UpdatedAt = now
+	sub.DeletedAt = nil
+
+	s.subscriptions[sub.ID] = clone(sub)
+}
+
+func (s *SubscriptionStorage) setArchived(id uuid.UUID, archive bool, expectedVersion *int) error {
+	sub, ok := s.subscriptions[id]
+	if !ok || (sub.DeletedAt == nil) != archive || (expectedVersion != nil && *expectedVersion != sub.Version) {
+		return s.versionMismatchError(id, !archive)
+	}
+
+	now := now()
+	if archive {
+		sub.DeletedAt = &now
+	} else {
+		sub.DeletedAt = nil
+	}
+	sub.Version++
+	sub.UpdatedAt = now
+
+	return nil
+}
+
+// versionMismatchError определяет, почему условная запись не была выполнена:
+// подписки в нужном состоянии (архивной или активной) нет или её версия изменилась
+func (s *SubscriptionStorage) versionMismatchError(id uuid.UUID, archived bool) error {
+	sub, ok := s.subscriptions[id]
+	if !ok || (sub.DeletedAt != nil) != archived {
+		if archived {
+			return apperrors.NewNotFound(models.ErrArchivedSubscriptionNotFound.Error(), models.ErrArchivedSubscriptionNotFound)
+		}
+		return apperrors.NewNotFound(models.ErrSubscriptionNotFound.Error(), models.ErrSubscriptionNotFound)
+	}
+
+	return apperrors.NewPreconditionFailed(models.ErrSubscriptionModified.Error(), models.ErrSubscriptionModified)
+}
+
+// checkConstraints повторяет ограничения CHECK таблицы subscriptions
+func checkConstraints(sub *models.Subscription) error {
+	if sub.Price < 0 {
+		return apperrors.NewInternal(errors.New("price violates check constraint"))
+	}
+	if sub.EndDate != nil && sub.EndDate.Before(sub.StartDate) {
+		return apperrors.NewInternal(errors.New("end_date violates check constraint"))
+	}
+	if sub.BillingPeriod.Validate() != nil {
+		return apperrors.NewInternal(errors.New("billing_period violates check constraint"))
+	}
+	if len(sub.Currency) != 3 || strings.ToUpper(sub.Currency) != sub.Currency {
+		return apperrors.NewInternal(errors.New("currency violates check constraint"))
+	}
+	return nil
+}
+
+func matchesList(sub *models.Subscription, req models.ListSubscriptionsRequest) bool {
+	switch {
+	case !req.IncludeDeleted && sub.DeletedAt != nil:
+		return false
+	case req.UserID != nil && sub.UserID != *req.UserID:
+		return false
+	case req.ServiceName != "" && sub.ServiceName != req.ServiceName:
+		return false
+	case req.ServiceNamePrefix != "" && !strings.HasPrefix(sub.ServiceName, req.ServiceNamePrefix):
+		return false
+	case req.PriceMin != nil && sub.Price < *req.PriceMin:
+		return false
+	case req.PriceMax != nil && sub.Price > *req.PriceMax:
+		return false
+	case req.ActiveAt != nil && (sub.StartDate.After(*req.ActiveAt) || (sub.EndDate != nil && sub.EndDate.Before(*req.ActiveAt))):
+		return false
+	case req.StartDateFrom != nil && sub.StartDate.Before(*req.StartDateFrom):
+		return false
+	case req.StartDateTo != nil && sub.StartDate.After(*req.StartDateTo):
+		return false
+	case req.EndDateFrom != nil && (sub.EndDate == nil || sub.EndDate.Before(*req.EndDateFrom)):
+		return false
+	case req.EndDateTo != nil && (sub.EndDate == nil || sub.EndDate.After(*req.EndDateTo)):
+		return false
+	case req.OpenEnded != nil && *req.OpenEnded != (sub.EndDate == nil):
+		return false
+	case req.Cursor != nil && compareCreated(sub, req.Cursor.CreatedAt, req.Cursor.ID) >= 0:
+		return false
+	}
+	return true
+}
+
+// listLess возвращает функцию сравнения для сортировки списка
+// Порядок совпадает с Postgres: NULL больше любого значения, id - последний ключ
+func listLess(sort []models.SortField) (func(a, b models.Subscription) int, error) {
+	if len(sort) == 0 {
+		return func(a, b models.Subscription) int {
+			return -compareCreated(&a, b.CreatedAt, b.ID)
+		}, nil
+	}
+
+	compares := make([]func(a, b *models.Subscription) int, 0, len(sort))
+	for _, f := range sort {
+		cmp, ok := sortCompares[f.Field]
+		if !ok {
+			return nil, apperrors.NewBadRequest(models.ErrInvalidSort.Error(), models.ErrInvalidSort)
+		}
+		if f.Desc {
+			asc := cmp
+			cmp = func(a, b *models.Subscription) int { return -asc(a, b) }
+		}
+		compares = append(compares, cmp)
+	}
+
+	return func(a, b models.Subscription) int {
+		for _, cmp := range compares {
+			if c := cmp(&a, &b); c != 0 {
+				return c
+			}
+		}
+		return bytes.Compare(a.ID[:], b.ID[:])
+	}, nil
+}
+
+var sortCompares = map[string]func(a, b *models.Subscription) int{
+	"service_name": func(a, b *models.Subscription) int { return strings.Compare(a.ServiceName, b.ServiceName) },
+	"price":        func(a, b *models.Subscription) int { return a.Price - b.Price },
+	"user_id":      func(a, b *models.Subscription) int { return bytes.Compare(a.UserID[:], b.UserID[:]) },
+	"start_date":   func(a, b *models.Subscription) int { return a.StartDate.Compare(b.StartDate) },
+	"end_date":     func(a, b *models.Subscription) int { return compareNullableTime(a.EndDate, b.EndDate) },
+	"created_at":   func(a, b *models.Subscription) int { return a.CreatedAt.Compare(b.CreatedAt) },
+	"updated_at":   func(a, b *models.Subscription) int { return a.UpdatedAt.Compare(b.UpdatedAt) },
+}
+
+// compareCreated сравнивает подписку с позицией (createdAt, id)
+func compareCreated(sub *models.Subscription, createdAt time.Time, id uuid.UUID) int {
+	if c := sub.CreatedAt.Compare(createdAt); c != 0 {
+		return c
+	}
+	return bytes.Compare(sub.ID[:], id[:])
+}
+
+func compareNullableTime(a, b *time.Time) int {
+	switch {
+	case a == nil && b == nil:
+		return 0
+	case a == nil:
+		return 1
+	case b == nil:
+		return -1
+	}
+	return a.Compare(*b)
+}
+
+// now возвращает текущее время с точностью Postgres timestamptz
+func now() time.Time {
+	return time.Now().Truncate(time.Microsecond)
+}
+
+func clone(sub *models.Subscription) *models.Subscription {
+	c := *sub
+	c.EndDate = cloneTime(sub.EndDate)
+	c.DeletedAt = cloneTime(sub.DeletedAt)
+	return &c
+}
+
+func cloneTime(t *time.Time) *time.Time {
+	if t == nil {
+		return nil
+	}
+	c := *t
+	return &c
+}
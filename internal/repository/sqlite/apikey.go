@@ -0,0 +1,139 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strings"
+	"time"
+
+	apperrors "github.com/Gilf4/effective-mobile-task/internal/errors"
+	"github.com/Gilf4/effective-mobile-task/internal/models"
+	"github.com/google/uuid"
+)
+
+// scopesSeparator разделяет области доступа в колонке scopes
+const scopesSeparator = ","
+
+// CreateAPIKey сохраняет новый API-ключ
+func (s *SubscriptionStorage) CreateAPIKey(ctx context.Context, key *models.APIKey) error {
+	query := `
+		INSERT INTO api_keys (id, name, key_hash, scopes, expires_at, created_at)
+		VALUES (?1, ?2, ?3, ?4, ?5, ?6)
+		RETURNING created_at
+	`
+
+	var expiresAt any
+	if key.ExpiresAt != nil {
+		expiresAt = formatTimestamp(*key.ExpiresAt)
+	}
+
+	id := uuid.New()
+	err := s.db.QueryRowContext(ctx, query,
+		id,
+		key.Name,
+		key.KeyHash,
+		strings.Join(key.Scopes, scopesSeparator),
+		expiresAt,
+		formatTimestamp(time.Now()),
+	).Scan(timeColumn{&key.CreatedAt})
+	if err != nil {
+		return apperrors.NewInternal(err)
+	}
+	key.ID = id
+
+	return nil
+}
+
+// GetAPIKeyByHash получает API-ключ по хешу, в том числе отозванный или истекший
+func (s *SubscriptionStorage) GetAPIKeyByHash(ctx context.Context, hash string) (*models.APIKey, error) {
+	query := `SELECT ` + apiKeyColumns + ` FROM api_keys WHERE key_hash = ?1`
+
+	key, err := scanAPIKey(s.db.QueryRowContext(ctx, query, hash))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, apperrors.NewNotFound(models.ErrAPIKeyNotFound.Error(), errors.Join(models.ErrAPIKeyNotFound, err))
+		}
+		return nil, apperrors.NewInternal(err)
+	}
+
+	return &key, nil
+}
+
+// TouchAPIKey запоминает время последнего использования API-ключа
+func (s *SubscriptionStorage) TouchAPIKey(ctx context.Context, id uuid.UUID, usedAt time.Time) error {
+	if _, err := s.db.ExecContext(ctx, `UPDATE api_keys SET last_used_at = ?2 WHERE id = ?1`, id, formatTimestamp(usedAt)); err != nil {
+		return apperrors.NewInternal(err)
+	}
+	return nil
+}
+
+// RevokeAPIKey отзывает действующий API-ключ
+func (s *SubscriptionStorage) RevokeAPIKey(ctx context.Context, id uuid.UUID) error {
+	query := `UPDATE api_keys SET revoked_at = ?2 WHERE id = ?1 AND revoked_at IS NULL`
+
+	res, err := s.db.ExecContext(ctx, query, id, formatTimestamp(time.Now()))
+	if err != nil {
+		return apperrors.NewInternal(err)
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return apperrors.NewInternal(err)
+	}
+
+	if affected == 0 {
+		return apperrors.NewNotFound(models.ErrAPIKeyNotFound.Error(), models.ErrAPIKeyNotFound)
+	}
+
+	return nil
+}
+
+// ListAPIKeys возвращает все API-ключи в порядке выпуска
+func (s *SubscriptionStorage) ListAPIKeys(ctx context.Context) ([]models.APIKey, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT `+apiKeyColumns+` FROM api_keys ORDER BY created_at, id`)
+	if err != nil {
+		return nil, apperrors.NewInternal(err)
+	}
+	defer rows.Close()
+
+	var keys []models.APIKey
+
+	for rows.Next() {
+		key, err := scanAPIKey(rows)
+		if err != nil {
+			return nil, apperrors.NewInternal(err)
+		}
+		keys = append(keys, key)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, apperrors.NewInternal(err)
+	}
+
+	return keys, nil
+}
+
+// apiKeyColumns - колонки, которые читает scanAPIKey
+const apiKeyColumns = `id, name, key_hash, scopes, expires_at, revoked_at, last_used_at, created_at`
+
+func scanAPIKey(row scanner) (models.APIKey, error) {
+	var (
+		key    models.APIKey
+		scopes string
+	)
+	err := row.Scan(
+		&key.ID,
+		&key.Name,
+		&key.KeyHash,
+		&scopes,
+		nullTimeColumn{&key.ExpiresAt},
+		nullTimeColumn{&key.RevokedAt},
+		nullTimeColumn{&key.LastUsedAt},
+		timeColumn{&key.CreatedAt},
+	)
+	if scopes != "" {
+		key.Scopes = strings.Split(scopes, scopesSeparator)
+	}
+	return key, err
+}
@@ -0,0 +1,93 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	apperrors "github.com/Gilf4/effective-mobile-task/internal/errors"
+	"github.com/Gilf4/effective-mobile-task/internal/models"
+	"github.com/google/uuid"
+)
+
+// CreateBatch создает подписки в одной транзакции
+// nil-элементы subs пропускаются. Возвращает ошибки по каждому элементу (nil при успехе).
+// В режиме atomic при первой ошибке транзакция откатывается и ничего не сохраняется;
+// в режиме partial каждая запись выполняется в отдельной точке сохранения
+func (s *SubscriptionStorage) CreateBatch(ctx context.Context, subs []*models.Subscription, atomic bool) ([]error, error) {
+	return s.runBatch(ctx, len(subs), atomic, func(tx *sql.Tx, i int) (bool, error) {
+		if subs[i] == nil {
+			return false, nil
+		}
+		return true, insertSubscription(ctx, tx, subs[i])
+	})
+}
+
+// ArchiveBatch архивирует подписки по списку ID в одной транзакции
+// Семантика режимов atomic и partial такая же, как у CreateBatch
+func (s *SubscriptionStorage) ArchiveBatch(ctx context.Context, ids []uuid.UUID, atomic bool) ([]error, error) {
+	query := `
+		UPDATE subscriptions
+		SET deleted_at = ?2, version = version + 1, updated_at = ?2
+		WHERE id = ?1 AND deleted_at IS NULL
+	`
+	now := formatTimestamp(time.Now())
+
+	return s.runBatch(ctx, len(ids), atomic, func(tx *sql.Tx, i int) (bool, error) {
+		res, err := tx.ExecContext(ctx, query, ids[i], now)
+		if err != nil {
+			return true, apperrors.NewInternal(err)
+		}
+		affected, err := res.RowsAffected()
+		if err != nil {
+			return true, apperrors.NewInternal(err)
+		}
+		if affected == 0 {
+			return true, apperrors.NewNotFound(models.ErrSubscriptionNotFound.Error(), models.ErrSubscriptionNotFound)
+		}
+		return true, nil
+	})
+}
+
+// runBatch выполняет apply для каждого элемента в одной транзакции
+// apply возвращает false, если элемент пропущен
+func (s *SubscriptionStorage) runBatch(ctx context.Context, n int, atomic bool, apply func(tx *sql.Tx, i int) (bool, error)) ([]error, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, apperrors.NewInternal(err)
+	}
+	defer tx.Rollback()
+
+	itemErrs := make([]error, n)
+
+	for i := range n {
+		if atomic {
+			if _, err := apply(tx, i); err != nil {
+				itemErrs[i] = err
+				return itemErrs, nil
+			}
+			continue
+		}
+
+		if _, err := tx.ExecContext(ctx, `SAVEPOINT batch_item`); err != nil {
+			return nil, apperrors.NewInternal(err)
+		}
+
+		if _, err := apply(tx, i); err != nil {
+			itemErrs[i] = err
+			if _, rbErr := tx.ExecContext(ctx, `ROLLBACK TO batch_item`); rbErr != nil {
+				return nil, apperrors.NewInternal(rbErr)
+			}
+		}
+
+		if _, err := tx.ExecContext(ctx, `RELEASE batch_item`); err != nil {
+			return nil, apperrors.NewInternal(err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, apperrors.NewInternal(err)
+	}
+
+	return itemErrs, nil
+}
@@ -0,0 +1,224 @@
+package sqlite
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	apperrors "github.com/Gilf4/effective-mobile-task/internal/errors"
+	"github.com/Gilf4/effective-mobile-task/internal/models"
+	"github.com/google/uuid"
+)
+
+// effectiveEndDateExpr - дата окончания действия подписки с учетом архивации:
+// архивная подписка считается действующей по месяц архивации включительно.
+// В отличие от LEAST в Postgres, MIN в SQLite возвращает NULL при любом NULL-аргументе
+const effectiveEndDateExpr = `COALESCE(MIN(end_date, date(deleted_at)), end_date, date(deleted_at))`
+
+// activeInPeriodCond отбирает подписки, пересекающиеся с периодом ?2..?1
+// Подписка, архивированная до начала действия, не учитывается
+const activeInPeriodCond = `start_date <= ?1
+		  AND (` + effectiveEndDateExpr + ` IS NULL OR ` + effectiveEndDateExpr + ` >= ?2)
+		  AND (deleted_at IS NULL OR date(deleted_at) >= start_date)`
+
+// monthsCTE разворачивает период ?2..?1 в календарные месяцы months.month
+const monthsCTE = `WITH RECURSIVE months(month) AS (
+		SELECT date(?2, 'start of month')
+		UNION ALL
+		SELECT date(month, '+1 month') FROM months WHERE date(month, '+1 month') <= ?1
+	)`
+
+// monthlyActiveJoin соединяет каждый месяц m из monthsCTE с подписками s, активными в нём
+const monthlyActiveJoin = `months AS m
+		JOIN subscriptions s
+		  ON date(start_date, 'start of month') <= m.month
+		 AND (` + effectiveEndDateExpr + ` IS NULL OR ` + effectiveEndDateExpr + ` >= m.month)
+		 AND (deleted_at IS NULL OR date(deleted_at) >= start_date)`
+
+// periodChargesExpr считает списания подписки внутри периода ?2..?1 (начало ?2, последний месяц ?1).
+// Бессрочная подписка считается активной до конца периода, архивная - до конца месяца архивации
+var periodChargesExpr = chargesExpr(
+	`MAX(start_date, ?2)`,
+	`date(MIN(COALESCE(`+effectiveEndDateExpr+`, ?1), ?1), 'start of month', '+1 month', '-1 day')`,
+)
+
+// monthChargesExpr считает списания подписки в месяце m.month из monthlyActiveJoin
+var monthChargesExpr = chargesExpr(
+	`MAX(start_date, m.month)`,
+	`date(m.month, '+1 month', '-1 day')`,
+)
+
+// chargesExpr считает списания подписки с датами между firstDayExpr и lastDayExpr включительно
+// Списания приходятся на start_date и далее через каждый период billing_period. Подписки начинаются
+// с первого числа месяца, поэтому помесячные периоды считаются по номерам месяцев, недельные - по дням.
+// firstDayExpr не должен быть раньше start_date
+func chargesExpr(firstDayExpr, lastDayExpr string) string {
+	months := `(CASE billing_period WHEN 'quarter' THEN 3 WHEN 'year' THEN 12 ELSE 1 END)`
+	start := monthIndex("start_date")
+
+	return fmt.Sprintf(`(CASE billing_period
+		WHEN 'week' THEN %[2]s / 7 - (%[1]s + 6) / 7
+		ELSE (%[4]s - %[6]s) / %[5]s - (%[3]s - %[6]s + %[5]s - 1) / %[5]s
+	END + 1)`, daysSinceStart(firstDayExpr), daysSinceStart(lastDayExpr), monthIndex(firstDayExpr), monthIndex(lastDayExpr), months, start)
+}
+
+// daysSinceStart возвращает количество дней от start_date до даты
+func daysSinceStart(dateExpr string) string {
+	return fmt.Sprintf(`CAST(julianday(%s) - julianday(start_date) AS INTEGER)`, dateExpr)
+}
+
+// monthIndex возвращает номер месяца даты от начала летоисчисления
+func monthIndex(dateExpr string) string {
+	return fmt.Sprintf(`(CAST(strftime('%%Y', %[1]s) AS INTEGER) * 12 + CAST(strftime('%%m', %[1]s) AS INTEGER))`, dateExpr)
+}
+
+// GetMonthlySubscriptions возвращает подписки, активные в каждом месяце периода
+// Подписка попадает в результат один раз для каждого месяца, в котором она действует
+// Параметры userID и serviceName опциональные
+func (s *SubscriptionStorage) GetMonthlySubscriptions(ctx context.Context, userID *uuid.UUID, serviceName string, startPeriod, endPeriod time.Time) ([]models.MonthlySubscription, error) {
+	query := monthsCTE + `
+		SELECT m.month, s.id, s.service_name, s.price, s.currency, s.billing_period, s.user_id, s.start_date, s.end_date, s.version, s.created_at, s.updated_at, s.deleted_at
+		FROM ` + monthlyActiveJoin + `
+		WHERE TRUE
+	`
+
+	args := periodArgs(startPeriod, endPeriod)
+	query, args = appendCostFilters(query, args, userID, serviceName)
+	query += " ORDER BY m.month, s.created_at"
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, apperrors.NewInternal(err)
+	}
+	defer rows.Close()
+
+	var result []models.MonthlySubscription
+
+	for rows.Next() {
+		var month time.Time
+		sub, err := scanSubscription(rows, timeColumn{&month})
+		if err != nil {
+			return nil, apperrors.NewInternal(err)
+		}
+		result = append(result, models.MonthlySubscription{Month: month, Subscription: sub})
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, apperrors.NewInternal(err)
+	}
+
+	return result, nil
+}
+
+// costGroupColumns сопоставляет поля группировки с выражениями SQL
+var costGroupColumns = map[models.CostGroupBy]string{
+	models.CostGroupByServiceName: "service_name",
+	models.CostGroupByUserID:      "user_id",
+	models.CostGroupByMonth:       "m.month",
+}
+
+// GetGroupedTotalCost считает стоимость и количество подписок за период одним запросом
+// с группировкой по переданным полям и валюте: суммы в разных валютах не складываются.
+// При группировке по месяцу каждая подписка учитывается в каждом месяце, в котором она активна,
+// со списаниями этого месяца; иначе сумма считается в соответствии с mode
+// Параметры userID и serviceName опциональные, groupBy может быть пустым
+func (s *SubscriptionStorage) GetGroupedTotalCost(ctx context.Context, userID *uuid.UUID, serviceName string, startPeriod, endPeriod time.Time, mode models.TotalCostMode, groupBy []models.CostGroupBy) ([]models.CostGroup, error) {
+	columns := make([]string, 0, len(groupBy)+1)
+	byMonth := false
+	for _, g := range groupBy {
+		column, ok := costGroupColumns[g]
+		if !ok {
+			return nil, apperrors.NewBadRequest(models.ErrInvalidGroupBy.Error(), models.ErrInvalidGroupBy)
+		}
+		if g == models.CostGroupByMonth {
+			byMonth = true
+		}
+		columns = append(columns, column)
+	}
+	columns = append(columns, "currency")
+	groupList := strings.Join(columns, ", ")
+
+	var query string
+	switch {
+	case byMonth:
+		query = fmt.Sprintf(`%s
+			SELECT %s, SUM(s.price * %s), COUNT(DISTINCT s.id)
+			FROM %s
+			WHERE TRUE
+		`, monthsCTE, groupList, monthChargesExpr, monthlyActiveJoin)
+	default:
+		amountExpr := "price * " + periodChargesExpr
+		if mode == models.TotalCostModePerSubscription {
+			amountExpr = "price"
+		}
+		query = fmt.Sprintf(`
+			SELECT %s, SUM(%s), COUNT(*)
+			FROM subscriptions
+			WHERE %s
+		`, groupList, amountExpr, activeInPeriodCond)
+	}
+
+	args := periodArgs(startPeriod, endPeriod)
+	query, args = appendCostFilters(query, args, userID, serviceName)
+	query += fmt.Sprintf(" GROUP BY %s ORDER BY %s", groupList, groupList)
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, apperrors.NewInternal(err)
+	}
+	defer rows.Close()
+
+	var groups []models.CostGroup
+
+	for rows.Next() {
+		var group models.CostGroup
+		dest := make([]any, 0, len(groupBy)+3)
+		for _, g := range groupBy {
+			switch g {
+			case models.CostGroupByServiceName:
+				dest = append(dest, &group.ServiceName)
+			case models.CostGroupByUserID:
+				dest = append(dest, &group.UserID)
+			case models.CostGroupByMonth:
+				dest = append(dest, nullTimeColumn{&group.Month})
+			}
+		}
+		dest = append(dest, &group.Currency, &group.TotalCost, &group.Count)
+
+		if err := rows.Scan(dest...); err != nil {
+			return nil, apperrors.NewInternal(err)
+		}
+		groups = append(groups, group)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, apperrors.NewInternal(err)
+	}
+
+	return groups, nil
+}
+
+// periodArgs возвращает параметры ?1 (конец периода) и ?2 (начало периода)
+func periodArgs(startPeriod, endPeriod time.Time) []any {
+	return []any{formatDate(endPeriod), formatDate(startPeriod)}
+}
+
+// appendCostFilters добавляет к запросу опциональные фильтры по user_id и service_name
+// Ожидается, что запрос уже содержит WHERE и использует параметры ?1 и ?2
+func appendCostFilters(query string, args []any, userID *uuid.UUID, serviceName string) (string, []any) {
+	argIdx := len(args) + 1
+
+	if userID != nil {
+		query += fmt.Sprintf(" AND user_id = ?%d", argIdx)
+		args = append(args, *userID)
+		argIdx++
+	}
+
+	if serviceName != "" {
+		query += fmt.Sprintf(" AND service_name = ?%d", argIdx)
+		args = append(args, serviceName)
+	}
+
+	return query, args
+}
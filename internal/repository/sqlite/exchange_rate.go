@@ -0,0 +1,136 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	apperrors "github.com/Gilf4/effective-mobile-task/internal/errors"
+	"github.com/Gilf4/effective-mobile-task/internal/models"
+)
+
+// UpsertExchangeRates сохраняет курсы в одной транзакции, заменяя курсы тех же пар на те же месяцы
+func (s *SubscriptionStorage) UpsertExchangeRates(ctx context.Context, rates []models.ExchangeRate) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return apperrors.NewInternal(err)
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.PrepareContext(ctx, `
+		INSERT INTO exchange_rates (base_currency, quote_currency, month, rate_e8, updated_at)
+		VALUES (?1, ?2, ?3, ?4, ?5)
+		ON CONFLICT (base_currency, quote_currency, month) DO UPDATE
+		SET rate_e8 = excluded.rate_e8, updated_at = excluded.updated_at
+	`)
+	if err != nil {
+		return apperrors.NewInternal(err)
+	}
+	defer stmt.Close()
+
+	now := time.Now()
+	for i := range rates {
+		rate := &rates[i]
+		if _, err := stmt.ExecContext(ctx, rate.BaseCurrency, rate.QuoteCurrency, formatDate(rate.Month), int64(rate.Rate), formatTimestamp(now)); err != nil {
+			return apperrors.NewInternal(err)
+		}
+		rate.UpdatedAt = now
+	}
+
+	if err := tx.Commit(); err != nil {
+		return apperrors.NewInternal(err)
+	}
+
+	return nil
+}
+
+// ListExchangeRates возвращает курсы, упорядоченные по паре и месяцу
+// Пустые base и quote не ограничивают выборку
+func (s *SubscriptionStorage) ListExchangeRates(ctx context.Context, base, quote string) ([]models.ExchangeRate, error) {
+	query := `
+		SELECT ` + exchangeRateColumns + `
+		FROM exchange_rates
+		WHERE (?1 = '' OR base_currency = ?1) AND (?2 = '' OR quote_currency = ?2)
+		ORDER BY base_currency, quote_currency, month
+	`
+
+	rows, err := s.db.QueryContext(ctx, query, base, quote)
+	if err != nil {
+		return nil, apperrors.NewInternal(err)
+	}
+	defer rows.Close()
+
+	var rates []models.ExchangeRate
+
+	for rows.Next() {
+		rate, err := scanExchangeRate(rows)
+		if err != nil {
+			return nil, apperrors.NewInternal(err)
+		}
+		rates = append(rates, rate)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, apperrors.NewInternal(err)
+	}
+
+	return rates, nil
+}
+
+// DeleteExchangeRate удаляет курс пары на месяц
+func (s *SubscriptionStorage) DeleteExchangeRate(ctx context.Context, base, quote string, month time.Time) error {
+	query := `DELETE FROM exchange_rates WHERE base_currency = ?1 AND quote_currency = ?2 AND month = ?3`
+
+	res, err := s.db.ExecContext(ctx, query, base, quote, formatDate(month))
+	if err != nil {
+		return apperrors.NewInternal(err)
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return apperrors.NewInternal(err)
+	}
+
+	if affected == 0 {
+		return apperrors.NewNotFound(models.ErrExchangeRateNotFound.Error(), models.ErrExchangeRateNotFound)
+	}
+
+	return nil
+}
+
+// GetExchangeRate возвращает курс пары, действующий в месяце month: последний курс на месяц не позже month
+func (s *SubscriptionStorage) GetExchangeRate(ctx context.Context, base, quote string, month time.Time) (*models.ExchangeRate, error) {
+	query := `
+		SELECT ` + exchangeRateColumns + `
+		FROM exchange_rates
+		WHERE base_currency = ?1 AND quote_currency = ?2 AND month <= ?3
+		ORDER BY month DESC
+		LIMIT 1
+	`
+
+	rate, err := scanExchangeRate(s.db.QueryRowContext(ctx, query, base, quote, formatDate(month)))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, apperrors.NewNotFound(models.ErrExchangeRateNotFound.Error(), errors.Join(models.ErrExchangeRateNotFound, err))
+		}
+		return nil, apperrors.NewInternal(err)
+	}
+
+	return &rate, nil
+}
+
+// exchangeRateColumns - колонки, которые читает scanExchangeRate
+const exchangeRateColumns = `base_currency, quote_currency, month, rate_e8, updated_at`
+
+func scanExchangeRate(row scanner) (models.ExchangeRate, error) {
+	var rate models.ExchangeRate
+	err := row.Scan(
+		&rate.BaseCurrency,
+		&rate.QuoteCurrency,
+		timeColumn{&rate.Month},
+		&rate.Rate,
+		timeColumn{&rate.UpdatedAt},
+	)
+	return rate, err
+}
@@ -0,0 +1,52 @@
+package sqlite
+
+import (
+	"context"
+	"time"
+
+	apperrors "github.com/Gilf4/effective-mobile-task/internal/errors"
+	"github.com/Gilf4/effective-mobile-task/internal/models"
+	"github.com/google/uuid"
+)
+
+// Import загружает подписки подготовленным запросом в одной транзакции
+func (s *SubscriptionStorage) Import(ctx context.Context, subs []*models.Subscription) (int64, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, apperrors.NewInternal(err)
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.PrepareContext(ctx, `
+		INSERT INTO subscriptions (id, service_name, price, currency, billing_period, user_id, start_date, end_date, created_at, updated_at)
+		VALUES (?1, ?2, ?3, ?4, ?5, ?6, ?7, ?8, ?9, ?9)
+	`)
+	if err != nil {
+		return 0, apperrors.NewInternal(err)
+	}
+	defer stmt.Close()
+
+	now := formatTimestamp(time.Now())
+	for _, sub := range subs {
+		_, err := stmt.ExecContext(ctx,
+			uuid.New(),
+			sub.ServiceName,
+			sub.Price,
+			sub.Currency,
+			sub.BillingPeriod,
+			sub.UserID,
+			formatDate(sub.StartDate),
+			formatNullDate(sub.EndDate),
+			now,
+		)
+		if err != nil {
+			return 0, apperrors.NewInternal(err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, apperrors.NewInternal(err)
+	}
+
+	return int64(len(subs)), nil
+}
@@ -0,0 +1,84 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"fmt"
+	"io/fs"
+	"time"
+
+	"github.com/Gilf4/effective-mobile-task/internal/migrate"
+)
+
+// migrationsFS содержит схему SQLite, повторяющую миграции Postgres из каталога migrations
+//
+//go:embed migrations/*.sql
+var migrationsFS embed.FS
+
+// applyMigrations применяет еще не примененные встроенные миграции по возрастанию версии
+// Каждая миграция выполняется в отдельной транзакции вместе с записью в schema_migrations
+func applyMigrations(ctx context.Context, db *sql.DB) error {
+	sub, err := fs.Sub(migrationsFS, "migrations")
+	if err != nil {
+		return err
+	}
+
+	migrations, err := migrate.Load(sub)
+	if err != nil {
+		return err
+	}
+
+	_, err = db.ExecContext(ctx, `
+		CREATE TABLE IF NOT EXISTS schema_migrations (
+		  version INTEGER PRIMARY KEY,
+		  applied_at TEXT NOT NULL
+		)
+	`)
+	if err != nil {
+		return fmt.Errorf("create schema_migrations: %w", err)
+	}
+
+	var current int64
+	err = db.QueryRowContext(ctx, `SELECT COALESCE(MAX(version), 0) FROM schema_migrations`).Scan(&current)
+	if err != nil {
+		return err
+	}
+	if latest := migrate.Latest(migrations); current > latest {
+		return fmt.Errorf("%w: applied version %d, latest known %d", migrate.ErrSchemaTooNew, current, latest)
+	}
+
+	for _, mig := range migrations {
+		if err := applyMigration(ctx, db, mig); err != nil {
+			return fmt.Errorf("apply %s: %w", mig.Name, err)
+		}
+	}
+
+	return nil
+}
+
+func applyMigration(ctx context.Context, db *sql.DB, mig migrate.Migration) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var applied bool
+	err = tx.QueryRowContext(ctx, `SELECT EXISTS(SELECT 1 FROM schema_migrations WHERE version = ?)`, mig.Version).Scan(&applied)
+	if err != nil || applied {
+		return err
+	}
+
+	if _, err := tx.ExecContext(ctx, mig.Up); err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, `INSERT INTO schema_migrations (version, applied_at) VALUES (?, ?)`,
+		mig.Version, formatTimestamp(time.Now()))
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
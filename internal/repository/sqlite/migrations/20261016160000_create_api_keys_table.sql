@@ -0,0 +1,14 @@
+-- +goose Up
+CREATE TABLE IF NOT EXISTS api_keys (
+  id TEXT PRIMARY KEY,
+  name TEXT NOT NULL,
+  key_hash TEXT NOT NULL UNIQUE,
+  scopes TEXT NOT NULL,
+  expires_at TEXT NULL,
+  revoked_at TEXT NULL,
+  last_used_at TEXT NULL,
+  created_at TEXT NOT NULL
+);
+
+-- +goose Down
+DROP TABLE IF EXISTS api_keys;
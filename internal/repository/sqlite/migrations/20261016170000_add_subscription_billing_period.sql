@@ -0,0 +1,6 @@
+-- +goose Up
+ALTER TABLE subscriptions ADD COLUMN billing_period TEXT NOT NULL DEFAULT 'month'
+  CHECK (billing_period IN ('month', 'quarter', 'year', 'week'));
+
+-- +goose Down
+ALTER TABLE subscriptions DROP COLUMN billing_period;
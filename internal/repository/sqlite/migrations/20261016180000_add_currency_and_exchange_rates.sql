@@ -0,0 +1,21 @@
+-- +goose Up
+ALTER TABLE subscriptions ADD COLUMN currency TEXT NOT NULL DEFAULT 'RUB'
+  CHECK (length(currency) = 3 AND currency = upper(currency));
+
+-- price хранится в минорных единицах валюты: прежние цены были в целых рублях
+UPDATE subscriptions SET price = price * 100;
+
+CREATE TABLE IF NOT EXISTS exchange_rates (
+  base_currency TEXT NOT NULL CHECK (length(base_currency) = 3),
+  quote_currency TEXT NOT NULL CHECK (length(quote_currency) = 3),
+  month TEXT NOT NULL,
+  rate_e8 INTEGER NOT NULL CHECK (rate_e8 > 0),
+  updated_at TEXT NOT NULL,
+  PRIMARY KEY (base_currency, quote_currency, month),
+  CHECK (base_currency <> quote_currency)
+);
+
+-- +goose Down
+DROP TABLE IF EXISTS exchange_rates;
+UPDATE subscriptions SET price = price / 100;
+ALTER TABLE subscriptions DROP COLUMN currency;
@@ -0,0 +1,497 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/Gilf4/effective-mobile-task/internal/config"
+	apperrors "github.com/Gilf4/effective-mobile-task/internal/errors"
+	"github.com/Gilf4/effective-mobile-task/internal/models"
+	"github.com/google/uuid"
+	_ "modernc.org/sqlite"
+)
+
+// SubscriptionStorage - хранилище подписок во встроенной базе SQLite
+// Повторяет семантику Postgres-хранилища, включая ограничения CHECK таблицы subscriptions
+type SubscriptionStorage struct {
+	db *sql.DB
+}
+
+// NewSubscriptionRepository открывает файл базы по пути из конфигурации и применяет встроенные миграции
+func NewSubscriptionRepository(ctx context.Context, dbCfg *config.DBConfig) (*SubscriptionStorage, error) {
+	params := url.Values{}
+	params.Add("_pragma", "foreign_keys(1)")
+	params.Add("_pragma", "busy_timeout(5000)")
+	params.Add("_pragma", "journal_mode(WAL)")
+	params.Set("_txlock", "immediate")
+
+	db, err := sql.Open("sqlite", "file:"+dbCfg.Path+"?"+params.Encode())
+	if err != nil {
+		return nil, err
+	}
+
+	if err := db.PingContext(ctx); err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	if err := applyMigrations(ctx, db); err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	return &SubscriptionStorage{db: db}, nil
+}
+
+// Ping проверяет доступность файла базы
+func (s *SubscriptionStorage) Ping(ctx context.Context) error {
+	return s.db.PingContext(ctx)
+}
+
+// querier - общий интерфейс базы и транзакции database/sql
+type querier interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+// Create создает новую запись о подписке
+func (s *SubscriptionStorage) Create(ctx context.Context, sub *models.Subscription) error {
+	return insertSubscription(ctx, s.db, sub)
+}
+
+func insertSubscription(ctx context.Context, q querier, sub *models.Subscription) error {
+	query := `
+		INSERT INTO subscriptions (id, service_name, price, currency, billing_period, user_id, start_date, end_date, created_at, updated_at)
+		VALUES (?1, ?2, ?3, ?4, ?5, ?6, ?7, ?8, ?9, ?9)
+		RETURNING version, created_at, updated_at
+	`
+
+	id := uuid.New()
+	err := q.QueryRowContext(ctx, query,
+		id,
+		sub.ServiceName,
+		sub.Price,
+		sub.Currency,
+		sub.BillingPeriod,
+		sub.UserID,
+		formatDate(sub.StartDate),
+		formatNullDate(sub.EndDate),
+		formatTimestamp(time.Now()),
+	).Scan(&sub.Version, timeColumn{&sub.CreatedAt}, timeColumn{&sub.UpdatedAt})
+
+	if err != nil {
+		return apperrors.NewInternal(err)
+	}
+	sub.ID = id
+
+	return nil
+}
+
+// GetByID получает неархивную подписку по ID
+func (s *SubscriptionStorage) GetByID(ctx context.Context, id uuid.UUID) (*models.Subscription, error) {
+	query := fmt.Sprintf(`
+		SELECT %s
+		FROM subscriptions
+		WHERE id = ?1 AND deleted_at IS NULL
+	`, subscriptionColumns)
+
+	sub, err := scanSubscription(s.db.QueryRowContext(ctx, query, id))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, apperrors.NewNotFound(models.ErrSubscriptionNotFound.Error(), errors.Join(models.ErrSubscriptionNotFound, err))
+		}
+		return nil, apperrors.NewInternal(err)
+	}
+
+	return &sub, nil
+}
+
+// GetOwnerID возвращает user_id подписки, в том числе архивной
+func (s *SubscriptionStorage) GetOwnerID(ctx context.Context, id uuid.UUID) (uuid.UUID, error) {
+	var userID uuid.UUID
+	err := s.db.QueryRowContext(ctx, `SELECT user_id FROM subscriptions WHERE id = ?1`, id).Scan(&userID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return uuid.Nil, apperrors.NewNotFound(models.ErrSubscriptionNotFound.Error(), errors.Join(models.ErrSubscriptionNotFound, err))
+		}
+		return uuid.Nil, apperrors.NewInternal(err)
+	}
+
+	return userID, nil
+}
+
+// Update обновляет данные подписки, если её версия не изменилась с момента чтения
+// При успешном обновлении версия подписки увеличивается
+func (s *SubscriptionStorage) Update(ctx context.Context, sub *models.Subscription) error {
+	query := `
+		UPDATE subscriptions
+		SET service_name = ?1, price = ?2, currency = ?3, billing_period = ?4, start_date = ?5, end_date = ?6,
+		    version = version + 1, updated_at = ?7
+		WHERE id = ?8 AND version = ?9 AND deleted_at IS NULL
+		RETURNING version, updated_at
+	`
+
+	err := s.db.QueryRowContext(ctx, query,
+		sub.ServiceName,
+		sub.Price,
+		sub.Currency,
+		sub.BillingPeriod,
+		formatDate(sub.StartDate),
+		formatNullDate(sub.EndDate),
+		formatTimestamp(time.Now()),
+		sub.ID,
+		sub.Version,
+	).Scan(&sub.Version, timeColumn{&sub.UpdatedAt})
+
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return s.versionMismatchError(ctx, sub.ID, false)
+		}
+		return apperrors.NewInternal(err)
+	}
+
+	return nil
+}
+
+// Archive помечает подписку как архивную вместо удаления, чтобы сохранить историю для отчетов
+// Если expectedVersion не nil, подписка архивируется только при совпадении версии
+func (s *SubscriptionStorage) Archive(ctx context.Context, id uuid.UUID, expectedVersion *int) error {
+	return s.setArchived(ctx, id, true, expectedVersion)
+}
+
+// Restore возвращает архивную подписку в активное состояние
+// Если expectedVersion не nil, подписка восстанавливается только при совпадении версии
+func (s *SubscriptionStorage) Restore(ctx context.Context, id uuid.UUID, expectedVersion *int) (*models.Subscription, error) {
+	if err := s.setArchived(ctx, id, false, expectedVersion); err != nil {
+		return nil, err
+	}
+
+	return s.GetByID(ctx, id)
+}
+
+func (s *SubscriptionStorage) setArchived(ctx context.Context, id uuid.UUID, archive bool, expectedVersion *int) error {
+	query := `
+		UPDATE subscriptions
+		SET deleted_at = CASE WHEN ?2 THEN ?3 END, version = version + 1, updated_at = ?3
+		WHERE id = ?1 AND (deleted_at IS NULL) = ?2
+	`
+	args := []any{id, archive, formatTimestamp(time.Now())}
+
+	if expectedVersion != nil {
+		query += ` AND version = ?4`
+		args = append(args, *expectedVersion)
+	}
+
+	res, err := s.db.ExecContext(ctx, query, args...)
+	if err != nil {
+		return apperrors.NewInternal(err)
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return apperrors.NewInternal(err)
+	}
+
+	if affected == 0 {
+		return s.versionMismatchError(ctx, id, !archive)
+	}
+
+	return nil
+}
+
+// PurgeArchived окончательно удаляет подписки, архивированные раньше olderThan
+func (s *SubscriptionStorage) PurgeArchived(ctx context.Context, olderThan time.Time) (int64, error) {
+	query := `DELETE FROM subscriptions WHERE deleted_at IS NOT NULL AND deleted_at < ?1`
+
+	res, err := s.db.ExecContext(ctx, query, formatTimestamp(olderThan))
+	if err != nil {
+		return 0, apperrors.NewInternal(err)
+	}
+
+	deleted, err := res.RowsAffected()
+	if err != nil {
+		return 0, apperrors.NewInternal(err)
+	}
+
+	return deleted, nil
+}
+
+// versionMismatchError определяет, почему условная запись не затронула ни одной строки:
+// подписки в нужном состоянии (архивной или активной) нет или её версия изменилась
+func (s *SubscriptionStorage) versionMismatchError(ctx context.Context, id uuid.UUID, archived bool) error {
+	query := `SELECT EXISTS(SELECT 1 FROM subscriptions WHERE id = ?1 AND (deleted_at IS NOT NULL) = ?2)`
+
+	var exists bool
+	if err := s.db.QueryRowContext(ctx, query, id, archived).Scan(&exists); err != nil {
+		return apperrors.NewInternal(err)
+	}
+
+	if !exists {
+		if archived {
+			return apperrors.NewNotFound(models.ErrArchivedSubscriptionNotFound.Error(), models.ErrArchivedSubscriptionNotFound)
+		}
+		return apperrors.NewNotFound(models.ErrSubscriptionNotFound.Error(), models.ErrSubscriptionNotFound)
+	}
+
+	return apperrors.NewPreconditionFailed(models.ErrSubscriptionModified.Error(), models.ErrSubscriptionModified)
+}
+
+// sortColumns сопоставляет разрешенные поля сортировки с колонками таблицы
+var sortColumns = map[string]string{
+	"service_name": "service_name",
+	"price":        "price",
+	"user_id":      "user_id",
+	"start_date":   "start_date",
+	"end_date":     "end_date",
+	"created_at":   "created_at",
+	"updated_at":   "updated_at",
+}
+
+// List возвращает страницу подписок, удовлетворяющих фильтрам
+// Если задан курсор, возвращаются подписки, следующие за ним в порядке (created_at, id) по убыванию
+func (s *SubscriptionStorage) List(ctx context.Context, req models.ListSubscriptionsRequest) ([]models.Subscription, error) {
+	where, args := listConditions(req)
+
+	orderBy, err := listOrderBy(req.Sort)
+	if err != nil {
+		return nil, err
+	}
+
+	argNum := len(args) + 1
+	query := fmt.Sprintf(`
+		SELECT %s
+		FROM subscriptions
+		%s
+		ORDER BY %s
+		LIMIT ?%d OFFSET ?%d
+	`, subscriptionColumns, where, orderBy, argNum, argNum+1)
+
+	args = append(args, req.Limit, req.Offset)
+
+	var subscriptions []models.Subscription
+	err = s.query(ctx, query, args, func(sub *models.Subscription) error {
+		subscriptions = append(subscriptions, *sub)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return subscriptions, nil
+}
+
+// Export последовательно передает в fn все подписки, удовлетворяющие фильтрам, без пагинации
+// Строки читаются из открытого курсора по мере обработки, поэтому память не зависит от объема выборки
+func (s *SubscriptionStorage) Export(ctx context.Context, req models.ListSubscriptionsRequest, fn func(*models.Subscription) error) error {
+	req.Cursor = nil
+	where, args := listConditions(req)
+
+	orderBy, err := listOrderBy(req.Sort)
+	if err != nil {
+		return err
+	}
+
+	query := fmt.Sprintf(`SELECT %s FROM subscriptions %s ORDER BY %s`, subscriptionColumns, where, orderBy)
+
+	return s.query(ctx, query, args, fn)
+}
+
+// Count возвращает количество подписок, удовлетворяющих фильтрам, без учета курсора и пагинации
+func (s *SubscriptionStorage) Count(ctx context.Context, req models.ListSubscriptionsRequest) (int64, error) {
+	req.Cursor = nil
+	where, args := listConditions(req)
+
+	var total int64
+	err := s.db.QueryRowContext(ctx, fmt.Sprintf(`SELECT COUNT(*) FROM subscriptions %s`, where), args...).Scan(&total)
+	if err != nil {
+		return 0, apperrors.NewInternal(err)
+	}
+
+	return total, nil
+}
+
+// CountActiveByService возвращает количество неархивных подписок, действующих в месяце month,
+// по названиям сервисов
+func (s *SubscriptionStorage) CountActiveByService(ctx context.Context, month time.Time) (map[string]int64, error) {
+	query := `
+		SELECT service_name, COUNT(*)
+		FROM subscriptions
+		WHERE deleted_at IS NULL AND start_date <= ?1 AND (end_date IS NULL OR end_date >= ?1)
+		GROUP BY service_name
+	`
+
+	rows, err := s.db.QueryContext(ctx, query, formatDate(month))
+	if err != nil {
+		return nil, apperrors.NewInternal(err)
+	}
+	defer rows.Close()
+
+	counts := make(map[string]int64)
+
+	for rows.Next() {
+		var (
+			serviceName string
+			count       int64
+		)
+		if err := rows.Scan(&serviceName, &count); err != nil {
+			return nil, apperrors.NewInternal(err)
+		}
+		counts[serviceName] = count
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, apperrors.NewInternal(err)
+	}
+
+	return counts, nil
+}
+
+// query выполняет запрос, выбирающий subscriptionColumns, и передает каждую строку в fn
+func (s *SubscriptionStorage) query(ctx context.Context, query string, args []any, fn func(*models.Subscription) error) error {
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return apperrors.NewInternal(err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		sub, err := scanSubscription(rows)
+		if err != nil {
+			return apperrors.NewInternal(err)
+		}
+		if err := fn(&sub); err != nil {
+			return err
+		}
+	}
+
+	if err := rows.Err(); err != nil {
+		return apperrors.NewInternal(err)
+	}
+
+	return nil
+}
+
+// subscriptionColumns - колонки, которые читает scanSubscription
+const subscriptionColumns = `id, service_name, price, currency, billing_period, user_id, start_date, end_date, version, created_at, updated_at, deleted_at`
+
+// scanner - общий интерфейс sql.Row и sql.Rows
+type scanner interface {
+	Scan(dest ...any) error
+}
+
+func scanSubscription(row scanner, extra ...any) (models.Subscription, error) {
+	var sub models.Subscription
+	dest := append(extra,
+		&sub.ID,
+		&sub.ServiceName,
+		&sub.Price,
+		&sub.Currency,
+		&sub.BillingPeriod,
+		&sub.UserID,
+		timeColumn{&sub.StartDate},
+		nullTimeColumn{&sub.EndDate},
+		&sub.Version,
+		timeColumn{&sub.CreatedAt},
+		timeColumn{&sub.UpdatedAt},
+		nullTimeColumn{&sub.DeletedAt},
+	)
+	err := row.Scan(dest...)
+	return sub, err
+}
+
+// listConditions строит условие WHERE по фильтрам списка подписок
+// Значения фильтров передаются только через параметры запроса
+func listConditions(req models.ListSubscriptionsRequest) (string, []any) {
+	var (
+		conditions []string
+		args       []any
+	)
+
+	add := func(cond string, arg any) {
+		args = append(args, arg)
+		conditions = append(conditions, fmt.Sprintf(cond, len(args)))
+	}
+
+	if !req.IncludeDeleted {
+		conditions = append(conditions, "deleted_at IS NULL")
+	}
+	if req.UserID != nil {
+		add("user_id = ?%d", *req.UserID)
+	}
+	if req.ServiceName != "" {
+		add("service_name = ?%d", req.ServiceName)
+	}
+	if req.ServiceNamePrefix != "" {
+		// LIKE в SQLite не учитывает регистр, поэтому префикс сравнивается напрямую
+		add("substr(service_name, 1, length(?%[1]d)) = ?%[1]d", req.ServiceNamePrefix)
+	}
+	if req.PriceMin != nil {
+		add("price >= ?%d", *req.PriceMin)
+	}
+	if req.PriceMax != nil {
+		add("price <= ?%d", *req.PriceMax)
+	}
+	if req.ActiveAt != nil {
+		add("start_date <= ?%[1]d AND (end_date IS NULL OR end_date >= ?%[1]d)", formatDate(*req.ActiveAt))
+	}
+	if req.StartDateFrom != nil {
+		add("start_date >= ?%d", formatDate(*req.StartDateFrom))
+	}
+	if req.StartDateTo != nil {
+		add("start_date <= ?%d", formatDate(*req.StartDateTo))
+	}
+	if req.EndDateFrom != nil {
+		add("end_date >= ?%d", formatDate(*req.EndDateFrom))
+	}
+	if req.EndDateTo != nil {
+		add("end_date <= ?%d", formatDate(*req.EndDateTo))
+	}
+	if req.OpenEnded != nil {
+		if *req.OpenEnded {
+			conditions = append(conditions, "end_date IS NULL")
+		} else {
+			conditions = append(conditions, "end_date IS NOT NULL")
+		}
+	}
+	if req.Cursor != nil {
+		args = append(args, formatTimestamp(req.Cursor.CreatedAt), req.Cursor.ID)
+		conditions = append(conditions, fmt.Sprintf("(created_at, id) < (?%d, ?%d)", len(args)-1, len(args)))
+	}
+
+	if len(conditions) == 0 {
+		return "", args
+	}
+
+	return "WHERE " + strings.Join(conditions, " AND "), args
+}
+
+// listOrderBy строит ORDER BY по полям сортировки из белого списка
+// Без сортировки подписки возвращаются от новых к старым; id обеспечивает стабильный порядок.
+// NULL упорядочиваются как в Postgres: последними по возрастанию и первыми по убыванию
+func listOrderBy(sort []models.SortField) (string, error) {
+	if len(sort) == 0 {
+		return "created_at DESC, id DESC", nil
+	}
+
+	parts := make([]string, 0, len(sort)+1)
+	for _, f := range sort {
+		column, ok := sortColumns[f.Field]
+		if !ok {
+			return "", apperrors.NewBadRequest(models.ErrInvalidSort.Error(), models.ErrInvalidSort)
+		}
+		if f.Desc {
+			column += " DESC NULLS FIRST"
+		} else {
+			column += " NULLS LAST"
+		}
+		parts = append(parts, column)
+	}
+	parts = append(parts, "id")
+
+	return strings.Join(parts, ", "), nil
+}
@@ -0,0 +1,84 @@
+package sqlite
+
+import (
+	"fmt"
+	"time"
+)
+
+// SQLite не имеет типов даты и времени, поэтому значения хранятся в колонках TEXT
+// в формате фиксированной ширины: строковое сравнение совпадает с хронологическим,
+// а встроенные функции date() и strftime() понимают оба формата
+const (
+	dateLayout      = "2006-01-02"
+	timestampLayout = "2006-01-02 15:04:05.000000"
+)
+
+// formatDate приводит дату к формату колонок start_date и end_date
+func formatDate(t time.Time) string {
+	return t.Format(dateLayout)
+}
+
+func formatNullDate(t *time.Time) any {
+	if t == nil {
+		return nil
+	}
+	return formatDate(*t)
+}
+
+// formatTimestamp приводит момент времени к UTC с точностью Postgres timestamptz
+func formatTimestamp(t time.Time) string {
+	return t.UTC().Format(timestampLayout)
+}
+
+func parseTime(s string) (time.Time, error) {
+	layout := timestampLayout
+	if len(s) == len(dateLayout) {
+		layout = dateLayout
+	}
+	return time.Parse(layout, s)
+}
+
+// timeColumn читает дату или момент времени из колонки TEXT
+type timeColumn struct {
+	dst *time.Time
+}
+
+func (c timeColumn) Scan(src any) error {
+	var s string
+	switch v := src.(type) {
+	case string:
+		s = v
+	case []byte:
+		s = string(v)
+	default:
+		return fmt.Errorf("cannot scan %T into time", src)
+	}
+
+	t, err := parseTime(s)
+	if err != nil {
+		return err
+	}
+	*c.dst = t
+
+	return nil
+}
+
+// nullTimeColumn читает дату или момент времени из колонки TEXT, допускающей NULL
+type nullTimeColumn struct {
+	dst **time.Time
+}
+
+func (c nullTimeColumn) Scan(src any) error {
+	if src == nil {
+		*c.dst = nil
+		return nil
+	}
+
+	var t time.Time
+	if err := (timeColumn{&t}).Scan(src); err != nil {
+		return err
+	}
+	*c.dst = &t
+
+	return nil
+}
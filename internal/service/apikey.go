@@ -0,0 +1,137 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/Gilf4/effective-mobile-task/internal/auth"
+	apperrors "github.com/Gilf4/effective-mobile-task/internal/errors"
+	"github.com/Gilf4/effective-mobile-task/internal/models"
+	"github.com/google/uuid"
+)
+
+const (
+	// apiKeyPrefix отличает API-ключи от других секретов, например в логах и сканерах утечек
+	apiKeyPrefix = "sk_"
+	apiKeyBytes  = 32
+)
+
+type APIKeyRepository interface {
+	CreateAPIKey(ctx context.Context, key *models.APIKey) error
+	GetAPIKeyByHash(ctx context.Context, hash string) (*models.APIKey, error)
+	TouchAPIKey(ctx context.Context, id uuid.UUID, usedAt time.Time) error
+	RevokeAPIKey(ctx context.Context, id uuid.UUID) error
+	ListAPIKeys(ctx context.Context) ([]models.APIKey, error)
+}
+
+type APIKeyService struct {
+	repo APIKeyRepository
+}
+
+func NewAPIKeyService(repo APIKeyRepository) *APIKeyService {
+	return &APIKeyService{repo: repo}
+}
+
+// IssueAPIKey выпускает API-ключ и возвращает его вместе с самим ключом
+// Ключ не хранится и больше не может быть получен
+func (s *APIKeyService) IssueAPIKey(ctx context.Context, req models.IssueAPIKeyRequest) (*models.APIKey, string, error) {
+	if err := validateIssueAPIKey(req, time.Now()); err != nil {
+		return nil, "", err
+	}
+
+	buf := make([]byte, apiKeyBytes)
+	if _, err := rand.Read(buf); err != nil {
+		return nil, "", apperrors.NewInternal(err)
+	}
+	raw := apiKeyPrefix + base64.RawURLEncoding.EncodeToString(buf)
+
+	key := &models.APIKey{
+		Name:      strings.TrimSpace(req.Name),
+		KeyHash:   hashAPIKey(raw),
+		Scopes:    slices.Compact(slices.Sorted(slices.Values(req.Scopes))),
+		ExpiresAt: req.ExpiresAt,
+	}
+	if err := s.repo.CreateAPIKey(ctx, key); err != nil {
+		return nil, "", err
+	}
+
+	return key, raw, nil
+}
+
+// RevokeAPIKey отзывает API-ключ. Отозванный ключ перестает приниматься сразу
+func (s *APIKeyService) RevokeAPIKey(ctx context.Context, id uuid.UUID) error {
+	return s.repo.RevokeAPIKey(ctx, id)
+}
+
+// ListAPIKeys возвращает все выпущенные API-ключи, включая отозванные
+func (s *APIKeyService) ListAPIKeys(ctx context.Context) ([]models.APIKey, error) {
+	return s.repo.ListAPIKeys(ctx)
+}
+
+// AuthenticateAPIKey проверяет API-ключ, запоминает время его использования и возвращает автора запроса
+// Ключ принадлежит сервисному клиенту, а не пользователю, поэтому автор запроса видит подписки
+// всех пользователей, а доступные маршруты ограничены областями доступа ключа
+func (s *APIKeyService) AuthenticateAPIKey(ctx context.Context, raw string) (auth.Principal, error) {
+	key, err := s.repo.GetAPIKeyByHash(ctx, hashAPIKey(raw))
+	if err != nil {
+		if errors.Is(err, models.ErrAPIKeyNotFound) {
+			return auth.Principal{}, auth.ErrInvalidAPIKey
+		}
+		return auth.Principal{}, err
+	}
+
+	now := time.Now()
+	if !key.Active(now) {
+		return auth.Principal{}, auth.ErrInvalidAPIKey
+	}
+
+	if err := s.repo.TouchAPIKey(ctx, key.ID, now); err != nil {
+		return auth.Principal{}, err
+	}
+
+	scopes := make([]auth.Scope, 0, len(key.Scopes))
+	for _, scope := range key.Scopes {
+		scopes = append(scopes, auth.Scope(scope))
+	}
+
+	return auth.Principal{
+		Subject: "apikey:" + key.ID.String(),
+		Admin:   true,
+		Scopes:  scopes,
+	}, nil
+}
+
+// validateIssueAPIKey проверяет параметры выпуска ключа и возвращает все нарушения сразу
+func validateIssueAPIKey(req models.IssueAPIKeyRequest, now time.Time) error {
+	var v apperrors.Validation
+
+	if strings.TrimSpace(req.Name) == "" {
+		v.Add("name", models.ErrAPIKeyNameRequired)
+	}
+	if len(req.Scopes) == 0 {
+		v.Add("scopes", models.ErrScopesRequired)
+	}
+	for _, scope := range req.Scopes {
+		if !auth.Scope(scope).Valid() {
+			v.Add("scopes", models.ErrInvalidScope)
+			break
+		}
+	}
+	if req.ExpiresAt != nil && !req.ExpiresAt.After(now) {
+		v.Add("expires_at", models.ErrAPIKeyExpiresAt)
+	}
+
+	return v.Err()
+}
+
+func hashAPIKey(raw string) string {
+	sum := sha256.Sum256([]byte(raw))
+	return hex.EncodeToString(sum[:])
+}
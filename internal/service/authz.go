@@ -0,0 +1,77 @@
+package service
+
+import (
+	"context"
+
+	"github.com/Gilf4/effective-mobile-task/internal/auth"
+	apperrors "github.com/Gilf4/effective-mobile-task/internal/errors"
+	"github.com/Gilf4/effective-mobile-task/internal/models"
+	"github.com/google/uuid"
+)
+
+// restrictedUser возвращает user_id автора запроса, если его доступ ограничен собственными подписками
+// Администраторы и запросы без аутентификации (например, при отключенной аутентификации) не ограничены
+func restrictedUser(ctx context.Context) (uuid.UUID, bool) {
+	p, ok := auth.FromContext(ctx)
+	if !ok || p.Admin {
+		return uuid.Nil, false
+	}
+	return p.UserID, true
+}
+
+// authorizeUserID подставляет user_id автора запроса, если он не задан,
+// и запрещает создавать подписки других пользователей
+func authorizeUserID(ctx context.Context, userID *uuid.UUID) error {
+	own, restricted := restrictedUser(ctx)
+	if !restricted {
+		return nil
+	}
+
+	if *userID == uuid.Nil {
+		*userID = own
+	}
+	if *userID != own {
+		return apperrors.NewForbidden(models.ErrForeignUserID.Error(), models.ErrForeignUserID)
+	}
+
+	return nil
+}
+
+// scopeUserID ограничивает фильтр по пользователю подписками автора запроса
+// Без фильтра возвращается user_id автора, фильтр по другому пользователю запрещен
+func scopeUserID(ctx context.Context, userID *uuid.UUID) (*uuid.UUID, error) {
+	own, restricted := restrictedUser(ctx)
+	if !restricted {
+		return userID, nil
+	}
+
+	if userID != nil && *userID != own {
+		return nil, apperrors.NewForbidden(models.ErrForeignUserID.Error(), models.ErrForeignUserID)
+	}
+
+	return &own, nil
+}
+
+// authorizeOwner проверяет, что подписка ownerID принадлежит автору запроса
+// Чужие подписки не раскрываются: для них возвращается та же ошибка, что и для несуществующих
+func authorizeOwner(ctx context.Context, ownerID uuid.UUID) error {
+	own, restricted := restrictedUser(ctx)
+	if restricted && ownerID != own {
+		return apperrors.NewNotFound(models.ErrSubscriptionNotFound.Error(), models.ErrSubscriptionNotFound)
+	}
+	return nil
+}
+
+// authorizeSubscription проверяет владельца подписки id, в том числе архивной
+func (s *SubscriptionService) authorizeSubscription(ctx context.Context, id uuid.UUID) error {
+	if _, restricted := restrictedUser(ctx); !restricted {
+		return nil
+	}
+
+	ownerID, err := s.repo.GetOwnerID(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	return authorizeOwner(ctx, ownerID)
+}
@@ -0,0 +1,162 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	apperrors "github.com/Gilf4/effective-mobile-task/internal/errors"
+	"github.com/Gilf4/effective-mobile-task/internal/models"
+	"github.com/google/uuid"
+)
+
+// CreateSubscriptionsBatch создает подписки одной транзакцией
+// Ошибки валидации и записи возвращаются по каждому элементу с его индексом
+func (s *SubscriptionService) CreateSubscriptionsBatch(ctx context.Context, reqs []models.CreateSubscriptionRequest, mode models.BatchMode) (*models.BatchResponse, error) {
+	if err := validateBatch(len(reqs), mode); err != nil {
+		return nil, err
+	}
+
+	subs := make([]*models.Subscription, len(reqs))
+	itemErrs := make([]error, len(reqs))
+	invalid := false
+
+	for i, req := range reqs {
+		if err := authorizeUserID(ctx, &req.UserID); err != nil {
+			itemErrs[i] = err
+			invalid = true
+			continue
+		}
+		sub, err := newSubscription(req)
+		if err != nil {
+			itemErrs[i] = err
+			invalid = true
+			continue
+		}
+		subs[i] = sub
+	}
+
+	// в режиме atomic ошибки валидации отклоняют весь пакет без обращения к БД
+	if !(invalid && mode == models.BatchModeAtomic) {
+		dbErrs, err := s.repo.CreateBatch(ctx, subs, mode == models.BatchModeAtomic)
+		if err != nil {
+			return nil, err
+		}
+		for i, err := range dbErrs {
+			if err != nil {
+				itemErrs[i] = err
+			}
+		}
+	}
+
+	return batchResponse(mode, itemErrs, http.StatusCreated, func(i int, res *models.BatchItemResult) {
+		res.ID = &subs[i].ID
+		res.Subscription = models.NewSubscriptionResponse(subs[i])
+	}), nil
+}
+
+// ArchiveSubscriptionsBatch архивирует подписки по списку ID одной транзакцией
+// Чужие подписки обычного пользователя считаются ненайденными и не передаются в хранилище
+func (s *SubscriptionService) ArchiveSubscriptionsBatch(ctx context.Context, ids []uuid.UUID, mode models.BatchMode) (*models.BatchResponse, error) {
+	if err := validateBatch(len(ids), mode); err != nil {
+		return nil, err
+	}
+
+	itemErrs := make([]error, len(ids))
+	allowed := make([]int, 0, len(ids))
+	for i, id := range ids {
+		if err := s.authorizeSubscription(ctx, id); err != nil {
+			var appErr *apperrors.AppError
+			if !errors.As(err, &appErr) || appErr.Code != http.StatusNotFound {
+				return nil, err
+			}
+			itemErrs[i] = err
+			continue
+		}
+		allowed = append(allowed, i)
+	}
+
+	// в режиме atomic запрещенный элемент отклоняет весь пакет без обращения к БД
+	if len(allowed) > 0 && !(len(allowed) < len(ids) && mode == models.BatchModeAtomic) {
+		allowedIDs := make([]uuid.UUID, len(allowed))
+		for j, i := range allowed {
+			allowedIDs[j] = ids[i]
+		}
+
+		dbErrs, err := s.repo.ArchiveBatch(ctx, allowedIDs, mode == models.BatchModeAtomic)
+		if err != nil {
+			return nil, err
+		}
+		for j, err := range dbErrs {
+			itemErrs[allowed[j]] = err
+		}
+	}
+
+	return batchResponse(mode, itemErrs, http.StatusOK, func(i int, res *models.BatchItemResult) {
+		res.ID = &ids[i]
+	}), nil
+}
+
+func validateBatch(n int, mode models.BatchMode) error {
+	if err := mode.Validate(); err != nil {
+		return apperrors.NewBadRequest(err.Error(), err)
+	}
+	if n == 0 {
+		return apperrors.NewBadRequest(models.ErrEmptyBatch.Error(), models.ErrEmptyBatch)
+	}
+	if n > models.MaxBatchSize {
+		return apperrors.NewBadRequest(models.ErrBatchTooLarge.Error(), models.ErrBatchTooLarge)
+	}
+	return nil
+}
+
+// batchResponse собирает результаты пакетной операции по ошибкам элементов
+// Если в режиме atomic хотя бы один элемент завершился ошибкой, остальные помечаются как не примененные
+func batchResponse(mode models.BatchMode, itemErrs []error, okStatus int, fill func(i int, res *models.BatchItemResult)) *models.BatchResponse {
+	rolledBack := false
+	if mode == models.BatchModeAtomic {
+		for _, err := range itemErrs {
+			if err != nil {
+				rolledBack = true
+				break
+			}
+		}
+	}
+
+	response := &models.BatchResponse{
+		Mode:    mode,
+		Results: make([]models.BatchItemResult, len(itemErrs)),
+	}
+
+	for i, err := range itemErrs {
+		res := models.BatchItemResult{Index: i}
+
+		switch {
+		case err != nil:
+			var appErr *apperrors.AppError
+			if errors.As(err, &appErr) {
+				res.Status = appErr.Code
+				res.Error = appErr.Message
+				res.Code = appErr.ErrorCode
+			} else {
+				res.Status = http.StatusInternalServerError
+				res.Error = "Internal server error"
+				res.Code = apperrors.CodeInternal
+			}
+			response.Failed++
+		case rolledBack:
+			res.Status = http.StatusFailedDependency
+			res.Error = models.ErrBatchRolledBack.Error()
+			res.Code = models.ErrBatchRolledBack.Code
+			response.Failed++
+		default:
+			res.Status = okStatus
+			fill(i, &res)
+			response.Succeeded++
+		}
+
+		response.Results[i] = res
+	}
+
+	return response
+}
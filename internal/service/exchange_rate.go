@@ -0,0 +1,196 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	apperrors "github.com/Gilf4/effective-mobile-task/internal/errors"
+	"github.com/Gilf4/effective-mobile-task/internal/models"
+)
+
+var exchangeRateColumns = []string{"base_currency", "quote_currency", "month", "rate"}
+
+type ExchangeRateRepository interface {
+	UpsertExchangeRates(ctx context.Context, rates []models.ExchangeRate) error
+	ListExchangeRates(ctx context.Context, base, quote string) ([]models.ExchangeRate, error)
+	DeleteExchangeRate(ctx context.Context, base, quote string, month time.Time) error
+	GetExchangeRate(ctx context.Context, base, quote string, month time.Time) (*models.ExchangeRate, error)
+}
+
+// ListExchangeRates возвращает курсы, при необходимости отфильтрованные по базовой и котируемой валюте
+func (s *SubscriptionService) ListExchangeRates(ctx context.Context, base, quote string) ([]models.ExchangeRateResponse, error) {
+	var v apperrors.Validation
+	if base != "" {
+		if err := models.ValidateCurrency(base); err != nil {
+			v.Add("base_currency", err)
+		}
+	}
+	if quote != "" {
+		if err := models.ValidateCurrency(quote); err != nil {
+			v.Add("quote_currency", err)
+		}
+	}
+	if err := v.Err(); err != nil {
+		return nil, err
+	}
+
+	rates, err := s.rates.ListExchangeRates(ctx, base, quote)
+	if err != nil {
+		return nil, err
+	}
+
+	return exchangeRateResponses(rates), nil
+}
+
+// UpsertExchangeRates сохраняет курсы: курс пары на тот же месяц заменяется
+// Все курсы проверяются до записи и сохраняются одной транзакцией
+func (s *SubscriptionService) UpsertExchangeRates(ctx context.Context, reqs []models.ExchangeRateRequest) ([]models.ExchangeRateResponse, error) {
+	if err := models.ValidateExchangeRates(reqs); err != nil {
+		return nil, err
+	}
+
+	rates := make([]models.ExchangeRate, len(reqs))
+	for i, req := range reqs {
+		rate, err := req.ExchangeRate()
+		if err != nil {
+			return nil, err
+		}
+		rates[i] = rate
+	}
+
+	if err := s.rates.UpsertExchangeRates(ctx, rates); err != nil {
+		return nil, err
+	}
+
+	return exchangeRateResponses(rates), nil
+}
+
+// DeleteExchangeRate удаляет курс пары на месяц (MM-YYYY)
+func (s *SubscriptionService) DeleteExchangeRate(ctx context.Context, base, quote, monthStr string) error {
+	var v apperrors.Validation
+	if err := models.ValidateCurrency(base); err != nil {
+		v.Add("base_currency", err)
+	}
+	if err := models.ValidateCurrency(quote); err != nil {
+		v.Add("quote_currency", err)
+	}
+	month, err := parseDate(monthStr)
+	if err != nil {
+		v.Add("month", models.ErrInvalidDate)
+	}
+	if err := v.Err(); err != nil {
+		return err
+	}
+
+	return s.rates.DeleteExchangeRate(ctx, base, quote, month)
+}
+
+// ImportExchangeRatesCSV загружает курсы из CSV с колонками base_currency, quote_currency,
+// month (MM-YYYY) и rate. Прочие колонки игнорируются. Все строки проверяются до записи:
+// при dryRun или наличии ошибок ничего не сохраняется, иначе курсы сохраняются одной транзакцией
+func (s *SubscriptionService) ImportExchangeRatesCSV(ctx context.Context, r io.Reader, dryRun bool) (*models.ImportReport, error) {
+	var rates []models.ExchangeRate
+
+	report, err := readImportCSV(r, dryRun, exchangeRateColumns, nil, func(field func(name string) string) error {
+		rate, err := models.ExchangeRateRequest{
+			BaseCurrency:  field("base_currency"),
+			QuoteCurrency: field("quote_currency"),
+			Month:         field("month"),
+			Rate:          field("rate"),
+		}.ExchangeRate()
+		if err != nil {
+			return err
+		}
+		rates = append(rates, rate)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if dryRun || len(report.Errors) > 0 || len(rates) == 0 {
+		return report, nil
+	}
+
+	if err := s.rates.UpsertExchangeRates(ctx, rates); err != nil {
+		return nil, err
+	}
+	report.Imported = len(rates)
+
+	return report, nil
+}
+
+func exchangeRateResponses(rates []models.ExchangeRate) []models.ExchangeRateResponse {
+	responses := make([]models.ExchangeRateResponse, len(rates))
+	for i := range rates {
+		responses[i] = *models.NewExchangeRateResponse(&rates[i])
+	}
+	return responses
+}
+
+// currencyConverter пересчитывает суммы в валюту отчета по курсам месяцев списания
+// Найденные курсы запоминаются на время одного расчета
+type currencyConverter struct {
+	rates  ExchangeRateRepository
+	target string
+	cache  map[conversionKey]*models.ExchangeRate
+}
+
+type conversionKey struct {
+	from  string
+	month time.Time
+}
+
+func newCurrencyConverter(rates ExchangeRateRepository, target string) *currencyConverter {
+	return &currencyConverter{
+		rates:  rates,
+		target: target,
+		cache:  make(map[conversionKey]*models.ExchangeRate),
+	}
+}
+
+// convert пересчитывает amount в минорных единицах валюты from, списанный в месяце month
+func (c *currencyConverter) convert(ctx context.Context, amount int, from string, month time.Time) (int, error) {
+	if from == c.target || amount == 0 {
+		return amount, nil
+	}
+
+	key := conversionKey{from: from, month: month}
+	rate, ok := c.cache[key]
+	if !ok {
+		var err error
+		rate, err = c.lookup(ctx, from, month)
+		if err != nil {
+			return 0, err
+		}
+		c.cache[key] = rate
+	}
+
+	return rate.Convert(amount, from), nil
+}
+
+// lookup находит курс, действующий в месяце month, для пары from/target в любом направлении:
+// из прямого и обратного курсов выбирается установленный позже, при равенстве - прямой
+func (c *currencyConverter) lookup(ctx context.Context, from string, month time.Time) (*models.ExchangeRate, error) {
+	direct, err := c.rates.GetExchangeRate(ctx, from, c.target, month)
+	if err != nil && !errors.Is(err, models.ErrExchangeRateNotFound) {
+		return nil, err
+	}
+	inverse, err := c.rates.GetExchangeRate(ctx, c.target, from, month)
+	if err != nil && !errors.Is(err, models.ErrExchangeRateNotFound) {
+		return nil, err
+	}
+
+	switch {
+	case direct != nil && (inverse == nil || !inverse.Month.After(direct.Month)):
+		return direct, nil
+	case inverse != nil:
+		return inverse, nil
+	}
+
+	message := fmt.Sprintf("no exchange rate between %s and %s for %s", from, c.target, month.Format(models.DateLayout))
+	return nil, apperrors.NewBadRequest(message, models.ErrExchangeRateNotFound)
+}
@@ -0,0 +1,112 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	apperrors "github.com/Gilf4/effective-mobile-task/internal/errors"
+	"github.com/Gilf4/effective-mobile-task/internal/models"
+	"github.com/google/uuid"
+)
+
+const maxIdempotencyKeyLength = 255
+
+type IdempotencyRepository interface {
+	ReserveIdempotencyKey(ctx context.Context, rec *models.IdempotencyRecord) (*models.IdempotencyRecord, bool, error)
+	// CreateIdempotent в одной транзакции создает подписку и сохраняет ответ response(sub)
+	// в ключ, зарезервированный с токеном rec.LockToken
+	CreateIdempotent(ctx context.Context, sub *models.Subscription, rec *models.IdempotencyRecord, statusCode int, response func(*models.Subscription) ([]byte, error)) error
+	ReleaseIdempotencyKey(ctx context.Context, key, lockToken string) error
+	DeleteExpiredIdempotencyKeys(ctx context.Context, now time.Time) (int64, error)
+}
+
+// CreateSubscriptionIdempotent создает подписку не более одного раза для каждого ключа идемпотентности
+// Повторный запрос с тем же ключом и телом возвращает сохраненный ответ и replayed = true.
+// Подписка и ответ сохраняются одной транзакцией, а ключ резервируется на время аренды:
+// если процесс упадет, не завершив запрос, повторный запрос займет ключ после ее истечения
+func (s *SubscriptionService) CreateSubscriptionIdempotent(ctx context.Context, key string, req models.CreateSubscriptionRequest) (*models.SubscriptionResponse, bool, error) {
+	if len(key) > maxIdempotencyKeyLength {
+		return nil, false, apperrors.NewBadRequest("Idempotency-Key is too long", nil)
+	}
+
+	// проверка до резервирования ключа, чтобы чужой запрос не получил сохраненный ответ
+	if err := authorizeUserID(ctx, &req.UserID); err != nil {
+		return nil, false, err
+	}
+
+	hash, err := hashRequest(req)
+	if err != nil {
+		return nil, false, apperrors.NewInternal(err)
+	}
+
+	now := time.Now()
+	rec, reserved, err := s.idempotency.ReserveIdempotencyKey(ctx, &models.IdempotencyRecord{
+		Key:         key,
+		RequestHash: hash,
+		LockToken:   uuid.NewString(),
+		LockedUntil: now.Add(s.idempotencyLease),
+		ExpiresAt:   now.Add(s.idempotencyTTL),
+	})
+	if err != nil {
+		return nil, false, err
+	}
+
+	if !reserved {
+		return replayIdempotent(rec, hash)
+	}
+
+	sub, err := newSubscription(req)
+	if err == nil {
+		err = s.idempotency.CreateIdempotent(ctx, sub, rec, http.StatusCreated, func(sub *models.Subscription) ([]byte, error) {
+			return json.Marshal(models.NewSubscriptionResponse(sub))
+		})
+	}
+	if err != nil {
+		// подписка не создана, поэтому ключ освобождается для повтора, в том числе
+		// после отмены запроса клиентом, иначе он останется занятым до истечения аренды
+		if releaseErr := s.idempotency.ReleaseIdempotencyKey(context.WithoutCancel(ctx), key, rec.LockToken); releaseErr != nil {
+			return nil, false, errors.Join(err, releaseErr)
+		}
+		return nil, false, err
+	}
+
+	return models.NewSubscriptionResponse(sub), false, nil
+}
+
+// CleanupExpiredIdempotencyKeys удаляет истекшие ключи идемпотентности
+func (s *SubscriptionService) CleanupExpiredIdempotencyKeys(ctx context.Context) (int64, error) {
+	return s.idempotency.DeleteExpiredIdempotencyKeys(ctx, time.Now())
+}
+
+func replayIdempotent(rec *models.IdempotencyRecord, hash string) (*models.SubscriptionResponse, bool, error) {
+	if rec.RequestHash != hash {
+		return nil, false, apperrors.NewConflict(models.ErrIdempotencyKeyReused.Error(), models.ErrIdempotencyKeyReused)
+	}
+
+	if rec.StatusCode == nil {
+		return nil, false, apperrors.NewConflict(models.ErrIdempotencyKeyInProgress.Error(), models.ErrIdempotencyKeyInProgress)
+	}
+
+	var sub models.SubscriptionResponse
+	if err := json.NewDecoder(bytes.NewReader(rec.Response)).Decode(&sub); err != nil {
+		return nil, false, apperrors.NewInternal(err)
+	}
+
+	return &sub, true, nil
+}
+
+func hashRequest(req models.CreateSubscriptionRequest) (string, error) {
+	body, err := json.Marshal(req)
+	if err != nil {
+		return "", err
+	}
+
+	sum := sha256.Sum256(body)
+	return hex.EncodeToString(sum[:]), nil
+}
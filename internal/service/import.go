@@ -0,0 +1,195 @@
+package service
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+
+	apperrors "github.com/Gilf4/effective-mobile-task/internal/errors"
+	"github.com/Gilf4/effective-mobile-task/internal/models"
+	"github.com/google/uuid"
+)
+
+const (
+	codeInvalidCSV    = "invalid_csv"
+	codeInvalidFormat = "invalid_format"
+)
+
+var (
+	importRequiredColumns = []string{"service_name", "price", "user_id", "start_date"}
+	importOptionalColumns = []string{"end_date", "billing_period", "currency"}
+)
+
+// ImportSubscriptionsCSV загружает подписки из CSV с заголовком, колонки которого совпадают
+// с полями CreateSubscriptionRequest (даты в формате MM-YYYY). Прочие колонки игнорируются.
+// Все строки проверяются до записи: при dryRun или наличии ошибок ничего не сохраняется,
+// иначе строки загружаются одной транзакцией
+// Обычный пользователь может загружать только собственные подписки
+func (s *SubscriptionService) ImportSubscriptionsCSV(ctx context.Context, r io.Reader, dryRun bool) (*models.ImportReport, error) {
+	var subs []*models.Subscription
+
+	report, err := readImportCSV(r, dryRun, importRequiredColumns, importOptionalColumns, func(field func(name string) string) error {
+		sub, err := importRow(ctx, field)
+		if err != nil {
+			return err
+		}
+		subs = append(subs, sub)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if dryRun || len(report.Errors) > 0 || len(subs) == 0 {
+		return report, nil
+	}
+
+	imported, err := s.repo.Import(ctx, subs)
+	if err != nil {
+		return nil, err
+	}
+	report.Imported = int(imported)
+
+	return report, nil
+}
+
+// readImportCSV читает CSV с заголовком и передает каждую строку в row. Функция field возвращает
+// значение известной колонки строки или пустую строку. Ошибки разбора и ошибки row собираются в отчет по номерам строк
+func readImportCSV(r io.Reader, dryRun bool, required, optional []string, row func(field func(name string) string) error) (*models.ImportReport, error) {
+	reader := csv.NewReader(r)
+	reader.TrimLeadingSpace = true
+	reader.ReuseRecord = true
+
+	header, err := reader.Read()
+	if err != nil {
+		if errors.Is(err, io.EOF) {
+			return nil, apperrors.NewBadRequest("csv file is empty", err)
+		}
+		return nil, apperrors.NewBadRequest("invalid csv header", err)
+	}
+
+	columns, err := importColumns(header, required, optional)
+	if err != nil {
+		return nil, apperrors.NewBadRequest(err.Error(), err)
+	}
+
+	report := &models.ImportReport{DryRun: dryRun, Errors: []models.ImportLineError{}}
+
+	for {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+
+		report.TotalRows++
+
+		var parseErr *csv.ParseError
+		if errors.As(err, &parseErr) {
+			report.Errors = append(report.Errors, models.ImportLineError{Line: parseErr.Line, Error: parseErr.Err.Error(), Code: codeInvalidCSV})
+			continue
+		}
+		if err != nil {
+			return nil, apperrors.NewBadRequest("failed to read csv", err)
+		}
+
+		line, _ := reader.FieldPos(0)
+
+		field := func(name string) string {
+			i, ok := columns[name]
+			if !ok || i >= len(record) {
+				return ""
+			}
+			return strings.TrimSpace(record[i])
+		}
+
+		if err := row(field); err != nil {
+			report.Errors = append(report.Errors, lineError(line, err))
+			continue
+		}
+		report.ValidRows++
+	}
+
+	return report, nil
+}
+
+// importColumns сопоставляет известные колонки с их позицией в заголовке
+func importColumns(header, required, optional []string) (map[string]int, error) {
+	columns := make(map[string]int)
+	for i, name := range header {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if _, ok := columns[name]; ok {
+			return nil, fmt.Errorf("duplicate column %q", name)
+		}
+		columns[name] = i
+	}
+
+	for _, name := range required {
+		if _, ok := columns[name]; !ok {
+			return nil, fmt.Errorf("missing required column %q", name)
+		}
+	}
+
+	known := make(map[string]int)
+	for _, name := range append(slices.Clip(required), optional...) {
+		if i, ok := columns[name]; ok {
+			known[name] = i
+		}
+	}
+
+	return known, nil
+}
+
+func importRow(ctx context.Context, field func(name string) string) (*models.Subscription, error) {
+	req := models.CreateSubscriptionRequest{
+		ServiceName:   field("service_name"),
+		Currency:      field("currency"),
+		BillingPeriod: models.BillingPeriod(field("billing_period")),
+		StartDate:     field("start_date"),
+	}
+
+	if v := field("price"); v != "" {
+		price, err := strconv.Atoi(v)
+		if err != nil {
+			return nil, apperrors.Coded(codeInvalidFormat, "price must be an integer")
+		}
+		req.Price = price
+	}
+
+	if v := field("user_id"); v != "" {
+		userID, err := uuid.Parse(v)
+		if err != nil {
+			return nil, apperrors.Coded(codeInvalidFormat, "invalid user_id format")
+		}
+		req.UserID = userID
+	}
+
+	if v := field("end_date"); v != "" {
+		req.EndDate = &v
+	}
+
+	if err := authorizeUserID(ctx, &req.UserID); err != nil {
+		return nil, err
+	}
+
+	return newSubscription(req)
+}
+
+// lineError описывает ошибку строки CSV текстом и кодом, безопасными для ответа клиенту
+func lineError(line int, err error) models.ImportLineError {
+	var appErr *apperrors.AppError
+	if errors.As(err, &appErr) {
+		return models.ImportLineError{Line: line, Error: appErr.Message, Code: appErr.ErrorCode}
+	}
+
+	var coded *apperrors.CodedError
+	if errors.As(err, &coded) {
+		return models.ImportLineError{Line: line, Error: coded.Message, Code: coded.Code}
+	}
+
+	return models.ImportLineError{Line: line, Error: err.Error(), Code: apperrors.CodeBadRequest}
+}
@@ -1,8 +1,11 @@
 package service
 
 import (
+	"bytes"
 	"context"
 	"fmt"
+	"slices"
+	"strings"
 	"time"
 
 	apperrors "github.com/Gilf4/effective-mobile-task/internal/errors"
@@ -13,23 +16,63 @@ import (
 type SubscriptionRepository interface {
 	Create(ctx context.Context, sub *models.Subscription) error
 	GetByID(ctx context.Context, id uuid.UUID) (*models.Subscription, error)
+	GetOwnerID(ctx context.Context, id uuid.UUID) (uuid.UUID, error)
 	Update(ctx context.Context, sub *models.Subscription) error
-	Delete(ctx context.Context, id uuid.UUID) error
-	List(ctx context.Context, req models.ListSubscriptionsRequest) ([]models.Subscription, int64, error)
-	GetTotalCost(ctx context.Context, userID *uuid.UUID, serviceName string, startPeriod, endPeriod time.Time) (int, error)
+	Archive(ctx context.Context, id uuid.UUID, expectedVersion *int) error
+	Restore(ctx context.Context, id uuid.UUID, expectedVersion *int) (*models.Subscription, error)
+	PurgeArchived(ctx context.Context, olderThan time.Time) (int64, error)
+	CreateBatch(ctx context.Context, subs []*models.Subscription, atomic bool) ([]error, error)
+	ArchiveBatch(ctx context.Context, ids []uuid.UUID, atomic bool) ([]error, error)
+	Import(ctx context.Context, subs []*models.Subscription) (int64, error)
+	List(ctx context.Context, req models.ListSubscriptionsRequest) ([]models.Subscription, error)
+	Count(ctx context.Context, req models.ListSubscriptionsRequest) (int64, error)
+	Export(ctx context.Context, req models.ListSubscriptionsRequest, fn func(*models.Subscription) error) error
+	CountActiveByService(ctx context.Context, month time.Time) (map[string]int64, error)
+	GetMonthlySubscriptions(ctx context.Context, userID *uuid.UUID, serviceName string, startPeriod, endPeriod time.Time) ([]models.MonthlySubscription, error)
+	GetGroupedTotalCost(ctx context.Context, userID *uuid.UUID, serviceName string, startPeriod, endPeriod time.Time, mode models.TotalCostMode, groupBy []models.CostGroupBy) ([]models.CostGroup, error)
 }
 
 type SubscriptionService struct {
-	repo SubscriptionRepository
+	repo             SubscriptionRepository
+	idempotency      IdempotencyRepository
+	rates            ExchangeRateRepository
+	idempotencyTTL   time.Duration
+	idempotencyLease time.Duration
 }
 
-func NewSubscriptionService(repo SubscriptionRepository) *SubscriptionService {
-	return &SubscriptionService{repo: repo}
+func NewSubscriptionService(repo SubscriptionRepository, idempotency IdempotencyRepository, rates ExchangeRateRepository, idempotencyTTL, idempotencyLease time.Duration) *SubscriptionService {
+	return &SubscriptionService{
+		repo:             repo,
+		idempotency:      idempotency,
+		rates:            rates,
+		idempotencyTTL:   idempotencyTTL,
+		idempotencyLease: idempotencyLease,
+	}
 }
 
+// CreateSubscription создает подписку. Обычный пользователь создает подписки только для себя:
+// незаданный user_id заменяется его идентификатором
 func (s *SubscriptionService) CreateSubscription(ctx context.Context, req models.CreateSubscriptionRequest) (*models.SubscriptionResponse, error) {
+	if err := authorizeUserID(ctx, &req.UserID); err != nil {
+		return nil, err
+	}
+
+	sub, err := newSubscription(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.repo.Create(ctx, sub); err != nil {
+		return nil, err
+	}
+
+	return models.NewSubscriptionResponse(sub), nil
+}
+
+// newSubscription проверяет запрос на создание и строит по нему подписку
+func newSubscription(req models.CreateSubscriptionRequest) (*models.Subscription, error) {
 	if err := req.Validate(); err != nil {
-		return nil, apperrors.NewBadRequest(err.Error(), err)
+		return nil, err
 	}
 
 	startDate, err := parseDate(req.StartDate)
@@ -38,10 +81,18 @@ func (s *SubscriptionService) CreateSubscription(ctx context.Context, req models
 	}
 
 	sub := &models.Subscription{
-		ServiceName: req.ServiceName,
-		Price:       req.Price,
-		UserID:      req.UserID,
-		StartDate:   startDate,
+		ServiceName:   req.ServiceName,
+		Price:         req.Price,
+		Currency:      req.Currency,
+		BillingPeriod: req.BillingPeriod,
+		UserID:        req.UserID,
+		StartDate:     startDate,
+	}
+	if sub.BillingPeriod == "" {
+		sub.BillingPeriod = models.BillingPeriodMonth
+	}
+	if sub.Currency == "" {
+		sub.Currency = models.DefaultCurrency
 	}
 
 	if req.EndDate != nil {
@@ -49,17 +100,10 @@ func (s *SubscriptionService) CreateSubscription(ctx context.Context, req models
 		if err != nil {
 			return nil, apperrors.NewBadRequest("invalid end_date format", err)
 		}
-		if endDate.Before(startDate) {
-			return nil, apperrors.NewBadRequest("end_date must be greater than or equal to start_date", nil)
-		}
 		sub.EndDate = &endDate
 	}
 
-	if err := s.repo.Create(ctx, sub); err != nil {
-		return nil, err
-	}
-
-	return models.NewSubscriptionResponse(sub), nil
+	return sub, nil
 }
 
 func (s *SubscriptionService) GetSubscription(ctx context.Context, id uuid.UUID) (*models.SubscriptionResponse, error) {
@@ -67,19 +111,31 @@ func (s *SubscriptionService) GetSubscription(ctx context.Context, id uuid.UUID)
 	if err != nil {
 		return nil, err
 	}
+	if err := authorizeOwner(ctx, sub.UserID); err != nil {
+		return nil, err
+	}
 
 	return models.NewSubscriptionResponse(sub), nil
 }
 
-func (s *SubscriptionService) UpdateSubscription(ctx context.Context, id uuid.UUID, req models.UpdateSubscriptionRequest) (*models.SubscriptionResponse, error) {
+// UpdateSubscription обновляет подписку. Если expectedVersion не nil,
+// обновление выполняется только при совпадении текущей версии подписки
+func (s *SubscriptionService) UpdateSubscription(ctx context.Context, id uuid.UUID, req models.UpdateSubscriptionRequest, expectedVersion *int) (*models.SubscriptionResponse, error) {
 	if err := req.Validate(); err != nil {
-		return nil, apperrors.NewBadRequest(err.Error(), err)
+		return nil, err
 	}
 
 	sub, err := s.repo.GetByID(ctx, id)
 	if err != nil {
 		return nil, err
 	}
+	if err := authorizeOwner(ctx, sub.UserID); err != nil {
+		return nil, err
+	}
+
+	if expectedVersion != nil && *expectedVersion != sub.Version {
+		return nil, apperrors.NewPreconditionFailed(models.ErrSubscriptionModified.Error(), models.ErrSubscriptionModified)
+	}
 
 	if req.ServiceName != nil {
 		sub.ServiceName = *req.ServiceName
@@ -87,6 +143,12 @@ func (s *SubscriptionService) UpdateSubscription(ctx context.Context, id uuid.UU
 	if req.Price != nil {
 		sub.Price = *req.Price
 	}
+	if req.Currency != nil {
+		sub.Currency = *req.Currency
+	}
+	if req.BillingPeriod != nil {
+		sub.BillingPeriod = *req.BillingPeriod
+	}
 	if req.StartDate != nil {
 		date, err := parseDate(*req.StartDate)
 		if err != nil {
@@ -101,6 +163,17 @@ func (s *SubscriptionService) UpdateSubscription(ctx context.Context, id uuid.UU
 		}
 		sub.EndDate = &date
 	}
+	if sub.EndDate != nil {
+		var v apperrors.Validation
+		if sub.EndDate.Before(sub.StartDate) {
+			v.Add("end_date", models.ErrInvalidPeriod)
+		} else if err := sub.BillingPeriod.ValidateTerm(sub.StartDate, *sub.EndDate); err != nil {
+			v.Add("end_date", err)
+		}
+		if err := v.Err(); err != nil {
+			return nil, err
+		}
+	}
 
 	if err := s.repo.Update(ctx, sub); err != nil {
 		return nil, err
@@ -109,59 +182,433 @@ func (s *SubscriptionService) UpdateSubscription(ctx context.Context, id uuid.UU
 	return models.NewSubscriptionResponse(sub), nil
 }
 
-func (s *SubscriptionService) DeleteSubscription(ctx context.Context, id uuid.UUID) error {
-	return s.repo.Delete(ctx, id)
+// DeleteSubscription архивирует подписку. Архивная подписка не возвращается из GET и списка
+// по умолчанию, но продолжает учитываться в отчетах по месяц архивации включительно
+func (s *SubscriptionService) DeleteSubscription(ctx context.Context, id uuid.UUID, expectedVersion *int) error {
+	if err := s.authorizeSubscription(ctx, id); err != nil {
+		return err
+	}
+
+	return s.repo.Archive(ctx, id, expectedVersion)
+}
+
+func (s *SubscriptionService) RestoreSubscription(ctx context.Context, id uuid.UUID, expectedVersion *int) (*models.SubscriptionResponse, error) {
+	if err := s.authorizeSubscription(ctx, id); err != nil {
+		return nil, err
+	}
+
+	sub, err := s.repo.Restore(ctx, id, expectedVersion)
+	if err != nil {
+		return nil, err
+	}
+
+	return models.NewSubscriptionResponse(sub), nil
+}
+
+// PurgeArchived окончательно удаляет подписки, находящиеся в архиве дольше olderThanDays дней
+func (s *SubscriptionService) PurgeArchived(ctx context.Context, olderThanDays int) (*models.PurgeArchivedResponse, error) {
+	if olderThanDays < 0 {
+		return nil, apperrors.NewBadRequest("older_than_days cannot be negative", nil)
+	}
+
+	deleted, err := s.repo.PurgeArchived(ctx, time.Now().AddDate(0, 0, -olderThanDays))
+	if err != nil {
+		return nil, err
+	}
+
+	return &models.PurgeArchivedResponse{Deleted: deleted}, nil
 }
 
 func (s *SubscriptionService) ListSubscriptions(ctx context.Context, req models.ListSubscriptionsRequest) (*models.PaginatedSubscriptionResponse, error) {
+	req.SetDefaults()
+
 	if err := req.Validate(); err != nil {
-		return nil, apperrors.NewBadRequest(err.Error(), err)
+		return nil, err
 	}
 
-	req.SetDefaults()
+	userID, err := scopeUserID(ctx, req.UserID)
+	if err != nil {
+		return nil, err
+	}
+	req.UserID = userID
+
+	// запрашиваем на одну запись больше, чтобы узнать, есть ли следующая страница
+	page := req
+	page.Limit = req.Limit + 1
 
-	subscriptions, total, err := s.repo.List(ctx, req)
+	subscriptions, err := s.repo.List(ctx, page)
 	if err != nil {
 		return nil, fmt.Errorf("failed to list subscriptions: %w", err)
 	}
 
+	hasMore := len(subscriptions) > req.Limit
+	if hasMore {
+		subscriptions = subscriptions[:req.Limit]
+	}
+
 	responses := make([]models.SubscriptionResponse, len(subscriptions))
 	for i, sub := range subscriptions {
 		responses[i] = *models.NewSubscriptionResponse(&sub)
 	}
 
 	response := &models.PaginatedSubscriptionResponse{
-		Data:   responses,
-		Total:  total,
-		Limit:  req.Limit,
-		Offset: req.Offset,
+		Data:    responses,
+		Limit:   req.Limit,
+		Offset:  req.Offset,
+		HasMore: hasMore,
+	}
+
+	// курсор имеет смысл только для порядка по умолчанию (created_at, id)
+	if hasMore && len(req.Sort) == 0 {
+		last := subscriptions[len(subscriptions)-1]
+		response.NextCursor = models.ListCursor{CreatedAt: last.CreatedAt, ID: last.ID}.Encode()
+	}
+
+	if *req.IncludeTotal {
+		total, err := s.repo.Count(ctx, req)
+		if err != nil {
+			return nil, fmt.Errorf("failed to count subscriptions: %w", err)
+		}
+		response.Total = &total
+	}
+
+	return response, nil
+}
+
+// ExportSubscriptions передает в fn все подписки, удовлетворяющие фильтрам req
+// Параметры пагинации игнорируются
+func (s *SubscriptionService) ExportSubscriptions(ctx context.Context, req models.ListSubscriptionsRequest, fn func(*models.SubscriptionResponse) error) error {
+	if err := req.ValidateFilters(); err != nil {
+		return err
+	}
+
+	userID, err := scopeUserID(ctx, req.UserID)
+	if err != nil {
+		return err
+	}
+	req.UserID = userID
+
+	return s.repo.Export(ctx, req, func(sub *models.Subscription) error {
+		return fn(models.NewSubscriptionResponse(sub))
+	})
+}
+
+// ActiveSubscriptionsByService возвращает количество неархивных подписок, действующих
+// в текущем месяце, по названиям сервисов
+func (s *SubscriptionService) ActiveSubscriptionsByService(ctx context.Context) (map[string]int64, error) {
+	now := time.Now().UTC()
+	month := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+
+	return s.repo.CountActiveByService(ctx, month)
+}
+
+// CalculateTotal считает общую стоимость подписок за период в валюте currency
+// Без currency все подписки должны быть в одной валюте, иначе возвращается ошибка currency_required
+func (s *SubscriptionService) CalculateTotal(ctx context.Context, userID *uuid.UUID, serviceName string, startStr, endStr string, mode models.TotalCostMode, currency string) (*models.TotalCostResponse, error) {
+	if mode == "" {
+		mode = models.TotalCostModeProrated
+	}
+	if err := mode.Validate(); err != nil {
+		return nil, apperrors.NewBadRequest(err.Error(), err)
+	}
+
+	groups, currency, err := s.costGroups(ctx, userID, serviceName, startStr, endStr, mode, nil, currency)
+	if err != nil {
+		return nil, err
+	}
+
+	return &models.TotalCostResponse{TotalCost: groups[0].TotalCost, Currency: currency}, nil
+}
+
+// CalculateGroupedTotal считает стоимость подписок за период по группам в валюте currency
+func (s *SubscriptionService) CalculateGroupedTotal(ctx context.Context, userID *uuid.UUID, serviceName string, startStr, endStr string, mode models.TotalCostMode, groupBy []models.CostGroupBy, currency string) (*models.TotalCostResponse, error) {
+	if mode == "" {
+		mode = models.TotalCostModeProrated
+	}
+	if err := mode.Validate(); err != nil {
+		return nil, apperrors.NewBadRequest(err.Error(), err)
+	}
+	if len(groupBy) == 0 {
+		return nil, apperrors.NewBadRequest(models.ErrInvalidGroupBy.Error(), models.ErrInvalidGroupBy)
+	}
+	if mode == models.TotalCostModePerSubscription && slices.Contains(groupBy, models.CostGroupByMonth) {
+		return nil, apperrors.NewBadRequest("mode=per_subscription cannot be combined with group_by=month", nil)
+	}
+
+	groups, currency, err := s.costGroups(ctx, userID, serviceName, startStr, endStr, mode, groupBy, currency)
+	if err != nil {
+		return nil, err
 	}
 
-	response.CalculateHasMore()
+	response := &models.TotalCostResponse{
+		Currency: currency,
+		Groups:   make([]models.CostGroupResponse, len(groups)),
+	}
+	for i, g := range groups {
+		group := models.CostGroupResponse{
+			ServiceName:        g.ServiceName,
+			UserID:             g.UserID,
+			TotalCost:          g.TotalCost,
+			SubscriptionsCount: g.Count,
+		}
+		if g.Month != nil {
+			month := g.Month.Format(models.DateLayout)
+			group.Month = &month
+		}
+		response.Groups[i] = group
+		response.TotalCost += g.TotalCost
+	}
 
 	return response, nil
 }
 
-func (s *SubscriptionService) CalculateTotal(ctx context.Context, userID *uuid.UUID, serviceName string, startStr, endStr string) (int, error) {
-	start, err := parseDate(startStr)
+// costGroups считает стоимость подписок по группам groupBy и возвращает её вместе с валютой сумм
+// Если currency задана, суммы пересчитываются в неё, иначе все подписки должны быть в одной валюте
+func (s *SubscriptionService) costGroups(ctx context.Context, userID *uuid.UUID, serviceName string, startStr, endStr string, mode models.TotalCostMode, groupBy []models.CostGroupBy, currency string) ([]models.CostGroup, string, error) {
+	if currency != "" {
+		if err := models.ValidateCurrency(currency); err != nil {
+			return nil, "", apperrors.NewBadRequest(err.Error(), err)
+		}
+	}
+
+	userID, err := scopeUserID(ctx, userID)
+	if err != nil {
+		return nil, "", err
+	}
+
+	start, end, err := parsePeriod(startStr, endStr)
+	if err != nil {
+		return nil, "", err
+	}
+
+	var groups []models.CostGroup
+	if currency != "" {
+		groups, err = s.convertedCostGroups(ctx, userID, serviceName, start, end, mode, groupBy, currency)
+	} else {
+		groups, err = s.repo.GetGroupedTotalCost(ctx, userID, serviceName, start, end, mode, groupBy)
+	}
+	if err != nil {
+		return nil, "", err
+	}
+	if len(groups) == 0 {
+		return nil, "", apperrors.NewNotFound("no subscriptions found for the specified criteria", nil)
+	}
+
+	if currency == "" {
+		currency = groups[0].Currency
+		for _, g := range groups {
+			if g.Currency != currency {
+				return nil, "", apperrors.NewBadRequest(models.ErrCurrencyRequired.Error(), models.ErrCurrencyRequired)
+			}
+		}
+	}
+
+	return groups, currency, nil
+}
+
+// convertedCostGroups считает стоимость подписок по группам groupBy в валюте currency
+// Суммы каждой валюты за каждый месяц складываются и пересчитываются по курсу этого месяца;
+// в режиме per_subscription цена подписки пересчитывается по курсу первого месяца периода, в котором она активна
+func (s *SubscriptionService) convertedCostGroups(ctx context.Context, userID *uuid.UUID, serviceName string, start, end time.Time, mode models.TotalCostMode, groupBy []models.CostGroupBy, currency string) ([]models.CostGroup, error) {
+	items, err := s.repo.GetMonthlySubscriptions(ctx, userID, serviceName, start, end)
+	if err != nil {
+		return nil, err
+	}
+
+	type groupKey struct {
+		serviceName string
+		userID      uuid.UUID
+		month       time.Time
+	}
+	type amountKey struct {
+		group    groupKey
+		currency string
+		month    time.Time
+	}
+
+	var (
+		groups      = make(map[groupKey]*models.CostGroup)
+		seen        = make(map[groupKey]map[uuid.UUID]bool)
+		amounts     = make(map[amountKey]int)
+		amountOrder []amountKey
+	)
+
+	for _, item := range items {
+		var key groupKey
+		group := models.CostGroup{Currency: currency}
+		for _, g := range groupBy {
+			switch g {
+			case models.CostGroupByServiceName:
+				key.serviceName = item.ServiceName
+				group.ServiceName = &key.serviceName
+			case models.CostGroupByUserID:
+				key.userID = item.UserID
+				group.UserID = &key.userID
+			case models.CostGroupByMonth:
+				key.month = item.Month
+				group.Month = &key.month
+			}
+		}
+
+		existing, ok := groups[key]
+		if !ok {
+			existing = &group
+			groups[key] = existing
+			seen[key] = make(map[uuid.UUID]bool)
+		}
+
+		first := !seen[key][item.ID]
+		if first {
+			seen[key][item.ID] = true
+			existing.Count++
+		}
+
+		amount := item.Price * item.BillingPeriod.ChargesInMonth(item.StartDate, item.Month)
+		if mode == models.TotalCostModePerSubscription {
+			if !first {
+				continue
+			}
+			amount = item.Price
+		}
+
+		ak := amountKey{group: key, currency: item.Currency, month: item.Month}
+		if _, ok := amounts[ak]; !ok {
+			amountOrder = append(amountOrder, ak)
+		}
+		amounts[ak] += amount
+	}
+
+	converter := newCurrencyConverter(s.rates, currency)
+	for _, ak := range amountOrder {
+		converted, err := converter.convert(ctx, amounts[ak], ak.currency, ak.month)
+		if err != nil {
+			return nil, err
+		}
+		groups[ak.group].TotalCost += converted
+	}
+
+	result := make([]models.CostGroup, 0, len(groups))
+	for _, g := range groups {
+		result = append(result, *g)
+	}
+
+	slices.SortFunc(result, func(a, b models.CostGroup) int {
+		for _, g := range groupBy {
+			var c int
+			switch g {
+			case models.CostGroupByServiceName:
+				c = strings.Compare(*a.ServiceName, *b.ServiceName)
+			case models.CostGroupByUserID:
+				c = bytes.Compare(a.UserID[:], b.UserID[:])
+			case models.CostGroupByMonth:
+				c = a.Month.Compare(*b.Month)
+			}
+			if c != 0 {
+				return c
+			}
+		}
+		return 0
+	})
+
+	return result, nil
+}
+
+// CalculateBreakdown разбивает стоимость подписок за период по месяцам в валюте currency
+// Без currency все подписки должны быть в одной валюте, иначе возвращается ошибка currency_required
+func (s *SubscriptionService) CalculateBreakdown(ctx context.Context, userID *uuid.UUID, serviceName string, startStr, endStr string, currency string) (*models.CostBreakdownResponse, error) {
+	if currency != "" {
+		if err := models.ValidateCurrency(currency); err != nil {
+			return nil, apperrors.NewBadRequest(err.Error(), err)
+		}
+	}
+
+	userID, err := scopeUserID(ctx, userID)
 	if err != nil {
-		return 0, apperrors.NewBadRequest("invalid start_date format", err)
+		return nil, err
 	}
-	end, err := parseDate(endStr)
+
+	start, end, err := parsePeriod(startStr, endStr)
 	if err != nil {
-		return 0, apperrors.NewBadRequest("invalid end_date format", err)
+		return nil, err
 	}
 
-	total, err := s.repo.GetTotalCost(ctx, userID, serviceName, start, end)
+	items, err := s.repo.GetMonthlySubscriptions(ctx, userID, serviceName, start, end)
 	if err != nil {
-		return 0, err
+		return nil, err
+	}
+
+	byMonth := make(map[string][]models.MonthlySubscription)
+	for _, item := range items {
+		if currency == "" && item.Currency != items[0].Currency {
+			return nil, apperrors.NewBadRequest(models.ErrCurrencyRequired.Error(), models.ErrCurrencyRequired)
+		}
+		key := item.Month.Format(models.DateLayout)
+		byMonth[key] = append(byMonth[key], item)
+	}
+
+	response := &models.CostBreakdownResponse{Currency: currency}
+	if currency == "" && len(items) > 0 {
+		response.Currency = items[0].Currency
 	}
 
-	return total, nil
+	converter := newCurrencyConverter(s.rates, response.Currency)
+	for month := start; !month.After(end); month = month.AddDate(0, 1, 0) {
+		key := month.Format(models.DateLayout)
+		row := models.MonthlyCostResponse{
+			Month:         key,
+			Subscriptions: make([]models.SubscriptionResponse, 0, len(byMonth[key])),
+		}
+
+		var (
+			amounts    = make(map[string]int)
+			currencies []string
+		)
+		for _, item := range byMonth[key] {
+			if _, ok := amounts[item.Currency]; !ok {
+				currencies = append(currencies, item.Currency)
+			}
+			amounts[item.Currency] += item.Price * item.BillingPeriod.ChargesInMonth(item.StartDate, item.Month)
+			row.Subscriptions = append(row.Subscriptions, *models.NewSubscriptionResponse(&item.Subscription))
+		}
+		for _, from := range currencies {
+			converted, err := converter.convert(ctx, amounts[from], from, month)
+			if err != nil {
+				return nil, err
+			}
+			row.TotalCost += converted
+		}
+
+		response.TotalCost += row.TotalCost
+		response.Months = append(response.Months, row)
+	}
+
+	return response, nil
+}
+
+// parsePeriod разбирает границы периода и возвращает все нарушения сразу
+func parsePeriod(startStr, endStr string) (time.Time, time.Time, error) {
+	var v apperrors.Validation
+
+	start, startErr := parseDate(startStr)
+	if startErr != nil {
+		v.Add("start_date", models.ErrInvalidDate)
+	}
+	end, endErr := parseDate(endStr)
+	if endErr != nil {
+		v.Add("end_date", models.ErrInvalidDate)
+	}
+	if startErr == nil && endErr == nil && end.Before(start) {
+		v.Add("end_date", models.ErrInvalidPeriod)
+	}
+
+	if err := v.Err(); err != nil {
+		return time.Time{}, time.Time{}, err
+	}
+	return start, end, nil
 }
 
 func parseDate(dateStr string) (time.Time, error) {
-	t, err := time.Parse("01-2006", dateStr)
+	t, err := time.Parse(models.DateLayout, dateStr)
 	if err != nil {
 		return time.Time{}, fmt.Errorf("failed to parse date: %w", err)
 	}
@@ -0,0 +1,232 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	apperrors "github.com/Gilf4/effective-mobile-task/internal/errors"
+	"github.com/Gilf4/effective-mobile-task/internal/models"
+	"github.com/Gilf4/effective-mobile-task/internal/repository/memory"
+	"github.com/google/uuid"
+)
+
+func newTestService() *SubscriptionService {
+	repo := memory.NewSubscriptionRepository()
+	return NewSubscriptionService(repo, repo, repo, time.Hour, time.Minute)
+}
+
+func mustCreate(t *testing.T, s *SubscriptionService, req models.CreateSubscriptionRequest) *models.SubscriptionResponse {
+	t.Helper()
+
+	sub, err := s.CreateSubscription(context.Background(), req)
+	if err != nil {
+		t.Fatalf("CreateSubscription(%+v): %v", req, err)
+	}
+	return sub
+}
+
+// requireAppError проверяет HTTP-статус и код ошибки приложения
+func requireAppError(t *testing.T, err error, status int, code string) {
+	t.Helper()
+
+	var appErr *apperrors.AppError
+	if !errors.As(err, &appErr) {
+		t.Fatalf("error = %v, want AppError %d %s", err, status, code)
+	}
+	if appErr.Code != status || appErr.ErrorCode != code {
+		t.Fatalf("error = %d %s, want %d %s", appErr.Code, appErr.ErrorCode, status, code)
+	}
+}
+
+func TestListSubscriptionsPaging(t *testing.T) {
+	s := newTestService()
+	ctx := context.Background()
+
+	userID := uuid.New()
+	created := make(map[uuid.UUID]bool)
+	for _, name := range []string{"Netflix", "Spotify", "YouTube", "Yandex Plus", "Kinopoisk"} {
+		sub := mustCreate(t, s, models.CreateSubscriptionRequest{ServiceName: name, Price: 1000, UserID: userID, StartDate: "01-2026"})
+		created[sub.ID] = true
+	}
+	mustCreate(t, s, models.CreateSubscriptionRequest{ServiceName: "Netflix", Price: 1000, UserID: uuid.New(), StartDate: "01-2026"})
+
+	includeTotal := true
+	req := models.ListSubscriptionsRequest{UserID: &userID, Limit: 2, IncludeTotal: &includeTotal}
+
+	seen := make(map[uuid.UUID]bool)
+	pages := 0
+	for {
+		page, err := s.ListSubscriptions(ctx, req)
+		if err != nil {
+			t.Fatalf("ListSubscriptions page %d: %v", pages, err)
+		}
+		pages++
+
+		if page.Total == nil || *page.Total != int64(len(created)) {
+			t.Fatalf("page %d total = %v, want %d", pages, page.Total, len(created))
+		}
+		for _, sub := range page.Data {
+			if !created[sub.ID] {
+				t.Fatalf("page %d returned foreign subscription %s", pages, sub.ID)
+			}
+			if seen[sub.ID] {
+				t.Fatalf("page %d repeated subscription %s", pages, sub.ID)
+			}
+			seen[sub.ID] = true
+		}
+
+		if !page.HasMore {
+			if page.NextCursor != "" {
+				t.Fatalf("last page has next_cursor %q", page.NextCursor)
+			}
+			break
+		}
+		cursor, err := models.DecodeListCursor(page.NextCursor)
+		if err != nil {
+			t.Fatalf("decode next_cursor of page %d: %v", pages, err)
+		}
+		req.Cursor = cursor
+	}
+
+	if pages != 3 {
+		t.Errorf("pages = %d, want 3", pages)
+	}
+	if len(seen) != len(created) {
+		t.Errorf("listed %d subscriptions, want %d", len(seen), len(created))
+	}
+}
+
+func TestCalculateTotal(t *testing.T) {
+	s := newTestService()
+	ctx := context.Background()
+
+	userID := uuid.New()
+	end := "03-2026"
+	mustCreate(t, s, models.CreateSubscriptionRequest{ServiceName: "Netflix", Price: 1000, UserID: userID, StartDate: "01-2026", EndDate: &end})
+	mustCreate(t, s, models.CreateSubscriptionRequest{ServiceName: "Spotify", Price: 500, UserID: userID, StartDate: "02-2026"})
+
+	tests := []struct {
+		name string
+		mode models.TotalCostMode
+		want int
+	}{
+		{name: "prorated", mode: models.TotalCostModeProrated, want: 3*1000 + 3*500},
+		{name: "per subscription", mode: models.TotalCostModePerSubscription, want: 1000 + 500},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := s.CalculateTotal(ctx, &userID, "", "01-2026", "04-2026", tt.mode, "")
+			if err != nil {
+				t.Fatalf("CalculateTotal: %v", err)
+			}
+			if got.TotalCost != tt.want || got.Currency != models.DefaultCurrency {
+				t.Errorf("total = %d %s, want %d %s", got.TotalCost, got.Currency, tt.want, models.DefaultCurrency)
+			}
+		})
+	}
+
+	t.Run("no subscriptions", func(t *testing.T) {
+		_, err := s.CalculateTotal(ctx, &userID, "", "01-2025", "12-2025", "", "")
+		requireAppError(t, err, http.StatusNotFound, apperrors.CodeNotFound)
+	})
+}
+
+func TestCalculateTotalCurrencies(t *testing.T) {
+	s := newTestService()
+	ctx := context.Background()
+
+	userID := uuid.New()
+	end := "01-2026"
+	mustCreate(t, s, models.CreateSubscriptionRequest{ServiceName: "Yandex Plus", Price: 39900, UserID: userID, StartDate: "01-2026", EndDate: &end})
+	mustCreate(t, s, models.CreateSubscriptionRequest{ServiceName: "Netflix", Price: 1550, Currency: "USD", UserID: userID, StartDate: "01-2026", EndDate: &end})
+
+	_, err := s.CalculateTotal(ctx, &userID, "", "01-2026", "01-2026", "", "")
+	requireAppError(t, err, http.StatusBadRequest, models.ErrCurrencyRequired.ErrorCode())
+
+	_, err = s.CalculateTotal(ctx, &userID, "", "01-2026", "01-2026", "", "RUB")
+	requireAppError(t, err, http.StatusBadRequest, models.ErrExchangeRateNotFound.ErrorCode())
+
+	if _, err := s.UpsertExchangeRates(ctx, []models.ExchangeRateRequest{
+		{BaseCurrency: "USD", QuoteCurrency: "RUB", Month: "12-2025", Rate: "90"},
+	}); err != nil {
+		t.Fatalf("UpsertExchangeRates: %v", err)
+	}
+
+	got, err := s.CalculateTotal(ctx, &userID, "", "01-2026", "01-2026", "", "RUB")
+	if err != nil {
+		t.Fatalf("CalculateTotal: %v", err)
+	}
+	// 15,50 USD по курсу 90 - 1395 рублей
+	if want := 39900 + 139500; got.TotalCost != want || got.Currency != "RUB" {
+		t.Errorf("total = %d %s, want %d RUB", got.TotalCost, got.Currency, want)
+	}
+}
+
+func TestUpdateSubscriptionVersionConflict(t *testing.T) {
+	s := newTestService()
+	ctx := context.Background()
+
+	sub := mustCreate(t, s, models.CreateSubscriptionRequest{ServiceName: "Netflix", Price: 1000, UserID: uuid.New(), StartDate: "01-2026"})
+	if sub.Version != 1 {
+		t.Fatalf("created version = %d, want 1", sub.Version)
+	}
+
+	price := 1200
+	version := sub.Version
+	updated, err := s.UpdateSubscription(ctx, sub.ID, models.UpdateSubscriptionRequest{Price: &price}, &version)
+	if err != nil {
+		t.Fatalf("UpdateSubscription: %v", err)
+	}
+	if updated.Version != 2 || updated.Price != price {
+		t.Fatalf("updated = version %d price %d, want version 2 price %d", updated.Version, updated.Price, price)
+	}
+
+	stalePrice := 1500
+	_, err = s.UpdateSubscription(ctx, sub.ID, models.UpdateSubscriptionRequest{Price: &stalePrice}, &version)
+	requireAppError(t, err, http.StatusPreconditionFailed, models.ErrSubscriptionModified.ErrorCode())
+
+	err = s.DeleteSubscription(ctx, sub.ID, &version)
+	requireAppError(t, err, http.StatusPreconditionFailed, models.ErrSubscriptionModified.ErrorCode())
+
+	got, err := s.GetSubscription(ctx, sub.ID)
+	if err != nil {
+		t.Fatalf("GetSubscription: %v", err)
+	}
+	if got.Version != 2 || got.Price != price {
+		t.Errorf("stored = version %d price %d, want version 2 price %d", got.Version, got.Price, price)
+	}
+}
+
+func TestCreateSubscriptionIdempotent(t *testing.T) {
+	s := newTestService()
+	ctx := context.Background()
+
+	req := models.CreateSubscriptionRequest{ServiceName: "Netflix", Price: 1000, UserID: uuid.New(), StartDate: "01-2026"}
+	first, replayed, err := s.CreateSubscriptionIdempotent(ctx, "key-1", req)
+	if err != nil || replayed {
+		t.Fatalf("first call = replayed %v, err %v", replayed, err)
+	}
+
+	second, replayed, err := s.CreateSubscriptionIdempotent(ctx, "key-1", req)
+	if err != nil || !replayed {
+		t.Fatalf("second call = replayed %v, err %v", replayed, err)
+	}
+	if second.ID != first.ID {
+		t.Errorf("replayed id = %s, want %s", second.ID, first.ID)
+	}
+
+	req.Price = 2000
+	_, _, err = s.CreateSubscriptionIdempotent(ctx, "key-1", req)
+	requireAppError(t, err, http.StatusConflict, models.ErrIdempotencyKeyReused.ErrorCode())
+
+	list, err := s.ListSubscriptions(ctx, models.ListSubscriptionsRequest{UserID: &req.UserID})
+	if err != nil {
+		t.Fatalf("ListSubscriptions: %v", err)
+	}
+	if len(list.Data) != 1 {
+		t.Errorf("subscriptions = %d, want 1", len(list.Data))
+	}
+}
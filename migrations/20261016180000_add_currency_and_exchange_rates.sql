@@ -0,0 +1,23 @@
+-- +goose Up
+ALTER TABLE subscriptions ADD COLUMN IF NOT EXISTS currency TEXT NOT NULL DEFAULT 'RUB'
+  CHECK (currency ~ '^[A-Z]{3}$');
+
+-- price хранится в минорных единицах валюты: прежние цены были в целых рублях
+ALTER TABLE subscriptions ALTER COLUMN price TYPE BIGINT;
+UPDATE subscriptions SET price = price * 100;
+
+CREATE TABLE IF NOT EXISTS exchange_rates (
+  base_currency TEXT NOT NULL CHECK (base_currency ~ '^[A-Z]{3}$'),
+  quote_currency TEXT NOT NULL CHECK (quote_currency ~ '^[A-Z]{3}$'),
+  month DATE NOT NULL,
+  rate_e8 BIGINT NOT NULL CHECK (rate_e8 > 0),
+  updated_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+  PRIMARY KEY (base_currency, quote_currency, month),
+  CHECK (base_currency <> quote_currency)
+);
+
+-- +goose Down
+DROP TABLE IF EXISTS exchange_rates;
+UPDATE subscriptions SET price = price / 100;
+ALTER TABLE subscriptions ALTER COLUMN price TYPE INTEGER;
+ALTER TABLE subscriptions DROP COLUMN IF EXISTS currency;
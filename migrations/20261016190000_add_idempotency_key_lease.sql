@@ -0,0 +1,9 @@
+-- +goose Up
+-- незавершенный резерв ключа принадлежит запросу с lock_token до locked_until;
+-- резервы, созданные до миграции, не имеют аренды и могут быть заняты сразу
+ALTER TABLE idempotency_keys ADD COLUMN IF NOT EXISTS lock_token TEXT NULL;
+ALTER TABLE idempotency_keys ADD COLUMN IF NOT EXISTS locked_until TIMESTAMPTZ NULL;
+
+-- +goose Down
+ALTER TABLE idempotency_keys DROP COLUMN IF EXISTS locked_until;
+ALTER TABLE idempotency_keys DROP COLUMN IF EXISTS lock_token;
@@ -0,0 +1,9 @@
+// Package migrations содержит SQL-миграции схемы Postgres в формате goose
+package migrations
+
+import "embed"
+
+// FS - миграции, встроенные в бинарный файл приложения
+//
+//go:embed *.sql
+var FS embed.FS